@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/config"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/db"
+)
+
+const usage = `usage: migrate <command> [arg]
+
+commands:
+  status        show applied and pending migrations
+  up            apply all pending migrations
+  down [n]      revert the last n migrations (default 1)
+  force <v>     mark the schema as clean at version v without running scripts
+
+The database is configured like for the server: by the database section
+of the file in CONFIG_FILE and by DB_DRIVER (sqlite or postgres, default
+sqlite) and DB_DSN; for sqlite DB_PATH (default db/cup-of-team.db) is used
+when DB_DSN is empty.`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// The arguments are commands, the database comes from the file and the environment
+	cfg, _, err := config.Load(nil, os.LookupEnv, os.Stderr)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	dbConfig := cfg.DBConfig()
+
+	database, err := db.Open(dbConfig)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	migrations, err := db.Migrations(dbConfig.Driver)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	migrator := db.NewMigrator(database, migrations)
+
+	switch os.Args[1] {
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("Failed to get status: %v", err)
+		}
+		printStatus(status)
+
+	case "up":
+		if err := migrator.Up(); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Printf("Schema is up to date")
+
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			steps = mustAtoi(os.Args[2])
+		}
+		reverted, err := migrator.Down(steps)
+		if err != nil {
+			log.Fatalf("Rollback failed after reverting %d migration(s): %v", reverted, err)
+		}
+		log.Printf("Reverted %d migration(s)", reverted)
+
+	case "force":
+		if len(os.Args) < 3 {
+			log.Fatalf("force requires a version")
+		}
+		version := mustAtoi(os.Args[2])
+		if err := migrator.Force(version); err != nil {
+			log.Fatalf("Force failed: %v", err)
+		}
+		log.Printf("Schema forced to version %d", version)
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func printStatus(status *db.MigrationStatus) {
+	fmt.Printf("version: %d (latest %d)\n", status.Version, status.Latest)
+	if status.Dirty {
+		fmt.Println("state:   DIRTY")
+	}
+	for _, a := range status.Applied {
+		fmt.Printf("  [applied] %04d_%s  %s\n", a.Version, a.Name, a.AppliedAt.Format("2006-01-02 15:04:05"))
+	}
+	for _, p := range status.Pending {
+		fmt.Printf("  [pending] %04d_%s\n", p.Version, p.Name)
+	}
+}
+
+func mustAtoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		log.Fatalf("Invalid number %q", s)
+	}
+	return n
+}
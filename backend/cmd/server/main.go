@@ -1,64 +1,205 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sync"
+	"syscall"
 
 	api "github.com/kvloginov/cup-of-team/backend/internal/api/handlers"
+	"github.com/kvloginov/cup-of-team/backend/internal/config"
 	"github.com/kvloginov/cup-of-team/backend/internal/infra/db"
 	"github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/metrics"
 	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/tracing"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/webhook"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
 	"github.com/kvloginov/cup-of-team/backend/internal/usecase/team"
+	"github.com/kvloginov/cup-of-team/backend/internal/worker"
 )
 
 func main() {
-	// Get configuration from environment variables
-	port := getEnv("PORT", "8080")
-	dbPath := getEnv("DB_PATH", "db/cup-of-team.db")
-
-	// Ensure db directory exists
-	dbDir := filepath.Dir(dbPath)
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		log.Fatalf("Failed to create db directory: %v", err)
+	// Settings come from the defaults, a YAML file, the environment and
+	// the flags, in increasing precedence
+	cfg, options, err := config.Load(os.Args[1:], os.LookupEnv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if options.PrintConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			log.Fatalf("Failed to print configuration: %v", err)
+		}
+		return
+	}
+
+	// Log structured records to stdout, the standard logger included
+	logger, err := logging.New(os.Stdout, cfg.LoggingConfig())
+	if err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+	slog.SetDefault(logger)
+	if options.File != "" {
+		slog.Info("configuration loaded", "file", options.File)
 	}
 
-	// Initialize database
-	database, err := db.New(dbPath)
+	// Trace requests through the handlers, usecases and repository
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingConfig())
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatal("invalid tracing configuration", "error", err)
 	}
-	defer database.Close()
-	log.Printf("Database initialized at %s", dbPath)
 
-	// Create repository
-	repo := repository.New(database.DB)
+	// Shut down on SIGINT and SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Metrics of the server, the usecases and the database, served at /metrics
+	registry := metrics.NewRegistry()
+
+	// Create repository for the selected storage
+	var repo repository.Repository
+	var database *db.DB
+	switch driver := db.Driver(cfg.Database.Driver); driver {
+	case db.DriverMemory:
+		repo = repository.NewMemory()
+		slog.Warn("using in-memory storage, data will be lost on restart")
+
+	case db.DriverSQLite, db.DriverPostgres:
+		if driver == db.DriverSQLite && cfg.Database.DSN == "" {
+			// Ensure db directory exists
+			dbDir := filepath.Dir(cfg.Database.Path)
+			if err := os.MkdirAll(dbDir, 0755); err != nil {
+				fatal("failed to create db directory", "error", err)
+			}
+		}
+
+		// Initialize database
+		database, err = db.New(cfg.DBConfig())
+		if err != nil {
+			fatal("failed to initialize database", "error", err)
+		}
+		slog.Info("database initialized", "driver", driver)
+
+		database.RegisterMetrics(registry)
+		opts := []repository.SQLOption{
+			repository.WithQueryMetrics(registry),
+			repository.WithQueryTimeout(database.QueryTimeout),
+		}
+		if driver == db.DriverPostgres {
+			repo = repository.NewPostgres(database.DB, opts...)
+		} else {
+			repo = repository.NewSQLite(database.DB, opts...)
+		}
+	}
+
+	repo = repository.NewTraced(repo)
 
 	// Create usecases
-	teamUsecase := team.NewUsecase(repo)
+	teamUsecase := usecase.NewTraced(team.NewUsecase(repo,
+		team.WithMetrics(registry),
+		team.WithWebhookSender(webhook.NewSender(cfg.Webhooks.Timeout, cfg.WebhookAllowlist())),
+		team.WithWebhookRetry(cfg.WebhookRetry()),
+	))
+
+	// Background workers run until shutdown
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	// Empty the trash in the background
+	purgeCtx := logging.WithLogger(workerCtx, logger.With("worker", "purge"))
+	purgeWorker := worker.NewPurgeWorker(teamUsecase, cfg.PurgeConfig())
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		purgeWorker.Run(purgeCtx)
+	}()
+
+	// Send webhook deliveries in the background
+	webhookCtx := logging.WithLogger(workerCtx, logger.With("worker", "webhooks"))
+	webhookWorker := worker.NewWebhookWorker(teamUsecase, cfg.WebhookConfig())
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		webhookWorker.Run(webhookCtx)
+	}()
 
 	// Create handlers
 	handlers := api.NewHandlers(teamUsecase)
 
 	// Create server
-	server := http.NewServer(http.Config{
-		Port: ":" + port,
-	})
+	serverConfig := cfg.HTTPConfig()
+	serverConfig.Logger = logger
+	serverConfig.Metrics = registry
+	server := http.NewServer(serverConfig)
 
 	// Register routes (API routes without /api prefix, it will be added automatically)
 	handlers.RegisterRoutes(server)
 
 	// Start server
-	log.Printf("🚀 API server starting on http://localhost:%s", port)
-	if err := server.Start(); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	slog.Info("API server starting", "url", fmt.Sprintf("http://localhost:%d", cfg.Server.Port))
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Start()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			fatal("server failed to start", "error", err)
+		}
+	case <-ctx.Done():
+		slog.Info("shutting down", "timeout", cfg.Server.ShutdownTimeout)
 	}
-}
+	stop() // a second signal kills the process
 
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Streams first: they hold connections open and read the database
+	if err := handlers.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to close streams", "error", err)
+	}
+
+	// Drain the requests in flight
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to drain connections", "error", err)
 	}
-	return defaultValue
+
+	// Let the workers finish what they are doing
+	stopWorkers()
+	workers.Wait()
+
+	// Nothing uses the database anymore
+	if database != nil {
+		if err := database.Close(); err != nil {
+			slog.Error("failed to close database", "error", err)
+		}
+	}
+
+	// Send the spans still buffered
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
+	}
+
+	slog.Info("server stopped")
+}
+
+// fatal logs an error and exits
+func fatal(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
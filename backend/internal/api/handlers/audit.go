@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+)
+
+// auditRegisterer passes the metadata of every request to the usecases,
+// which record it in the audit log
+type auditRegisterer struct {
+	Registerer
+}
+
+// Handle registers handler wrapped with withRequestInfo
+func (a auditRegisterer) Handle(method, pattern string, handler http.HandlerFunc) {
+	a.Registerer.Handle(method, pattern, withRequestInfo(handler))
+}
+
+// withRequestInfo stores the client address, user agent and request ID
+// of the request in its context. The server assigns the request ID, from
+// X-Request-ID when the client sends a valid one.
+func withRequestInfo(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info := domain.RequestInfo{
+			RequestID: httpServer.RequestID(r.Context()),
+			IP:        clientIP(r),
+			UserAgent: r.UserAgent(),
+		}
+
+		next(w, r.WithContext(usecase.WithRequestInfo(r.Context(), info)))
+	}
+}
+
+// clientIP returns the address the request came from. Forwarding headers
+// are not trusted, they can be set by anyone.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+)
+
+// accessKey is the context key holding the caller's domain.Access
+type accessKey struct{}
+
+var (
+	errTokenRequired = domain.Unauthorized("token_required", "Authorization header with a team token is required")
+	errRoleForbidden = domain.Forbidden("role_forbidden", "Team token does not allow this action")
+	errWrongTeam     = domain.Forbidden("wrong_team", "Team token does not belong to this team")
+)
+
+// requireRole authorizes the request by the team secret in the
+// Authorization header ("Bearer <token>") before calling next
+func (h *Handlers) requireRole(role domain.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := bearerToken(r)
+		if !ok {
+			httpServer.SendDomainError(w, errTokenRequired, "")
+			return
+		}
+
+		access, err := h.teamUsecase.Authorize(r.Context(), secret)
+		if err != nil {
+			httpServer.SendDomainError(w, err, "Failed to authorize")
+			return
+		}
+
+		if !access.Role.Allows(role) {
+			httpServer.SendDomainError(w, errRoleForbidden, "")
+			return
+		}
+
+		// Only now is it known which team the request counts against
+		if !httpServer.LimitTeam(w, r, access.TeamID) {
+			return
+		}
+
+		// The usecases attribute changes to the secret in the audit log
+		ctx := context.WithValue(r.Context(), accessKey{}, access)
+		ctx = usecase.WithActor(ctx, domain.Actor{
+			Type: domain.ActorToken,
+			Role: access.Role,
+			ID:   access.TokenID,
+		})
+
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// withQueryToken lets next read the team token from the access_token
+// query parameter. EventSource and WebSocket clients in browsers can't set
+// the Authorization header, so the streaming routes accept it there.
+func withQueryToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		next(w, r)
+	}
+}
+
+// checkTeamAccess verifies that the authorized token belongs to teamID,
+// sending an error response and returning false otherwise
+func checkTeamAccess(w http.ResponseWriter, r *http.Request, teamID string) bool {
+	access, ok := r.Context().Value(accessKey{}).(*domain.Access)
+	if !ok {
+		httpServer.SendDomainError(w, errTokenRequired, "")
+		return false
+	}
+
+	if access.TeamID != teamID {
+		httpServer.SendDomainError(w, errWrongTeam, "")
+		return false
+	}
+
+	return true
+}
+
+// bearerToken extracts the token from "Authorization: Bearer <token>"
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -1,17 +1,19 @@
 package handlers
 
 import (
-	"log"
 	"net/http"
 
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
 	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
 )
 
 // HandleRemoveFromTeam handles DELETE /api/team/user
+// and DELETE /api/v1/teams/{teamId}/members/{userId}
 func (h *Handlers) HandleRemoveFromTeam(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
-	teamID := r.URL.Query().Get("team_id")
-	userID := r.URL.Query().Get("user_id")
+	teamID := param(r, teamIDVar, "team_id")
+	userID := param(r, userIDVar, "user_id")
 
 	if teamID == "" {
 		httpServer.SendError(w, http.StatusBadRequest, "team_id parameter is required")
@@ -23,14 +25,26 @@ func (h *Handlers) HandleRemoveFromTeam(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	log.Printf("[DELETE /api/team/user] team_id=%s user_id=%s", teamID, userID)
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	expectedVersion, err := ifMatchVersion(r)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "")
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("remove member", "team_id", teamID, "user_id", userID)
 
 	// Remove user via usecase
-	if err := h.teamUsecase.RemoveUser(teamID, userID); err != nil {
-		httpServer.SendError(w, http.StatusInternalServerError, "Failed to remove user from team")
+	version, err := h.teamUsecase.RemoveUser(r.Context(), teamID, userID, expectedVersion)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to remove user from team")
 		return
 	}
 
-	// Send empty response
-	httpServer.SendJSON(w, http.StatusOK, struct{}{})
+	// Send response
+	setTeamETag(w, version)
+	httpServer.SendJSON(w, http.StatusOK, model.RemoveFromTeamResponse{})
 }
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// HandleRevokeInvite handles DELETE /api/v1/teams/{teamId}/invites/{code}
+func (h *Handlers) HandleRevokeInvite(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+	code := pathVar(r, codeVar)
+
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("revoke invite", "team_id", teamID)
+
+	// Revoke invite via usecase
+	if err := h.teamUsecase.RevokeInvite(r.Context(), teamID, code); err != nil {
+		httpServer.SendDomainError(w, err, "Failed to revoke invite")
+		return
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, model.RevokeInviteResponse{})
+}
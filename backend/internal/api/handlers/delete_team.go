@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// HandleDeleteTeam handles DELETE /api/v1/teams/{teamId}
+func (h *Handlers) HandleDeleteTeam(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	expectedVersion, err := ifMatchVersion(r)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "")
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("delete team", "team_id", teamID)
+
+	// Delete team via usecase
+	if err := h.teamUsecase.DeleteTeam(r.Context(), teamID, expectedVersion); err != nil {
+		httpServer.SendDomainError(w, err, "Failed to delete team")
+		return
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, model.DeleteTeamResponse{})
+}
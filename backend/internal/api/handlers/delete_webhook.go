@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// HandleDeleteWebhook handles DELETE /api/v1/teams/{teamId}/webhooks/{webhookId}
+func (h *Handlers) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+	webhookID := pathVar(r, webhookIDVar)
+
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("delete webhook", "team_id", teamID, "webhook_id", webhookID)
+
+	// Delete webhook via usecase
+	if err := h.teamUsecase.DeleteWebhook(r.Context(), teamID, webhookID); err != nil {
+		httpServer.SendDomainError(w, err, "Failed to delete webhook")
+		return
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, model.DeleteWebhookResponse{})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+)
+
+// errBadIfMatch is returned for If-Match headers other than "*" or a single
+// team ETag. Per RFC 9110 a precondition that can't match fails with 412.
+var errBadIfMatch = domain.PreconditionFailed("invalid_if_match", "If-Match must be \"*\" or a single ETag of the team")
+
+// setTeamETag sends the team version as a strong ETag
+func setTeamETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", formatETag(version))
+}
+
+// formatETag formats a version as a strong ETag, e.g. "3"
+func formatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchVersion returns the team version required by the If-Match header,
+// 0 when there is no header or any version will do ("*")
+func ifMatchVersion(r *http.Request) (int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	// Weak ETags never match in If-Match
+	if !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) || len(header) < 2 {
+		return 0, errBadIfMatch
+	}
+
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, errBadIfMatch
+	}
+
+	return version, nil
+}
+
+// notModified reports whether the If-None-Match header already names the
+// current ETag, so GET can answer 304 without a body
+func notModified(r *http.Request, version int64) bool {
+	etag := formatETag(version)
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// HandleListInvites handles GET /api/v1/teams/{teamId}/invites
+func (h *Handlers) HandleListInvites(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("list invites", "team_id", teamID)
+
+	// List invites via usecase
+	invites, err := h.teamUsecase.ListInvites(r.Context(), teamID)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to list invites")
+		return
+	}
+
+	// Send response
+	response := model.ListInvitesResponse{
+		Invites: invites,
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// HandleGetMember handles GET /api/v1/teams/{teamId}/members/{userId}.
+// The ETag is the one of the team, which writes of the member expect.
+func (h *Handlers) HandleGetMember(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+	userID := pathVar(r, userIDVar)
+
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("get member", "team_id", teamID, "user_id", userID)
+
+	// Get user via usecase
+	result, err := h.teamUsecase.GetUser(r.Context(), teamID, userID)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to get user")
+		return
+	}
+
+	setTeamETag(w, result.TeamVersion)
+	if notModified(r, result.TeamVersion) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	// Send response
+	response := model.GetMemberResponse{
+		User: result.User,
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
@@ -1,28 +1,40 @@
 package handlers
 
 import (
-	"log"
 	"net/http"
 
 	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
 	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
 )
 
 // HandleGetTeam handles GET /api/team
+// and GET /api/v1/teams/{teamId}
 func (h *Handlers) HandleGetTeam(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
-	teamID := r.URL.Query().Get("team_id")
+	teamID := param(r, teamIDVar, "team_id")
 	if teamID == "" {
 		httpServer.SendError(w, http.StatusBadRequest, "team_id parameter is required")
 		return
 	}
 
-	log.Printf("[GET /api/team] team_id=%s", teamID)
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("get team", "team_id", teamID)
 
 	// Get team via usecase
-	team, err := h.teamUsecase.GetTeam(teamID)
+	team, err := h.teamUsecase.GetTeam(r.Context(), teamID)
 	if err != nil {
-		httpServer.SendError(w, http.StatusNotFound, "Team not found")
+		httpServer.SendDomainError(w, err, "Failed to get team")
+		return
+	}
+
+	// Clients polling with the last ETag get an empty 304
+	setTeamETag(w, team.Version)
+	if notModified(r, team.Version) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+)
+
+// Page sizes of the audit log
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 200
+)
+
+// HandleListAudit handles GET /api/v1/teams/{teamId}/audit
+func (h *Handlers) HandleListAudit(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters
+	query := r.URL.Query()
+	params := usecase.ListAuditParams{
+		TeamID: pathVar(r, teamIDVar),
+		Limit:  defaultAuditLimit,
+	}
+
+	for name, dest := range map[string]*time.Time{"from": &params.From, "to": &params.To} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			httpServer.SendError(w, http.StatusBadRequest, name+" must be an RFC 3339 time")
+			return
+		}
+		*dest = parsed
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 1 {
+			httpServer.SendError(w, http.StatusBadRequest, "cursor must be a positive integer")
+			return
+		}
+		params.Cursor = parsed
+	}
+
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxAuditLimit {
+			httpServer.SendError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit))
+			return
+		}
+		params.Limit = parsed
+	}
+
+	if !checkTeamAccess(w, r, params.TeamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("list audit log", "team_id", params.TeamID, "cursor", params.Cursor)
+
+	// List audit log via usecase
+	page, err := h.teamUsecase.ListAudit(r.Context(), params)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to list audit log")
+		return
+	}
+
+	// Send response
+	response := model.ListAuditResponse{
+		Entries:    page.Entries,
+		NextCursor: page.NextCursor,
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// HandleTeamEvents handles GET /api/v1/teams/{teamId}/events, streaming
+// the changes of a team as Server-Sent Events. Every event has its ID and type set, the
+// data is the JSON of domain.Event. Reconnecting clients resume after the
+// Last-Event-ID they send. Streams end when the handlers shut down.
+func (h *Handlers) HandleTeamEvents(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	afterID, err := lastEventID(r)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpServer.SendError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("watch team events", "team_id", teamID, "last_event_id", afterID)
+
+	// Watch team via usecase, until the client goes away or the server
+	// shuts down
+	ctx, done, ok := h.startStream(r)
+	if !ok {
+		httpServer.SendError(w, http.StatusServiceUnavailable, "Server is shutting down")
+		return
+	}
+	defer done()
+
+	watch, err := h.teamUsecase.WatchTeam(ctx, teamID, afterID)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to watch team")
+		return
+	}
+
+	clearDeadlines(w)
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering
+	w.WriteHeader(http.StatusOK)
+
+	// An ID without data sets the client's last event ID, so it resumes
+	// from here even if it reconnects before the first event
+	fmt.Fprintf(w, "retry: 3000\nid: %d\n\n", watch.Cursor)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-watch.Events:
+			if !ok {
+				return
+			}
+			rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := writeSSE(w, event); err != nil {
+				return
+			}
+
+		case <-keepAlive.C:
+			rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeSSE writes one event in the text/event-stream format
+func writeSSE(w http.ResponseWriter, event domain.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+var upgrader = websocket.Upgrader{
+	// The CORS policy of the server has checked the origin already
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// HandleTeamSocket handles GET /api/v1/teams/{teamId}/ws, streaming the changes of a team over a WebSocket. After a model.TeamSocketReady message every
+// message is a domain.Event. Clients resume with the last_event_id query
+// parameter. When the handlers shut down the socket is closed as going away.
+func (h *Handlers) HandleTeamSocket(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	afterID, err := lastEventID(r)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "")
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("watch team socket", "team_id", teamID, "last_event_id", afterID)
+
+	// Watch team via usecase, until the client goes away or the server
+	// shuts down
+	ctx, done, ok := h.startStream(r)
+	if !ok {
+		httpServer.SendError(w, http.StatusServiceUnavailable, "Server is shutting down")
+		return
+	}
+	defer done()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	watch, err := h.teamUsecase.WatchTeam(ctx, teamID, afterID)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to watch team")
+		return
+	}
+
+	// Upgrade responds with an error itself and lifts the deadlines of
+	// the server from the connection
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("WebSocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	// Clients send nothing but control frames, reading handles them and
+	// notices when the connection is gone
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(v interface{}) error {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		return conn.WriteJSON(v)
+	}
+
+	if err := send(model.TeamSocketReady{Type: "ready", LastEventID: watch.Cursor}); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-watch.Events:
+			if !ok {
+				closing := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+				conn.WriteControl(websocket.CloseMessage, closing, time.Now().Add(streamWriteTimeout))
+				return
+			}
+			if err := send(event); err != nil {
+				return
+			}
+
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// HandleListTrash handles GET /api/v1/teams/{teamId}/trash
+func (h *Handlers) HandleListTrash(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("list trash", "team_id", teamID)
+
+	// List trash via usecase
+	users, err := h.teamUsecase.ListTrash(r.Context(), teamID)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to list trash")
+		return
+	}
+
+	// Send response
+	response := model.ListTrashResponse{
+		Users: users,
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
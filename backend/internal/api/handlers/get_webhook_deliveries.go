@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// Page sizes of the delivery log
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 200
+)
+
+// HandleListWebhookDeliveries handles GET /api/v1/teams/{teamId}/webhooks/{webhookId}/deliveries
+func (h *Handlers) HandleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+	webhookID := pathVar(r, webhookIDVar)
+
+	limit := defaultDeliveriesLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxDeliveriesLimit {
+			httpServer.SendError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxDeliveriesLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("list webhook deliveries", "team_id", teamID, "webhook_id", webhookID)
+
+	// List deliveries via usecase
+	deliveries, err := h.teamUsecase.ListWebhookDeliveries(r.Context(), teamID, webhookID, limit)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to list deliveries")
+		return
+	}
+
+	// Send response
+	response := model.ListWebhookDeliveriesResponse{
+		Deliveries: deliveries,
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// HandleListWebhooks handles GET /api/v1/teams/{teamId}/webhooks
+func (h *Handlers) HandleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("list webhooks", "team_id", teamID)
+
+	// List webhooks via usecase
+	webhooks, err := h.teamUsecase.ListWebhooks(r.Context(), teamID)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to list webhooks")
+		return
+	}
+
+	// Send response
+	response := model.ListWebhooksResponse{
+		Webhooks: webhooks,
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
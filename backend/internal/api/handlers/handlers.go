@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"net/http"
+	"sync"
 
 	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
 	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
 	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
 )
@@ -11,12 +13,21 @@ import (
 // Handlers holds dependencies for HTTP handlers
 type Handlers struct {
 	teamUsecase usecase.TeamUsecase
+	// routes are the registered routes, described by OpenAPI
+	routes []route
+
+	// streams counts the open event streams and WebSockets, stopping is
+	// closed by Shutdown to end them
+	streamsMu sync.Mutex
+	streams   sync.WaitGroup
+	stopping  chan struct{}
 }
 
 // NewHandlers creates a new Handlers instance
 func NewHandlers(teamUsecase usecase.TeamUsecase) *Handlers {
 	return &Handlers{
 		teamUsecase: teamUsecase,
+		stopping:    make(chan struct{}),
 	}
 }
 
@@ -29,11 +40,66 @@ type Registerer interface {
 	Handle(method, pattern string, handler http.HandlerFunc)
 }
 
+// legacyDeprecation is the Deprecation header (RFC 9745) of the routes
+// that predate /api/v1: deprecated since 2026-10-17
+const legacyDeprecation = "@1792195200"
+
+// legacyRegisterer marks every route as deprecated in favor of /api/v1
+type legacyRegisterer struct {
+	Registerer
+}
+
+// Handle registers handler answering with a Deprecation header
+func (l legacyRegisterer) Handle(method, pattern string, handler http.HandlerFunc) {
+	l.Registerer.Handle(method, pattern, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", legacyDeprecation)
+		handler(w, r)
+	})
+}
+
+// RegisterRoutes registers all routes. Everything except team creation,
+// joining by invite and health needs a team token: reads need the viewer
+// role, writes the admin one. The event streams also take the token from
+// the access_token query parameter. Every route passes the request
+// metadata on to the audit log.
+//
+// The /api/v1 routes address teams and their members as resources by
+// path. The original routes, which pass IDs in query strings and bodies,
+// keep working, marked deprecated; newer endpoints exist only under
+// /api/v1. GET /api/openapi.json describes them all.
 func (h *Handlers) RegisterRoutes(server Registerer) {
-	server.Handle("POST", "/team", h.HandleCreateTeam)
-	server.Handle("GET", "/team", h.HandleGetTeam)
-	server.Handle("POST", "/team/user", h.HandleAddToTeam)
-	server.Handle("DELETE", "/team/user", h.HandleRemoveFromTeam)
+	server = auditRegisterer{server}
+	api := routeRecorder{Registerer: server, routes: &h.routes}
+	legacy := routeRecorder{Registerer: legacyRegisterer{server}, routes: &h.routes, deprecated: true}
+
+	api.Handle("POST", "/v1/teams", h.HandleCreateTeam)
+	api.Handle("GET", "/v1/teams/{teamId}", h.requireRole(domain.RoleViewer, h.HandleGetTeam))
+	api.Handle("PATCH", "/v1/teams/{teamId}", h.requireRole(domain.RoleAdmin, h.HandleUpdateTeam))
+	api.Handle("DELETE", "/v1/teams/{teamId}", h.requireRole(domain.RoleAdmin, h.HandleDeleteTeam))
+	api.Handle("POST", "/v1/teams/{teamId}/restore", h.requireRole(domain.RoleAdmin, h.HandleRestoreTeam))
+	api.Handle("GET", "/v1/teams/{teamId}/members/{userId}", h.requireRole(domain.RoleViewer, h.HandleGetMember))
+	api.Handle("PUT", "/v1/teams/{teamId}/members/{userId}", h.requireRole(domain.RoleAdmin, h.HandlePutMember))
+	api.Handle("DELETE", "/v1/teams/{teamId}/members/{userId}", h.requireRole(domain.RoleAdmin, h.HandleRemoveFromTeam))
+	api.Handle("POST", "/v1/teams/{teamId}/members/{userId}/restore", h.requireRole(domain.RoleAdmin, h.HandleRestoreUser))
+	api.Handle("GET", "/v1/teams/{teamId}/events", withQueryToken(h.requireRole(domain.RoleViewer, h.HandleTeamEvents)))
+	api.Handle("GET", "/v1/teams/{teamId}/ws", withQueryToken(h.requireRole(domain.RoleViewer, h.HandleTeamSocket)))
+	api.Handle("GET", "/v1/teams/{teamId}/trash", h.requireRole(domain.RoleViewer, h.HandleListTrash))
+	api.Handle("POST", "/v1/teams/{teamId}/tokens/{role}", h.requireRole(domain.RoleAdmin, h.HandleRotateToken))
+	api.Handle("POST", "/v1/teams/{teamId}/invites", h.requireRole(domain.RoleAdmin, h.HandleCreateInvite))
+	api.Handle("GET", "/v1/teams/{teamId}/invites", h.requireRole(domain.RoleAdmin, h.HandleListInvites))
+	api.Handle("DELETE", "/v1/teams/{teamId}/invites/{code}", h.requireRole(domain.RoleAdmin, h.HandleRevokeInvite))
+	api.Handle("POST", "/v1/teams/{teamId}/webhooks", h.requireRole(domain.RoleAdmin, h.HandleCreateWebhook))
+	api.Handle("GET", "/v1/teams/{teamId}/webhooks", h.requireRole(domain.RoleAdmin, h.HandleListWebhooks))
+	api.Handle("DELETE", "/v1/teams/{teamId}/webhooks/{webhookId}", h.requireRole(domain.RoleAdmin, h.HandleDeleteWebhook))
+	api.Handle("GET", "/v1/teams/{teamId}/webhooks/{webhookId}/deliveries", h.requireRole(domain.RoleAdmin, h.HandleListWebhookDeliveries))
+	api.Handle("GET", "/v1/teams/{teamId}/audit", h.requireRole(domain.RoleAdmin, h.HandleListAudit))
+	api.Handle("POST", "/v1/invites/{code}/join", h.HandleJoinByInvite)
+
+	legacy.Handle("POST", "/team", h.HandleCreateTeam)
+	legacy.Handle("GET", "/team", h.requireRole(domain.RoleViewer, h.HandleGetTeam))
+	legacy.Handle("POST", "/team/user", h.requireRole(domain.RoleAdmin, h.HandleAddToTeam))
+	legacy.Handle("DELETE", "/team/user", h.requireRole(domain.RoleAdmin, h.HandleRemoveFromTeam))
 
-	server.Handle("GET", "/health", h.HandleHealth)
+	api.Handle("GET", "/openapi.json", h.HandleOpenAPI)
+	api.Handle("GET", "/health", h.HandleHealth)
 }
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/openapi"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// apiInfo heads the OpenAPI document
+var apiInfo = openapi.Info{
+	Title:       "Cup of Team API",
+	Description: "Teams, their members and everything around them. IDs and secrets are generated by the server.",
+	Version:     "1.0.0",
+}
+
+// route is a route as registered by RegisterRoutes
+type route struct {
+	method     string
+	pattern    string
+	deprecated bool
+}
+
+// routeRecorder remembers the routes registered through it, which the
+// OpenAPI document describes
+type routeRecorder struct {
+	Registerer
+	routes     *[]route
+	deprecated bool
+}
+
+// Handle records the route and registers it
+func (rr routeRecorder) Handle(method, pattern string, handler http.HandlerFunc) {
+	*rr.routes = append(*rr.routes, route{method: method, pattern: pattern, deprecated: rr.deprecated})
+	rr.Registerer.Handle(method, pattern, handler)
+}
+
+// routeDoc documents a route. The role must match the one the route
+// requires in RegisterRoutes, the contract test checks both agree.
+type routeDoc struct {
+	summary     string
+	description string
+	tag         string
+	role        domain.Role // empty for public routes
+	query       []openapi.Param
+	request     interface{}
+	response    interface{}
+	contentType string
+	status      int
+}
+
+// Query parameters shared by the routes
+var (
+	teamIDQuery    = openapi.Param{Name: "team_id", Required: true}
+	userIDQuery    = openapi.Param{Name: "user_id", Required: true}
+	lastEventQuery = openapi.Param{Name: "last_event_id", Type: "integer",
+		Description: "Resume after this event, like the Last-Event-ID header"}
+	tokenQuery = openapi.Param{Name: "access_token",
+		Description: "Team secret for clients that can't set the Authorization header"}
+	limitQuery = openapi.Param{Name: "limit", Type: "integer"}
+	auditQuery = []openapi.Param{
+		{Name: "from", Description: "RFC 3339 time, inclusive"},
+		{Name: "to", Description: "RFC 3339 time, exclusive"},
+		{Name: "cursor", Type: "integer", Description: "next_cursor of the previous page"},
+		limitQuery,
+	}
+)
+
+// teamETagDoc describes the ETag of the routes of members: members have
+// no ETag of their own, every change of a member changes the team's
+const teamETagDoc = "Members are versioned with their team: the ETag is the team's, " +
+	"and If-Match on writes of a member takes an ETag of the team, like the team routes."
+
+// routeDocs documents every route by "METHOD pattern"
+var routeDocs = map[string]routeDoc{
+	"GET /health":       {summary: "Check the server is up", tag: "meta", response: model.HealthResponse{}},
+	"GET /openapi.json": {summary: "This document", tag: "meta", response: map[string]interface{}{}},
+
+	"POST /v1/teams": {
+		summary: "Create a team", tag: "teams",
+		request: model.CreateTeamRequest{}, response: model.CreateTeamResponse{},
+	},
+	"GET /v1/teams/{teamId}": {
+		summary: "Get a team with its members", tag: "teams", role: domain.RoleViewer,
+		response: model.GetTeamResponse{},
+	},
+	"PATCH /v1/teams/{teamId}": {
+		summary: "Change a team", tag: "teams", role: domain.RoleAdmin,
+		request: model.UpdateTeamRequest{}, response: model.UpdateTeamResponse{},
+	},
+	"DELETE /v1/teams/{teamId}": {
+		summary: "Move a team to the trash", tag: "teams", role: domain.RoleAdmin,
+		response: model.DeleteTeamResponse{},
+	},
+	"POST /v1/teams/{teamId}/restore": {
+		summary: "Restore a team from the trash", tag: "teams", role: domain.RoleAdmin,
+		response: model.RestoreTeamResponse{},
+	},
+	"GET /v1/teams/{teamId}/members/{userId}": {
+		summary: "Get a member", description: teamETagDoc, tag: "members", role: domain.RoleViewer,
+		response: model.GetMemberResponse{},
+	},
+	"PUT /v1/teams/{teamId}/members/{userId}": {
+		summary: "Add or replace a member", description: teamETagDoc, tag: "members", role: domain.RoleAdmin,
+		request: model.PutMemberRequest{}, response: model.PutMemberResponse{},
+	},
+	"DELETE /v1/teams/{teamId}/members/{userId}": {
+		summary: "Move a member to the trash", description: teamETagDoc, tag: "members", role: domain.RoleAdmin,
+		response: model.RemoveFromTeamResponse{},
+	},
+	"POST /v1/teams/{teamId}/members/{userId}/restore": {
+		summary: "Restore a member from the trash", tag: "members", role: domain.RoleAdmin,
+		response: model.RestoreUserResponse{},
+	},
+	"GET /v1/teams/{teamId}/events": {
+		summary: "Stream the changes of a team as Server-Sent Events", tag: "events", role: domain.RoleViewer,
+		query: []openapi.Param{lastEventQuery, tokenQuery}, response: domain.Event{}, contentType: "text/event-stream",
+	},
+	"GET /v1/teams/{teamId}/ws": {
+		summary: "Stream the changes of a team over a WebSocket", tag: "events", role: domain.RoleViewer,
+		query: []openapi.Param{lastEventQuery, tokenQuery}, status: http.StatusSwitchingProtocols,
+	},
+	"GET /v1/teams/{teamId}/trash": {
+		summary: "List the deleted members of a team", tag: "members", role: domain.RoleViewer,
+		response: model.ListTrashResponse{},
+	},
+	"POST /v1/teams/{teamId}/tokens/{role}": {
+		summary: "Replace the secret of a role", tag: "access", role: domain.RoleAdmin,
+		response: model.RotateTokenResponse{},
+	},
+	"POST /v1/teams/{teamId}/invites": {
+		summary: "Create an invite code", tag: "access", role: domain.RoleAdmin,
+		request: model.CreateInviteRequest{}, response: model.CreateInviteResponse{},
+	},
+	"GET /v1/teams/{teamId}/invites": {
+		summary: "List the invite codes of a team", tag: "access", role: domain.RoleAdmin,
+		response: model.ListInvitesResponse{},
+	},
+	"DELETE /v1/teams/{teamId}/invites/{code}": {
+		summary: "Revoke an invite code", tag: "access", role: domain.RoleAdmin,
+		response: model.RevokeInviteResponse{},
+	},
+	"POST /v1/teams/{teamId}/webhooks": {
+		summary: "Register a webhook", tag: "webhooks", role: domain.RoleAdmin,
+		request: model.CreateWebhookRequest{}, response: model.CreateWebhookResponse{},
+	},
+	"GET /v1/teams/{teamId}/webhooks": {
+		summary: "List the webhooks of a team", tag: "webhooks", role: domain.RoleAdmin,
+		response: model.ListWebhooksResponse{},
+	},
+	"DELETE /v1/teams/{teamId}/webhooks/{webhookId}": {
+		summary: "Delete a webhook", tag: "webhooks", role: domain.RoleAdmin,
+		response: model.DeleteWebhookResponse{},
+	},
+	"GET /v1/teams/{teamId}/webhooks/{webhookId}/deliveries": {
+		summary: "List the deliveries of a webhook", tag: "webhooks", role: domain.RoleAdmin,
+		query: []openapi.Param{limitQuery}, response: model.ListWebhookDeliveriesResponse{},
+	},
+	"GET /v1/teams/{teamId}/audit": {
+		summary: "List the audit log of a team", tag: "audit", role: domain.RoleAdmin,
+		query: auditQuery, response: model.ListAuditResponse{},
+	},
+	"POST /v1/invites/{code}/join": {
+		summary: "Join a team with an invite code", tag: "access",
+		request: model.JoinByInviteRequest{}, response: model.JoinByInviteResponse{},
+	},
+
+	"POST /team": {
+		summary: "Create a team", tag: "legacy",
+		request: model.CreateTeamRequest{}, response: model.CreateTeamResponse{},
+	},
+	"GET /team": {
+		summary: "Get a team with its members", tag: "legacy", role: domain.RoleViewer,
+		query: []openapi.Param{teamIDQuery}, response: model.GetTeamResponse{},
+	},
+	"POST /team/user": {
+		summary: "Add or replace a member", description: teamETagDoc, tag: "legacy", role: domain.RoleAdmin,
+		request: model.AddToTeamRequest{}, response: model.AddToTeamResponse{},
+	},
+	"DELETE /team/user": {
+		summary: "Move a member to the trash", description: teamETagDoc, tag: "legacy", role: domain.RoleAdmin,
+		query: []openapi.Param{teamIDQuery, userIDQuery}, response: model.RemoveFromTeamResponse{},
+	},
+}
+
+// OpenAPI describes the registered routes. It fails when a route has no
+// documentation or documentation has no route.
+func (h *Handlers) OpenAPI() (*openapi.Document, error) {
+	builder := openapi.NewBuilder(apiInfo, model.ErrorResponse{})
+
+	registered := make(map[string]bool, len(h.routes))
+	var problems []string
+	for _, rt := range h.routes {
+		key := rt.method + " " + rt.pattern
+		registered[key] = true
+
+		doc, ok := routeDocs[key]
+		if !ok {
+			problems = append(problems, "undocumented route "+key)
+			continue
+		}
+
+		builder.Add(openapi.Route{
+			Method:      rt.method,
+			Path:        routePath(rt.pattern),
+			Summary:     doc.summary,
+			Description: doc.description,
+			Tag:         doc.tag,
+			Deprecated:  rt.deprecated,
+			Role:        string(doc.role),
+			Query:       doc.query,
+			Request:     doc.request,
+			Response:    doc.response,
+			Status:      doc.status,
+			ContentType: doc.contentType,
+		})
+	}
+
+	for key := range routeDocs {
+		if !registered[key] {
+			problems = append(problems, "documented route "+key+" is not registered")
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("openapi: %s", strings.Join(problems, "; "))
+	}
+
+	return builder.Document(), nil
+}
+
+// routePath returns the path a pattern is served at, see
+// httpServer.Server: everything but /health lives under /api
+func routePath(pattern string) string {
+	if pattern == "/health" {
+		return pattern
+	}
+	return "/api" + pattern
+}
+
+// HandleOpenAPI handles GET /api/openapi.json
+func (h *Handlers) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debug("get OpenAPI document")
+
+	doc, err := h.OpenAPI()
+	if err != nil {
+		httpServer.SendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Send response
+	httpServer.SendJSON(w, http.StatusOK, doc)
+}
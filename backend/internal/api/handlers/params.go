@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Path variables of the /api/v1 routes. The legacy routes pass the team
+// and user IDs as query parameters or body fields.
+const (
+	teamIDVar    = "teamId"
+	userIDVar    = "userId"
+	roleVar      = "role"
+	codeVar      = "code"
+	webhookIDVar = "webhookId"
+)
+
+// pathVar returns a path variable of a /api/v1 route
+func pathVar(r *http.Request, name string) string {
+	return mux.Vars(r)[name]
+}
+
+// param returns a path variable of a /api/v1 route, or the query
+// parameter the legacy route passes it in
+func param(r *http.Request, name, queryName string) string {
+	if value, ok := mux.Vars(r)[name]; ok {
+		return value
+	}
+	return r.URL.Query().Get(queryName)
+}
+
+// decodeBody reads the JSON request body into v. Routes with path
+// variables may leave the body out when the path says it all.
+func decodeBody(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if errors.Is(err, io.EOF) && len(mux.Vars(r)) > 0 {
+		return nil
+	}
+	return err
+}
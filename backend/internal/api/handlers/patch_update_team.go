@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+)
+
+// HandleUpdateTeam handles PATCH /api/v1/teams/{teamId}
+func (h *Handlers) HandleUpdateTeam(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+
+	// Parse request body
+	var req model.UpdateTeamRequest
+	if err := decodeBody(r, &req); err != nil {
+		httpServer.SendError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	expectedVersion, err := ifMatchVersion(r)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "")
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("update team", "team_id", teamID)
+
+	// Update team via usecase
+	team, err := h.teamUsecase.UpdateTeam(r.Context(), usecase.UpdateTeamParams{
+		TeamID:          teamID,
+		Name:            req.Name,
+		ExpectedVersion: expectedVersion,
+	})
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to update team")
+		return
+	}
+
+	// Send response
+	setTeamETag(w, team.Version)
+	response := model.UpdateTeamResponse{
+		Team: *team,
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
@@ -1,12 +1,11 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
 
 	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
 	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
 	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
 )
 
@@ -14,7 +13,7 @@ import (
 func (h *Handlers) HandleAddToTeam(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
 	var req model.AddToTeamRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeBody(r, &req); err != nil {
 		httpServer.SendError(w, http.StatusBadRequest, "Invalid JSON body")
 		return
 	}
@@ -25,31 +24,35 @@ func (h *Handlers) HandleAddToTeam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.User.ID == "" {
-		httpServer.SendError(w, http.StatusBadRequest, "user.id is required")
+	// User fields are validated by the usecase, reporting all of them at once
+
+	if !checkTeamAccess(w, r, req.TeamID) {
 		return
 	}
 
-	if req.User.FirstName == "" {
-		httpServer.SendError(w, http.StatusBadRequest, "user.first_name is required")
+	expectedVersion, err := ifMatchVersion(r)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "")
 		return
 	}
 
-	log.Printf("[POST /api/team/user] team_id=%s user_id=%s", req.TeamID, req.User.ID)
+	logging.FromContext(r.Context()).Debug("add member", "team_id", req.TeamID, "user_id", req.User.ID)
 
 	// Add user via usecase
-	user, err := h.teamUsecase.AddUser(usecase.AddUserParams{
-		TeamID: req.TeamID,
-		User:   req.User,
+	result, err := h.teamUsecase.AddUser(r.Context(), usecase.AddUserParams{
+		TeamID:              req.TeamID,
+		User:                req.User,
+		ExpectedTeamVersion: expectedVersion,
 	})
 	if err != nil {
-		httpServer.SendError(w, http.StatusInternalServerError, "Failed to add user to team")
+		httpServer.SendDomainError(w, err, "Failed to add user to team")
 		return
 	}
 
 	// Send response
+	setTeamETag(w, result.TeamVersion)
 	response := model.AddToTeamResponse{
-		User: *user,
+		User: result.User,
 	}
 
 	httpServer.SendJSON(w, http.StatusOK, response)
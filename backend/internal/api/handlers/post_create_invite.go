@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+)
+
+// HandleCreateInvite handles POST /api/v1/teams/{teamId}/invites
+func (h *Handlers) HandleCreateInvite(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+
+	// Parse request body
+	var req model.CreateInviteRequest
+	if err := decodeBody(r, &req); err != nil {
+		httpServer.SendError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	// Validate request
+	role := domain.Role(req.Role)
+	if role != "" && !role.Valid() {
+		httpServer.SendError(w, http.StatusBadRequest, "role must be admin or viewer")
+		return
+	}
+
+	if req.MaxUses < 0 {
+		httpServer.SendError(w, http.StatusBadRequest, "max_uses must not be negative")
+		return
+	}
+
+	if req.ExpiresInSeconds < 0 {
+		httpServer.SendError(w, http.StatusBadRequest, "expires_in_seconds must not be negative")
+		return
+	}
+
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("create invite", "team_id", teamID, "role", role)
+
+	// Create invite via usecase
+	invite, err := h.teamUsecase.CreateInvite(r.Context(), usecase.CreateInviteParams{
+		TeamID:    teamID,
+		Role:      role,
+		MaxUses:   req.MaxUses,
+		ExpiresIn: time.Duration(req.ExpiresInSeconds) * time.Second,
+	})
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to create invite")
+		return
+	}
+
+	// Send response
+	response := model.CreateInviteResponse{
+		Invite: *invite,
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
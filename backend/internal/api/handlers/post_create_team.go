@@ -1,20 +1,20 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
 
 	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
 	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
 	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
 )
 
 // HandleCreateTeam handles POST /api/team
+// and POST /api/v1/teams
 func (h *Handlers) HandleCreateTeam(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
 	var req model.CreateTeamRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeBody(r, &req); err != nil {
 		httpServer.SendError(w, http.StatusBadRequest, "Invalid JSON body")
 		return
 	}
@@ -25,20 +25,22 @@ func (h *Handlers) HandleCreateTeam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("[POST /api/team] name=%s", req.Name)
+	logging.FromContext(r.Context()).Debug("create team", "name", req.Name)
 
 	// Create team via usecase
-	result, err := h.teamUsecase.CreateTeam(usecase.CreateTeamParams{
+	result, err := h.teamUsecase.CreateTeam(r.Context(), usecase.CreateTeamParams{
 		Name: req.Name,
 	})
 	if err != nil {
-		httpServer.SendError(w, http.StatusInternalServerError, "Failed to create team")
+		httpServer.SendDomainError(w, err, "Failed to create team")
 		return
 	}
 
 	// Send response
 	response := model.CreateTeamResponse{
-		ID: result.ID,
+		ID:          result.ID,
+		AdminToken:  result.AdminToken,
+		ViewerToken: result.ViewerToken,
 	}
 
 	httpServer.SendJSON(w, http.StatusOK, response)
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+)
+
+// HandleCreateWebhook handles POST /api/v1/teams/{teamId}/webhooks
+func (h *Handlers) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+
+	// Parse request body
+	var req model.CreateWebhookRequest
+	if err := decodeBody(r, &req); err != nil {
+		httpServer.SendError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("create webhook", "team_id", teamID)
+
+	// Create webhook via usecase
+	webhook, err := h.teamUsecase.CreateWebhook(r.Context(), usecase.CreateWebhookParams{
+		TeamID: teamID,
+		URL:    req.URL,
+		Secret: req.Secret,
+		Events: req.Events,
+	})
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to create webhook")
+		return
+	}
+
+	// Send response
+	response := model.CreateWebhookResponse{
+		Webhook: *webhook,
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
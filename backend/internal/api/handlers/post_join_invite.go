@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+)
+
+// HandleJoinByInvite handles POST /api/v1/invites/{code}/join
+func (h *Handlers) HandleJoinByInvite(w http.ResponseWriter, r *http.Request) {
+	code := pathVar(r, codeVar)
+
+	// Parse request body
+	var req model.JoinByInviteRequest
+	if err := decodeBody(r, &req); err != nil {
+		httpServer.SendError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	// User fields are validated by the usecase, reporting all of them at once
+
+	logging.FromContext(r.Context()).Debug("join by invite", "user_id", req.User.ID)
+
+	// Join via usecase
+	result, err := h.teamUsecase.JoinByInvite(r.Context(), usecase.JoinByInviteParams{
+		Code: code,
+		User: req.User,
+	})
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to join team")
+		return
+	}
+
+	// Send response
+	response := model.JoinByInviteResponse{
+		TeamID: result.TeamID,
+		Role:   string(result.Role),
+		Token:  result.Token,
+		User:   result.User,
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// HandleRestoreTeam handles POST /api/v1/teams/{teamId}/restore
+func (h *Handlers) HandleRestoreTeam(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("restore team", "team_id", teamID)
+
+	// Restore team via usecase
+	team, err := h.teamUsecase.RestoreTeam(r.Context(), teamID)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to restore team")
+		return
+	}
+
+	// Send response
+	setTeamETag(w, team.Version)
+	response := model.RestoreTeamResponse{
+		Team: *team,
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// HandleRestoreUser handles POST /api/v1/teams/{teamId}/members/{userId}/restore
+func (h *Handlers) HandleRestoreUser(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+	userID := pathVar(r, userIDVar)
+
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("restore member", "team_id", teamID, "user_id", userID)
+
+	// Restore user via usecase
+	user, err := h.teamUsecase.RestoreUser(r.Context(), teamID, userID)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to restore user")
+		return
+	}
+
+	// Send response
+	response := model.RestoreUserResponse{
+		User: *user,
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
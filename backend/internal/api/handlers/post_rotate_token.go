@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// HandleRotateToken handles POST /api/v1/teams/{teamId}/tokens/{role}
+func (h *Handlers) HandleRotateToken(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+
+	// Validate request
+	role := domain.Role(pathVar(r, roleVar))
+	if !role.Valid() {
+		httpServer.SendError(w, http.StatusBadRequest, "role must be admin or viewer")
+		return
+	}
+
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("rotate token", "team_id", teamID, "role", role)
+
+	// Rotate token via usecase
+	result, err := h.teamUsecase.RotateToken(r.Context(), teamID, role)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to rotate token")
+		return
+	}
+
+	// Send response
+	response := model.RotateTokenResponse{
+		Token: result.Token,
+	}
+	if len(result.OtherTokens) > 0 {
+		response.OtherTokens = make(map[string]string, len(result.OtherTokens))
+		for other, token := range result.OtherTokens {
+			response.OtherTokens[string(other)] = token
+		}
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+)
+
+// HandlePutMember handles PUT /api/v1/teams/{teamId}/members/{userId}.
+// It adds the member or replaces it like POST /api/team/user does.
+func (h *Handlers) HandlePutMember(w http.ResponseWriter, r *http.Request) {
+	// Parse path parameters
+	teamID := pathVar(r, teamIDVar)
+	userID := pathVar(r, userIDVar)
+
+	// Parse request body
+	var req model.PutMemberRequest
+	if err := decodeBody(r, &req); err != nil {
+		httpServer.SendError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	// Validate request
+	if req.ID != "" && req.ID != userID {
+		httpServer.SendError(w, http.StatusBadRequest, "user id in the body does not match the path")
+		return
+	}
+	req.ID = userID
+
+	// User fields are validated by the usecase, reporting all of them at once
+
+	if !checkTeamAccess(w, r, teamID) {
+		return
+	}
+
+	expectedVersion, err := ifMatchVersion(r)
+	if err != nil {
+		httpServer.SendDomainError(w, err, "")
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("put member", "team_id", teamID, "user_id", userID)
+
+	// Add or update user via usecase
+	result, err := h.teamUsecase.AddUser(r.Context(), usecase.AddUserParams{
+		TeamID:              teamID,
+		User:                req,
+		ExpectedTeamVersion: expectedVersion,
+	})
+	if err != nil {
+		httpServer.SendDomainError(w, err, "Failed to put user")
+		return
+	}
+
+	// Send response
+	setTeamETag(w, result.TeamVersion)
+	response := model.PutMemberResponse{
+		User: result.User,
+	}
+
+	httpServer.SendJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+)
+
+// keepAliveInterval is how often idle streams send something, so that
+// proxies don't close them
+const keepAliveInterval = 25 * time.Second
+
+// streamWriteTimeout bounds every write to a stream. Streams outlive the
+// server's read and write timeouts, so they set their own deadlines.
+const streamWriteTimeout = 10 * time.Second
+
+var errBadLastEventID = domain.Validation("invalid_last_event_id", "Last-Event-ID must be a non-negative integer")
+
+// lastEventID returns the event ID a stream resumes after, taken from the
+// Last-Event-ID header EventSource sends on reconnect or the
+// last_event_id query parameter. 0 means no events have been seen.
+func lastEventID(r *http.Request) (int64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, errBadLastEventID
+	}
+	return id, nil
+}
+
+// startStream registers a stream, so Shutdown waits for it. The context
+// is done when the client goes away or the handlers shut down; the stream
+// has to call done when it ends. ok is false once shutting down.
+func (h *Handlers) startStream(r *http.Request) (ctx context.Context, done func(), ok bool) {
+	h.streamsMu.Lock()
+	defer h.streamsMu.Unlock()
+
+	select {
+	case <-h.stopping:
+		return nil, nil, false
+	default:
+	}
+	h.streams.Add(1)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	go func() {
+		select {
+		case <-h.stopping:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		cancel()
+		h.streams.Done()
+	}, true
+}
+
+// clearDeadlines lifts the read and write timeouts of the server from the
+// connection of a stream. Writers that cannot, like test recorders, have
+// no deadlines anyway.
+func clearDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+}
+
+// Shutdown ends the event streams and WebSockets and refuses new ones,
+// then waits for them to finish or ctx to be done. Streams hold their
+// connections open, so it comes before the server shuts down.
+func (h *Handlers) Shutdown(ctx context.Context) error {
+	h.streamsMu.Lock()
+	select {
+	case <-h.stopping:
+	default:
+		close(h.stopping)
+	}
+	h.streamsMu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		h.streams.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -6,6 +6,12 @@ import "github.com/kvloginov/cup-of-team/backend/internal/domain"
 type ErrorResponse struct {
 	Success bool   `json:"success"`
 	Error   string `json:"error"`
+	// Code identifies the error for clients, e.g. "team_not_found".
+	// Generic codes: invalid_request, unauthorized, forbidden,
+	// not_found, conflict, gone, precondition_failed, internal.
+	Code string `json:"code"`
+	// Fields lists every invalid field when Code is a validation error
+	Fields []domain.FieldError `json:"fields,omitempty"`
 }
 
 // HealthResponse for health check
@@ -21,14 +27,22 @@ type CreateTeamRequest struct {
 
 // CreateTeamResponse
 type CreateTeamResponse struct {
-	// Team ID - generated by the server.
-	// Save it and share it only with your friends,
-	// because at first it will be as a authorization method.
+	// Team ID - generated by the server. It identifies the team
+	// but does not grant any access on its own.
 	ID string `json:"id"`
+	// AdminToken allows reading and changing the team.
+	// Keep it to yourself.
+	AdminToken string `json:"admin_token"`
+	// ViewerToken only allows reading the team.
+	// Share it with your friends.
+	ViewerToken string `json:"viewer_token"`
 }
 
 // AddToTeamRequest adds to the team a new user.
-// If user already exists, overwrite the existing user.
+// If user already exists in this team, overwrite the existing user. The
+// ID of a member in the trash is a conflict until it is restored.
+// Users are scoped to a team: the same user ID in another team is a
+// separate member and is never moved or changed.
 type AddToTeamRequest struct {
 	TeamID string      `json:"team_id"`
 	User   domain.User `json:"user"`
@@ -38,6 +52,18 @@ type AddToTeamResponse struct {
 	User domain.User `json:"user"`
 }
 
+type GetMemberResponse struct {
+	User domain.User `json:"user"`
+}
+
+// PutMemberRequest is the body of PUT /api/v1/teams/{teamId}/members/{userId}:
+// the member itself. The ID comes from the path and may be left out.
+type PutMemberRequest = domain.User
+
+type PutMemberResponse struct {
+	User domain.User `json:"user"`
+}
+
 type GetTeamRequest struct {
 	TeamID string `json:"team_id"`
 }
@@ -46,10 +72,131 @@ type GetTeamResponse struct {
 	Team domain.Team `json:"team"`
 }
 
+// UpdateTeamRequest changes the given fields of a team,
+// omitted fields stay unchanged
+type UpdateTeamRequest struct {
+	Name *string `json:"name,omitempty"`
+}
+
+type UpdateTeamResponse struct {
+	Team domain.Team `json:"team"`
+}
+
+// DeleteTeamResponse is sent after the team was moved to the trash.
+// Its admin token keeps working for POST /api/v1/teams/{teamId}/restore until the
+// team is purged.
+type DeleteTeamResponse struct {
+}
+
+type RestoreTeamResponse struct {
+	Team domain.Team `json:"team"`
+}
+
 type RemoveFromTeamRequest struct {
 	TeamID string `json:"team_id"`
 	UserID string `json:"user_id"`
 }
 
+// RemoveFromTeamResponse is sent after the user was moved to the
+// trash of the team, see GET /api/v1/teams/{teamId}/trash
 type RemoveFromTeamResponse struct {
 }
+
+// TeamSocketReady is the first message on the team WebSocket, the
+// following ones are domain.Event. A client that reconnects before
+// receiving any event resumes after LastEventID.
+type TeamSocketReady struct {
+	Type        string `json:"type"` // always "ready"
+	LastEventID int64  `json:"last_event_id"`
+}
+
+// ListTrashResponse lists deleted members, last deleted first.
+// They are purged after the retention period of the server.
+type ListTrashResponse struct {
+	Users []domain.DeletedUser `json:"users"`
+}
+
+type RestoreUserResponse struct {
+	User domain.User `json:"user"`
+}
+
+// RotateTokenResponse holds the new secret of a role. The old secret
+// of that role stops working immediately.
+type RotateTokenResponse struct {
+	Token string `json:"token"`
+	// OtherTokens holds the secrets of the other roles by role, issued
+	// with the first secret of a team created before secrets existed.
+	// They are not shown again.
+	OtherTokens map[string]string `json:"other_tokens,omitempty"`
+}
+
+// CreateInviteRequest mints an invite code for the team
+type CreateInviteRequest struct {
+	// Role granted to whoever joins: "viewer" (default) or "admin"
+	Role string `json:"role,omitempty"`
+	// MaxUses limits how many people can join, 0 means unlimited
+	MaxUses int `json:"max_uses,omitempty"`
+	// ExpiresInSeconds limits how long the code works, 0 means forever
+	ExpiresInSeconds int64 `json:"expires_in_seconds,omitempty"`
+}
+
+type CreateInviteResponse struct {
+	Invite domain.Invite `json:"invite"`
+}
+
+type ListInvitesResponse struct {
+	Invites []domain.Invite `json:"invites"`
+}
+
+type RevokeInviteResponse struct {
+}
+
+// JoinByInviteRequest adds the user to the team of the invite code.
+// The code itself is part of the URL.
+type JoinByInviteRequest struct {
+	User domain.User `json:"user"`
+}
+
+type JoinByInviteResponse struct {
+	TeamID string `json:"team_id"`
+	// Role granted by the invite
+	Role string `json:"role"`
+	// Token is a new team secret with that role, keep it
+	Token string      `json:"token"`
+	User  domain.User `json:"user"`
+}
+
+// CreateWebhookRequest registers a URL receiving the team's events
+type CreateWebhookRequest struct {
+	// URL receives a signed POST per event, see webhook.Sign
+	URL string `json:"url"`
+	// Secret signs the deliveries, generated when omitted
+	Secret string `json:"secret,omitempty"`
+	// Events to deliver, all when omitted
+	Events []domain.EventType `json:"events,omitempty"`
+}
+
+// CreateWebhookResponse carries the webhook with its secret,
+// which is not shown again
+type CreateWebhookResponse struct {
+	Webhook domain.Webhook `json:"webhook"`
+}
+
+type ListWebhooksResponse struct {
+	Webhooks []domain.Webhook `json:"webhooks"`
+}
+
+type DeleteWebhookResponse struct {
+}
+
+// ListWebhookDeliveriesResponse lists deliveries of a webhook, newest first
+type ListWebhookDeliveriesResponse struct {
+	Deliveries []domain.WebhookDelivery `json:"deliveries"`
+}
+
+// ListAuditResponse is a page of the audit log, newest first
+type ListAuditResponse struct {
+	Entries []domain.AuditEntry `json:"entries"`
+	// NextCursor is passed as cursor to get the next page, absent on the last one
+	NextCursor int64 `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,266 @@
+// Package openapi builds an OpenAPI 3 document from route descriptions,
+// deriving the schemas of request and response bodies from Go types
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is the OpenAPI version of the generated documents
+const Version = "3.0.3"
+
+// Document is an OpenAPI document, limited to what the API uses
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem holds the operations of a path by lower case method
+type PathItem map[string]*Operation
+
+// Operation is one method of a path
+type Operation struct {
+	OperationID string   `json:"operationId"`
+	Summary     string   `json:"summary,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Deprecated  bool     `json:"deprecated,omitempty"`
+	// Role is the least role of the bearer token the operation needs
+	Role        string                `json:"x-role,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of a request
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response by its status
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas referenced by operations
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how requests are authorized
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Route describes an operation of the API
+type Route struct {
+	Method string
+	// Path is the full path, with path parameters in braces
+	Path        string
+	Summary     string
+	Description string
+	Tag         string
+	Deprecated  bool
+	// Role is the least role of the bearer token, empty for public
+	// operations
+	Role  string
+	Query []Param
+	// Request and Response are values of the body types,
+	// nil when there is no body
+	Request  interface{}
+	Response interface{}
+	// Status of a successful response, 200 when zero
+	Status int
+	// ContentType of a successful response, JSON when empty
+	ContentType string
+}
+
+// Param describes a query parameter
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+	// Type is a JSON schema type, string when empty
+	Type string
+}
+
+// bearerScheme names the security scheme of secured operations
+const bearerScheme = "bearerAuth"
+
+// pathParamPattern matches path parameters like {teamId}
+var pathParamPattern = regexp.MustCompile(`\{([^}/]+)\}`)
+
+// Builder collects routes into a Document
+type Builder struct {
+	doc     *Document
+	schemas *schemaRegistry
+	errors  *Schema
+}
+
+// NewBuilder creates a builder of a document. Failed operations answer
+// with errorResponse, a value of the error body type.
+func NewBuilder(info Info, errorResponse interface{}) *Builder {
+	schemas := newSchemaRegistry()
+	return &Builder{
+		doc: &Document{
+			OpenAPI: Version,
+			Info:    info,
+			Paths:   make(map[string]PathItem),
+			Components: Components{
+				Schemas: schemas.components,
+				SecuritySchemes: map[string]SecurityScheme{
+					bearerScheme: {
+						Type:        "http",
+						Scheme:      "bearer",
+						Description: "Admin or viewer secret of the team",
+					},
+				},
+			},
+		},
+		schemas: schemas,
+		errors:  schemas.schemaOf(errorResponse),
+	}
+}
+
+// Add adds an operation to the document
+func (b *Builder) Add(route Route) {
+	op := &Operation{
+		OperationID: operationID(route.Method, route.Path),
+		Summary:     route.Summary,
+		Description: route.Description,
+		Deprecated:  route.Deprecated,
+		Role:        route.Role,
+		Responses:   make(map[string]Response),
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+
+	for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	for _, query := range route.Query {
+		schemaType := query.Type
+		if schemaType == "" {
+			schemaType = "string"
+		}
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:        query.Name,
+			In:          "query",
+			Description: query.Description,
+			Required:    query.Required,
+			Schema:      &Schema{Type: schemaType},
+		})
+	}
+
+	if route.Role != "" {
+		op.Security = []map[string][]string{{bearerScheme: {}}}
+	}
+
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				"application/json": {Schema: b.schemas.schemaOf(route.Request)},
+			},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	if route.Response != nil {
+		contentType := route.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		success.Content = map[string]MediaType{
+			contentType: {Schema: b.schemas.schemaOf(route.Response)},
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+
+	op.Responses["default"] = Response{
+		Description: "Error",
+		Content: map[string]MediaType{
+			"application/json": {Schema: b.errors},
+		},
+	}
+
+	item, ok := b.doc.Paths[route.Path]
+	if !ok {
+		item = make(PathItem)
+		b.doc.Paths[route.Path] = item
+	}
+	item[strings.ToLower(route.Method)] = op
+}
+
+// Document returns the document with all added operations
+func (b *Builder) Document() *Document {
+	return b.doc
+}
+
+// Operations lists the method and path of every operation, sorted
+func (d *Document) Operations() []string {
+	var result []string
+	for path, item := range d.Paths {
+		for method := range item {
+			result = append(result, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// operationID derives a stable ID like getApiV1TeamsTeamIdMembersUserId
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '.' || r == '_' || r == '-'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
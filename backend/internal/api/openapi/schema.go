@@ -0,0 +1,182 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON schema as used by OpenAPI 3.0
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+}
+
+// refPrefix starts the references to component schemas
+const refPrefix = "#/components/schemas/"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaRegistry derives schemas from Go types. Named structs become
+// components and are referenced, everything else is inlined.
+type schemaRegistry struct {
+	components map[string]*Schema
+	// names maps struct types to their component names
+	names map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// schemaOf returns the schema of the type of v
+func (r *schemaRegistry) schemaOf(v interface{}) *Schema {
+	return r.schema(reflect.TypeOf(v))
+}
+
+// schema returns the schema of t as encoding/json marshals it
+func (r *schemaRegistry) schema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{} // any JSON value
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := r.schema(t.Elem())
+		if s.Ref != "" {
+			// Siblings of $ref are ignored in OpenAPI 3.0
+			return s
+		}
+		s.Nullable = true
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		// A nil slice is marshaled as null
+		return &Schema{Type: "array", Items: r.schema(t.Elem()), Nullable: true}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: r.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		return r.structRef(t)
+	default:
+		return &Schema{} // interfaces hold any value
+	}
+}
+
+// structRef registers a struct as a component and returns a reference
+// to it. Anonymous structs are inlined.
+func (r *schemaRegistry) structRef(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return r.structSchema(t)
+	}
+
+	name, ok := r.names[t]
+	if !ok {
+		name = r.componentName(t)
+		r.names[t] = name
+		// Registered before the fields, so recursive types terminate
+		r.components[name] = &Schema{}
+		*r.components[name] = *r.structSchema(t)
+	}
+
+	return &Schema{Ref: refPrefix + name}
+}
+
+// componentName names a struct by its type name, qualified by its
+// package when another package already uses the name
+func (r *schemaRegistry) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := r.components[name]; !taken {
+		return name
+	}
+
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + name
+}
+
+// structSchema describes the fields of a struct. Fields without
+// omitempty are always marshaled and therefore required.
+func (r *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	r.addFields(s, t)
+	return s
+}
+
+// addFields adds the fields of t to s, flattening embedded structs
+func (r *schemaRegistry) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				r.addFields(s, embedded)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		s.Properties[name] = r.schema(field.Type)
+		if !hasOption(options, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// hasOption reports whether a json tag lists the option
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
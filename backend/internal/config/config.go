@@ -0,0 +1,338 @@
+// Package config resolves the configuration of the server from defaults,
+// a YAML file, environment variables and command-line flags, see Load.
+// YAML is the only file format, TOML is not supported.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/db"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/tracing"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/webhook"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase/team"
+	"github.com/kvloginov/cup-of-team/backend/internal/worker"
+)
+
+// Config is the configuration of the server. Every setting has a key in
+// the YAML file, an environment variable (the env tag) and a flag named
+// after its key, like --server.port.
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Database  Database  `yaml:"database"`
+	Log       Log       `yaml:"log"`
+	Tracing   Tracing   `yaml:"tracing"`
+	CORS      CORS      `yaml:"cors"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	Workers   Workers   `yaml:"workers"`
+	Webhooks  Webhooks  `yaml:"webhooks"`
+}
+
+// Server configures the HTTP server
+type Server struct {
+	Port              int           `yaml:"port" env:"PORT" usage:"port to listen on"`
+	FrontendPath      string        `yaml:"frontend_path" env:"FRONTEND_PATH" usage:"directory of the built frontend"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" usage:"time to read request headers"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" usage:"time to read a request"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" usage:"time to write a response, streams excluded"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" usage:"time to keep idle connections"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"time to finish requests on shutdown"`
+}
+
+// Database configures the storage
+type Database struct {
+	Driver string `yaml:"driver" env:"DB_DRIVER" usage:"sqlite, postgres or memory"`
+	// Path is the SQLite file, used when DSN is empty
+	Path          string        `yaml:"path" env:"DB_PATH" usage:"SQLite database file"`
+	DSN           string        `yaml:"dsn" env:"DB_DSN" usage:"data source name, overrides the path"`
+	MigrationMode string        `yaml:"migration_mode" env:"DB_MIGRATION_MODE" usage:"up or check"`
+	QueryTimeout  time.Duration `yaml:"query_timeout" env:"DB_QUERY_TIMEOUT" usage:"time a query may run, negative for no bound"`
+}
+
+// Log configures the structured logger
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" usage:"debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" usage:"json or text"`
+}
+
+// Tracing configures the export of spans
+type Tracing struct {
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" usage:"none, otlp or stdout"`
+	Endpoint string `yaml:"endpoint" env:"TRACING_ENDPOINT" usage:"host:port of the OTLP collector"`
+	Insecure bool   `yaml:"insecure" env:"TRACING_INSECURE" usage:"send spans over plain HTTP"`
+}
+
+// CORS configures requests from other origins, see http.CORSConfig
+type CORS struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" usage:"origin patterns allowed to call the API"`
+	AllowedMethods   []string      `yaml:"allowed_methods" env:"CORS_ALLOWED_METHODS" usage:"methods allowed in preflights"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS" usage:"request headers allowed in preflights"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" env:"CORS_EXPOSED_HEADERS" usage:"response headers clients may read"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" usage:"allow cookies and client certificates"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE" usage:"time browsers cache preflights"`
+}
+
+// RateLimit configures the limits of API requests, see http.RateLimitConfig
+type RateLimit struct {
+	Enabled         bool          `yaml:"enabled" env:"RATE_LIMIT_ENABLED" usage:"limit the API requests of clients and teams"`
+	IPPerMinute     int           `yaml:"ip_per_minute" env:"RATE_LIMIT_IP_PER_MINUTE" usage:"requests per minute of a client IP"`
+	IPBurst         int           `yaml:"ip_burst" env:"RATE_LIMIT_IP_BURST" usage:"requests a client IP may send at once"`
+	TeamPerMinute   int           `yaml:"team_per_minute" env:"RATE_LIMIT_TEAM_PER_MINUTE" usage:"requests per minute to a team"`
+	TeamBurst       int           `yaml:"team_burst" env:"RATE_LIMIT_TEAM_BURST" usage:"requests a team may receive at once"`
+	FailuresPerHour int           `yaml:"failures_per_hour" env:"RATE_LIMIT_FAILURES_PER_HOUR" usage:"401, 403 and 404 responses per hour of a client IP"`
+	FailureBurst    int           `yaml:"failure_burst" env:"RATE_LIMIT_FAILURE_BURST" usage:"401, 403 and 404 responses a client IP may get at once"`
+	Backoff         time.Duration `yaml:"backoff" env:"RATE_LIMIT_BACKOFF" usage:"first block of a client over the failures, then doubled"`
+	MaxBackoff      time.Duration `yaml:"max_backoff" env:"RATE_LIMIT_MAX_BACKOFF" usage:"longest block of a client"`
+	TrustProxy      bool          `yaml:"trust_proxy" env:"RATE_LIMIT_TRUST_PROXY" usage:"take client IPs from X-Forwarded-For"`
+}
+
+// Workers configures the background workers
+type Workers struct {
+	PurgeInterval   time.Duration `yaml:"purge_interval" env:"PURGE_INTERVAL" usage:"time between emptying the trash"`
+	PurgeRetention  time.Duration `yaml:"purge_retention" env:"PURGE_RETENTION" usage:"time deleted teams and members are kept"`
+	WebhookInterval time.Duration `yaml:"webhook_interval" env:"WEBHOOK_INTERVAL" usage:"time between sending webhook deliveries"`
+}
+
+// Webhooks configures the delivery of webhooks
+type Webhooks struct {
+	Timeout     time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT" usage:"time a delivery attempt may take"`
+	MaxAttempts int           `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" usage:"attempts before a delivery fails"`
+	BaseDelay   time.Duration `yaml:"base_delay" env:"WEBHOOK_BASE_DELAY" usage:"wait after the first failed attempt"`
+	MaxDelay    time.Duration `yaml:"max_delay" env:"WEBHOOK_MAX_DELAY" usage:"longest wait between attempts"`
+	// AllowedNetworks are internal networks webhooks may target anyway,
+	// for local development only
+	AllowedNetworks []string `yaml:"allowed_networks" env:"WEBHOOK_ALLOWED_NETWORKS" usage:"internal networks webhooks may target, like 127.0.0.0/8"`
+}
+
+// Default returns the configuration used for everything not set
+func Default() Config {
+	return Config{
+		Server: Server{
+			Port:              8080,
+			FrontendPath:      http.DefaultFrontendPath,
+			ReadHeaderTimeout: http.DefaultReadHeaderTimeout,
+			ReadTimeout:       http.DefaultReadTimeout,
+			WriteTimeout:      http.DefaultWriteTimeout,
+			IdleTimeout:       http.DefaultIdleTimeout,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Database: Database{
+			Driver:        string(db.DriverSQLite),
+			Path:          "db/cup-of-team.db",
+			MigrationMode: string(db.MigrateUp),
+			QueryTimeout:  db.DefaultQueryTimeout,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: logging.FormatJSON,
+		},
+		Tracing: Tracing{
+			Exporter: tracing.ExporterNone,
+		},
+		CORS: CORS{
+			AllowedOrigins: clone(http.DefaultCORSOrigins),
+			AllowedMethods: clone(http.DefaultCORSMethods),
+			AllowedHeaders: clone(http.DefaultCORSHeaders),
+			ExposedHeaders: clone(http.DefaultCORSExposedHeaders),
+			MaxAge:         http.DefaultCORSMaxAge,
+		},
+		RateLimit: RateLimit{
+			Enabled:         true,
+			IPPerMinute:     http.DefaultRateLimitIPPerMinute,
+			IPBurst:         http.DefaultRateLimitIPBurst,
+			TeamPerMinute:   http.DefaultRateLimitTeamPerMinute,
+			TeamBurst:       http.DefaultRateLimitTeamBurst,
+			FailuresPerHour: http.DefaultRateLimitFailuresPerHour,
+			FailureBurst:    http.DefaultRateLimitFailureBurst,
+			Backoff:         http.DefaultRateLimitBackoff,
+			MaxBackoff:      http.DefaultRateLimitMaxBackoff,
+		},
+		Workers: Workers{
+			PurgeInterval:   time.Hour,
+			PurgeRetention:  30 * 24 * time.Hour,
+			WebhookInterval: 2 * time.Second,
+		},
+		Webhooks: Webhooks{
+			Timeout:         team.DefaultWebhookTimeout,
+			MaxAttempts:     team.DefaultWebhookRetry.MaxAttempts,
+			BaseDelay:       team.DefaultWebhookRetry.BaseDelay,
+			MaxDelay:        team.DefaultWebhookRetry.MaxDelay,
+			AllowedNetworks: []string{},
+		},
+	}
+}
+
+// Validate reports every invalid setting at once
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	positive := func(key string, d time.Duration) {
+		check(d > 0, "%s must be positive, like 30s, got %s", key, d)
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port must be between 1 and 65535, got %d", c.Server.Port)
+	positive("server.read_header_timeout", c.Server.ReadHeaderTimeout)
+	positive("server.read_timeout", c.Server.ReadTimeout)
+	positive("server.write_timeout", c.Server.WriteTimeout)
+	positive("server.idle_timeout", c.Server.IdleTimeout)
+	positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+
+	switch db.Driver(c.Database.Driver) {
+	case db.DriverSQLite:
+		check(c.Database.DSN != "" || c.Database.Path != "", "database.path or database.dsn is required for sqlite")
+	case db.DriverPostgres:
+		check(c.Database.DSN != "", "database.dsn is required for postgres")
+	case db.DriverMemory:
+	default:
+		check(false, "database.driver must be sqlite, postgres or memory, got %q", c.Database.Driver)
+	}
+	switch db.MigrationMode(c.Database.MigrationMode) {
+	case db.MigrateUp, db.MigrateCheck:
+	default:
+		check(false, "database.migration_mode must be up or check, got %q", c.Database.MigrationMode)
+	}
+	// Zero would silently mean the default of the db package
+	check(c.Database.QueryTimeout != 0, "database.query_timeout must be positive, or negative for no bound, got %s", c.Database.QueryTimeout)
+
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case logging.FormatJSON, logging.FormatText:
+	default:
+		check(false, "log.format must be json or text, got %q", c.Log.Format)
+	}
+
+	switch strings.ToLower(c.Tracing.Exporter) {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout:
+	default:
+		check(false, "tracing.exporter must be none, otlp or stdout, got %q", c.Tracing.Exporter)
+	}
+
+	if err := c.HTTPConfig().CORS.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("cors: %w", err))
+	}
+	if err := c.HTTPConfig().RateLimit.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("rate_limit: %w", err))
+	}
+
+	positive("workers.purge_interval", c.Workers.PurgeInterval)
+	positive("workers.purge_retention", c.Workers.PurgeRetention)
+	positive("workers.webhook_interval", c.Workers.WebhookInterval)
+
+	positive("webhooks.timeout", c.Webhooks.Timeout)
+	check(c.Webhooks.Timeout < team.DeliveryLease, "webhooks.timeout must be shorter than %s, got %s", team.DeliveryLease, c.Webhooks.Timeout)
+	check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts must be positive, got %d", c.Webhooks.MaxAttempts)
+	positive("webhooks.base_delay", c.Webhooks.BaseDelay)
+	check(c.Webhooks.MaxDelay >= c.Webhooks.BaseDelay, "webhooks.max_delay must not be below webhooks.base_delay")
+	if _, err := webhook.ParseAllowlist(c.Webhooks.AllowedNetworks); err != nil {
+		errs = append(errs, fmt.Errorf("webhooks.allowed_networks: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// HTTPConfig returns the configuration of the HTTP server. The logger and the
+// metrics registry are left for the caller to set.
+func (c Config) HTTPConfig() http.Config {
+	return http.Config{
+		Port:              fmt.Sprintf(":%d", c.Server.Port),
+		FrontendPath:      c.Server.FrontendPath,
+		ReadHeaderTimeout: c.Server.ReadHeaderTimeout,
+		ReadTimeout:       c.Server.ReadTimeout,
+		WriteTimeout:      c.Server.WriteTimeout,
+		IdleTimeout:       c.Server.IdleTimeout,
+		CORS: http.CORSConfig{
+			AllowedOrigins:   c.CORS.AllowedOrigins,
+			AllowedMethods:   c.CORS.AllowedMethods,
+			AllowedHeaders:   c.CORS.AllowedHeaders,
+			ExposedHeaders:   c.CORS.ExposedHeaders,
+			AllowCredentials: c.CORS.AllowCredentials,
+			MaxAge:           c.CORS.MaxAge,
+		},
+		RateLimit: http.RateLimitConfig{
+			Enabled:         c.RateLimit.Enabled,
+			IPPerMinute:     c.RateLimit.IPPerMinute,
+			IPBurst:         c.RateLimit.IPBurst,
+			TeamPerMinute:   c.RateLimit.TeamPerMinute,
+			TeamBurst:       c.RateLimit.TeamBurst,
+			FailuresPerHour: c.RateLimit.FailuresPerHour,
+			FailureBurst:    c.RateLimit.FailureBurst,
+			Backoff:         c.RateLimit.Backoff,
+			MaxBackoff:      c.RateLimit.MaxBackoff,
+			TrustProxy:      c.RateLimit.TrustProxy,
+		},
+	}
+}
+
+// DBConfig returns the configuration of the database, the SQLite file as the
+// DSN unless one is set
+func (c Config) DBConfig() db.Config {
+	dsn := c.Database.DSN
+	if dsn == "" && db.Driver(c.Database.Driver) == db.DriverSQLite {
+		dsn = c.Database.Path
+	}
+
+	return db.Config{
+		Driver:        db.Driver(c.Database.Driver),
+		DSN:           dsn,
+		MigrationMode: db.MigrationMode(c.Database.MigrationMode),
+		QueryTimeout:  c.Database.QueryTimeout,
+	}
+}
+
+// LoggingConfig returns the configuration of the logger
+func (c Config) LoggingConfig() logging.Config {
+	return logging.Config{Level: c.Log.Level, Format: c.Log.Format}
+}
+
+// TracingConfig returns the configuration of the span export
+func (c Config) TracingConfig() tracing.Config {
+	return tracing.Config{
+		Exporter: c.Tracing.Exporter,
+		Endpoint: c.Tracing.Endpoint,
+		Insecure: c.Tracing.Insecure,
+	}
+}
+
+// PurgeConfig returns the configuration of the purge worker
+func (c Config) PurgeConfig() worker.PurgeConfig {
+	return worker.PurgeConfig{
+		Interval:  c.Workers.PurgeInterval,
+		Retention: c.Workers.PurgeRetention,
+	}
+}
+
+// WebhookConfig returns the configuration of the webhook worker
+func (c Config) WebhookConfig() worker.WebhookConfig {
+	return worker.WebhookConfig{Interval: c.Workers.WebhookInterval}
+}
+
+// WebhookRetry returns the backoff of failed webhook deliveries
+func (c Config) WebhookRetry() team.WebhookRetry {
+	return team.WebhookRetry{
+		MaxAttempts: c.Webhooks.MaxAttempts,
+		BaseDelay:   c.Webhooks.BaseDelay,
+		MaxDelay:    c.Webhooks.MaxDelay,
+	}
+}
+
+// WebhookAllowlist returns the internal networks webhooks may target. The
+// configuration must be valid.
+func (c Config) WebhookAllowlist() webhook.Allowlist {
+	allowlist, _ := webhook.ParseAllowlist(c.Webhooks.AllowedNetworks)
+	return allowlist
+}
+
+// clone copies a list of defaults, so that they are never changed
+func clone(list []string) []string {
+	return append([]string(nil), list...)
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the variable holding the path of the configuration file,
+// used unless the --config flag is given
+const FileEnv = "CONFIG_FILE"
+
+// Options are the flags about the configuration itself
+type Options struct {
+	// File is the YAML file that was read, empty when none
+	File string
+	// PrintConfig asks to print the resolved configuration and exit
+	PrintConfig bool
+}
+
+// Load resolves the configuration from, in increasing precedence, the
+// defaults, the YAML file, the environment and the flags in args. Empty
+// variables count as unset. Lists are comma separated in variables and
+// flags. The result is not validated, see Config.Validate. For -h and
+// --help the usage is written to output and flag.ErrHelp is returned.
+func Load(args []string, lookupEnv func(string) (string, bool), output io.Writer) (Config, Options, error) {
+	config := Default()
+	settings := settingsOf(&config)
+
+	var options Options
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&options.File, "config", "", "YAML configuration file, also set by "+FileEnv)
+	flags.BoolVar(&options.PrintConfig, "print-config", false, "print the resolved configuration and exit")
+
+	// Flags are applied last, but parsed first to find the file
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		key, usage := s.key, fmt.Sprintf("%s (%s)", s.usage, s.env)
+		collect := func(value string) error {
+			flagValues[key] = value
+			return nil
+		}
+		if s.value.Kind() == reflect.Bool {
+			flags.BoolFunc(key, usage, collect)
+		} else {
+			flags.Func(key, usage, collect)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return config, options, err
+	}
+	if flags.NArg() > 0 {
+		return config, options, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	if options.File == "" {
+		options.File, _ = lookupEnv(FileEnv)
+	}
+	if options.File != "" {
+		if err := readFile(options.File, &config); err != nil {
+			return config, options, err
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.env); ok && value != "" {
+			if err := s.set(value); err != nil {
+				return config, options, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := flagValues[s.key]; ok {
+			if err := s.set(value); err != nil {
+				return config, options, fmt.Errorf("invalid --%s: %w", s.key, err)
+			}
+		}
+	}
+
+	return config, options, nil
+}
+
+// readFile decodes a YAML file into config. Unknown keys are errors, so
+// that typos do not go unnoticed.
+func readFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Write writes the configuration as YAML, in the format Load reads.
+// Passwords in the database DSN are masked.
+func (c Config) Write(w io.Writer) error {
+	c.Database.DSN = redactDSN(c.Database.DSN)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// dsnPassword finds the password of a key=value DSN
+var dsnPassword = regexp.MustCompile(`(password=)('[^']*'|\S*)`)
+
+// redactDSN masks the password of a URL or key=value DSN
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+			return u.String()
+		}
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}xxxxx")
+}
+
+// setting is a single value of the configuration
+type setting struct {
+	// key is the path in the YAML file and the name of the flag,
+	// like server.port
+	key   string
+	env   string
+	usage string
+	value reflect.Value
+}
+
+// settingsOf lists the settings of config, in the order of its fields
+func settingsOf(config *Config) []setting {
+	var settings []setting
+	sections := reflect.ValueOf(config).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		prefix := sections.Type().Field(i).Tag.Get("yaml")
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Type().Field(j)
+			settings = append(settings, setting{
+				key:   prefix + "." + field.Tag.Get("yaml"),
+				env:   field.Tag.Get("env"),
+				usage: field.Tag.Get("usage"),
+				value: section.Field(j),
+			})
+		}
+	}
+	return settings
+}
+
+// durationType is set from strings like 30s rather than as an integer
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses a value given as text
+func (s setting) set(text string) error {
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("expected a duration like 30s or 720h, got %q", text)
+		}
+		s.value.SetInt(int64(d))
+
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(text)
+
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("expected a number, got %q", text)
+		}
+		s.value.SetInt(int64(n))
+
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", text)
+		}
+		s.value.SetBool(b)
+
+	case s.value.Kind() == reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(list))
+
+	default:
+		panic("config: unsupported setting type " + s.value.Type().String())
+	}
+	return nil
+}
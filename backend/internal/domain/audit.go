@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditAction names a change recorded in the audit log
+type AuditAction string
+
+const (
+	AuditTeamCreated    AuditAction = "team.created"
+	AuditTeamRenamed    AuditAction = "team.renamed"
+	AuditTeamDeleted    AuditAction = "team.deleted"
+	AuditTeamRestored   AuditAction = "team.restored"
+	AuditMemberAdded    AuditAction = "member.added"
+	AuditMemberUpdated  AuditAction = "member.updated"
+	AuditMemberRemoved  AuditAction = "member.removed"
+	AuditMemberRestored AuditAction = "member.restored"
+	AuditTokenRotated   AuditAction = "token.rotated"
+	AuditInviteCreated  AuditAction = "invite.created"
+	AuditInviteRevoked  AuditAction = "invite.revoked"
+	AuditWebhookCreated AuditAction = "webhook.created"
+	AuditWebhookDeleted AuditAction = "webhook.deleted"
+)
+
+// ActorType tells how an actor proved its access
+type ActorType string
+
+const (
+	// ActorToken acted with a team secret
+	ActorToken ActorType = "token"
+	// ActorInvite joined with an invite code
+	ActorInvite ActorType = "invite"
+	// ActorAnonymous needed no access, e.g. to create a team
+	ActorAnonymous ActorType = "anonymous"
+)
+
+// Actor is who made a change. Team secrets are shared, so an actor is
+// identified by the secret it used, never by a person.
+type Actor struct {
+	Type ActorType `json:"type"`
+	Role Role      `json:"role,omitempty"`
+	// ID is the fingerprint of the secret or the invite code
+	ID string `json:"id,omitempty"`
+}
+
+// RequestInfo describes the request that made a change
+type RequestInfo struct {
+	RequestID string `json:"request_id,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// AuditEntry records one change of a team
+type AuditEntry struct {
+	ID     int64       `json:"id"`
+	TeamID string      `json:"team_id"`
+	Action AuditAction `json:"action"`
+	Actor  Actor       `json:"actor"`
+	// TargetType is team, member, token, invite or webhook
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	// Before and After are snapshots of the target, a User for members.
+	// Secrets are never included.
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	Request   RequestInfo     `json:"request"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 type User struct {
 	ID                string   `json:"id"`
 	FirstName         string   `json:"first_name"`
@@ -7,10 +9,109 @@ type User struct {
 	ParentNames       []string `json:"parent_names"`       // list of parent names, no more than 2
 	GrandParentsNames []string `json:"grandparents_names"` // list of grandparent names, no more than 4
 	Country           string   `json:"country"`
+	// Version increases with every change of the user. Send back the
+	// version you last saw to reject the write if someone changed the
+	// user since, 0 overwrites blindly.
+	Version int64 `json:"version"`
 }
 
 type Team struct {
 	ID    string `json:"id"`
 	Name  string `json:"name"`
 	Users []User `json:"users"`
+	// Version increases with every change of the team or its users,
+	// GET /api/team sends it as the ETag
+	Version int64 `json:"version"`
+}
+
+// DeletedUser is a member in the trash of a team
+type DeletedUser struct {
+	User      User      `json:"user"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// Role is the access level granted by a team secret
+type Role string
+
+const (
+	// RoleViewer can read the team
+	RoleViewer Role = "viewer"
+	// RoleAdmin can read and change the team
+	RoleAdmin Role = "admin"
+)
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	return r == RoleViewer || r == RoleAdmin
+}
+
+// Allows reports whether r grants at least the required role
+func (r Role) Allows(required Role) bool {
+	switch required {
+	case RoleViewer:
+		return r == RoleViewer || r == RoleAdmin
+	case RoleAdmin:
+		return r == RoleAdmin
+	default:
+		return false
+	}
+}
+
+// Access is what a presented secret grants
+type Access struct {
+	TeamID string
+	Role   Role
+	// TokenID tells secrets apart without revealing them
+	TokenID string
+}
+
+// Invite is a shareable code to join a team
+type Invite struct {
+	Code      string     `json:"code"`
+	TeamID    string     `json:"team_id"`
+	Role      Role       `json:"role"`               // role granted to whoever joins
+	MaxUses   int        `json:"max_uses,omitempty"` // 0 means unlimited
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil means never
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Webhook receives the events of a team as signed HTTP POST requests
+type Webhook struct {
+	ID     string `json:"id"`
+	TeamID string `json:"team_id"`
+	URL    string `json:"url"`
+	// Events filters what is delivered, empty means all event types
+	Events []EventType `json:"events"`
+	// Secret signs the deliveries. It is only shown on creation.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DeliveryStatus is the state of a webhook delivery
+type DeliveryStatus string
+
+const (
+	// DeliveryPending is waiting for its first attempt or a retry
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryDelivered was accepted by the receiver
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryFailed ran out of attempts
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// WebhookDelivery is one event sent to a webhook
+type WebhookDelivery struct {
+	ID        int64          `json:"id"`
+	WebhookID string         `json:"webhook_id"`
+	EventID   int64          `json:"event_id"`
+	EventType EventType      `json:"event_type"`
+	Status    DeliveryStatus `json:"status"`
+	Attempts  int            `json:"attempts"`
+	// NextAttemptAt is set while the delivery is pending
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	ResponseStatus int        `json:"response_status,omitempty"` // HTTP status of the last attempt
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
 }
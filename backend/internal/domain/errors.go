@@ -0,0 +1,72 @@
+package domain
+
+import "errors"
+
+// Error kinds. Every Error unwraps to one of them, so callers can check
+// the category with errors.Is(err, ErrNotFound) without knowing the
+// specific error.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrForbidden    = errors.New("forbidden")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrExpired      = errors.New("expired")
+	// ErrPrecondition means the client's copy is stale, e.g. a version mismatch
+	ErrPrecondition = errors.New("precondition failed")
+)
+
+// Error is an expected failure with a machine-readable code,
+// e.g. "team_not_found". Its message is safe to show to clients.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	// Fields lists every invalid field of a validation error
+	Fields []FieldError
+}
+
+// Error returns the message
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the kind
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// NotFound creates an error of kind ErrNotFound
+func NotFound(code, message string) *Error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+// Conflict creates an error of kind ErrConflict
+func Conflict(code, message string) *Error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
+
+// Validation creates an error of kind ErrValidation
+func Validation(code, message string) *Error {
+	return &Error{Kind: ErrValidation, Code: code, Message: message}
+}
+
+// Forbidden creates an error of kind ErrForbidden
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: ErrForbidden, Code: code, Message: message}
+}
+
+// Unauthorized creates an error of kind ErrUnauthorized
+func Unauthorized(code, message string) *Error {
+	return &Error{Kind: ErrUnauthorized, Code: code, Message: message}
+}
+
+// Expired creates an error of kind ErrExpired
+func Expired(code, message string) *Error {
+	return &Error{Kind: ErrExpired, Code: code, Message: message}
+}
+
+// PreconditionFailed creates an error of kind ErrPrecondition
+func PreconditionFailed(code, message string) *Error {
+	return &Error{Kind: ErrPrecondition, Code: code, Message: message}
+}
@@ -0,0 +1,49 @@
+package domain
+
+import "time"
+
+// EventType names a change of a team
+type EventType string
+
+const (
+	// EventMemberAdded is sent when a user joins the team or is restored from the trash
+	EventMemberAdded EventType = "member_added"
+	// EventMemberUpdated is sent when a member is changed
+	EventMemberUpdated EventType = "member_updated"
+	// EventMemberRemoved is sent when a member is moved to the trash
+	EventMemberRemoved EventType = "member_removed"
+	// EventTeamRenamed is sent when the team gets a new name
+	EventTeamRenamed EventType = "team_renamed"
+	// EventTeamDeleted is sent when the team is moved to the trash
+	EventTeamDeleted EventType = "team_deleted"
+	// EventTeamRestored is sent when the team is restored from the trash
+	EventTeamRestored EventType = "team_restored"
+)
+
+// Valid reports whether t is a known event type
+func (t EventType) Valid() bool {
+	switch t {
+	case EventMemberAdded, EventMemberUpdated, EventMemberRemoved,
+		EventTeamRenamed, EventTeamDeleted, EventTeamRestored:
+		return true
+	default:
+		return false
+	}
+}
+
+// Event is a change of a team, as streamed to subscribers
+type Event struct {
+	// ID increases with every event, clients resume after the last ID they saw
+	ID     int64     `json:"id"`
+	TeamID string    `json:"team_id"`
+	Type   EventType `json:"type"`
+	// Version is the team version after the change
+	Version int64 `json:"version"`
+	// UserID is set for member events
+	UserID string `json:"user_id,omitempty"`
+	// User is the member after the change, set for added and updated members
+	User *User `json:"user,omitempty"`
+	// Name is the new team name, set for renames
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
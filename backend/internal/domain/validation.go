@@ -0,0 +1,217 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits of user fields
+const (
+	MaxUserIDLength      = 64
+	MaxNameLength        = 50
+	MaxInitialsLength    = 4
+	MaxParentNames       = 2
+	MaxGrandParentsNames = 4
+	MaxTeamNameLength    = 100
+)
+
+// Limits of webhook fields
+const (
+	MaxWebhookURLLength    = 2048
+	MinWebhookSecretLength = 16
+	MaxWebhookSecretLength = 256
+)
+
+// FieldError describes one invalid field, e.g. "parent_names[1]"
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Normalize trims all fields and upper-cases initials and country,
+// so that equal input is stored the same way
+func (u *User) Normalize() {
+	u.ID = strings.TrimSpace(u.ID)
+	u.FirstName = strings.TrimSpace(u.FirstName)
+	u.Initials = strings.ToUpper(strings.TrimSpace(u.Initials))
+	u.Country = strings.ToUpper(strings.TrimSpace(u.Country))
+	u.ParentNames = trimNames(u.ParentNames)
+	u.GrandParentsNames = trimNames(u.GrandParentsNames)
+}
+
+// Validate checks a normalized user and reports all invalid fields at once.
+// Initials and country are optional; initials must start with the first
+// letter of the first name, the country is an ISO 3166-1 alpha-2 code.
+func (u User) Validate() error {
+	var fields []FieldError
+	add := func(field, code, format string, args ...interface{}) {
+		fields = append(fields, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case u.ID == "":
+		add("id", "required", "id is required")
+	case len(u.ID) > MaxUserIDLength:
+		add("id", "too_long", "id must be at most %d bytes", MaxUserIDLength)
+	}
+
+	if u.Version < 0 {
+		add("version", "invalid", "version must not be negative")
+	}
+
+	switch {
+	case u.FirstName == "":
+		add("first_name", "required", "first_name is required")
+	case utf8.RuneCountInString(u.FirstName) > MaxNameLength:
+		add("first_name", "too_long", "first_name must be at most %d characters", MaxNameLength)
+	}
+
+	if u.Initials != "" {
+		first, _ := utf8.DecodeRuneInString(u.FirstName)
+		initial, _ := utf8.DecodeRuneInString(u.Initials)
+
+		switch {
+		case utf8.RuneCountInString(u.Initials) > MaxInitialsLength:
+			add("initials", "too_long", "initials must be at most %d characters", MaxInitialsLength)
+		case strings.IndexFunc(u.Initials, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0:
+			add("initials", "invalid", "initials must only contain letters")
+		case u.FirstName != "" && unicode.ToUpper(first) != initial:
+			add("initials", "mismatch", "initials must start with the first letter of first_name")
+		}
+	}
+
+	validateNames(add, "parent_names", u.ParentNames, MaxParentNames)
+	validateNames(add, "grandparents_names", u.GrandParentsNames, MaxGrandParentsNames)
+
+	if u.Country != "" && !ValidCountry(u.Country) {
+		add("country", "invalid", "country must be an ISO 3166-1 alpha-2 code, e.g. US")
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return &Error{
+		Kind:    ErrValidation,
+		Code:    "invalid_user",
+		Message: "user is invalid",
+		Fields:  fields,
+	}
+}
+
+// ValidateTeamName checks a trimmed team name
+func ValidateTeamName(name string) error {
+	var field *FieldError
+	switch {
+	case name == "":
+		field = &FieldError{Field: "name", Code: "required", Message: "name is required"}
+	case utf8.RuneCountInString(name) > MaxTeamNameLength:
+		field = &FieldError{Field: "name", Code: "too_long", Message: fmt.Sprintf("name must be at most %d characters", MaxTeamNameLength)}
+	default:
+		return nil
+	}
+
+	return &Error{
+		Kind:    ErrValidation,
+		Code:    "invalid_team",
+		Message: "team is invalid",
+		Fields:  []FieldError{*field},
+	}
+}
+
+// Validate checks a webhook and reports all invalid fields at once.
+// The URL must be absolute http(s), an empty secret is generated by the
+// server.
+func (w Webhook) Validate() error {
+	var fields []FieldError
+	add := func(field, code, format string, args ...interface{}) {
+		fields = append(fields, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case w.URL == "":
+		add("url", "required", "url is required")
+	case len(w.URL) > MaxWebhookURLLength:
+		add("url", "too_long", "url must be at most %d bytes", MaxWebhookURLLength)
+	default:
+		parsed, err := url.Parse(w.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			add("url", "invalid", "url must be an absolute http or https URL")
+		}
+	}
+
+	switch {
+	case w.Secret == "":
+	case len(w.Secret) < MinWebhookSecretLength:
+		add("secret", "too_short", "secret must be at least %d bytes", MinWebhookSecretLength)
+	case len(w.Secret) > MaxWebhookSecretLength:
+		add("secret", "too_long", "secret must be at most %d bytes", MaxWebhookSecretLength)
+	}
+
+	for i, event := range w.Events {
+		if !event.Valid() {
+			entry := fmt.Sprintf("events[%d]", i)
+			add(entry, "invalid", "%s is not a known event type", entry)
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return &Error{
+		Kind:    ErrValidation,
+		Code:    "invalid_webhook",
+		Message: "webhook is invalid",
+		Fields:  fields,
+	}
+}
+
+// ForbiddenWebhookURL is the validation error of a webhook URL in an
+// internal network, like loopback, private or link-local addresses
+func ForbiddenWebhookURL() error {
+	return &Error{
+		Kind:    ErrValidation,
+		Code:    "invalid_webhook",
+		Message: "webhook is invalid",
+		Fields: []FieldError{{
+			Field:   "url",
+			Code:    "forbidden",
+			Message: "url must not point to an internal network",
+		}},
+	}
+}
+
+// validateNames checks the number and the length of names in a list
+func validateNames(add func(field, code, format string, args ...interface{}), field string, names []string, max int) {
+	if len(names) > max {
+		add(field, "too_many", "%s must have at most %d entries", field, max)
+	}
+
+	for i, name := range names {
+		entry := fmt.Sprintf("%s[%d]", field, i)
+		switch {
+		case name == "":
+			add(entry, "required", "%s must not be empty", entry)
+		case utf8.RuneCountInString(name) > MaxNameLength:
+			add(entry, "too_long", "%s must be at most %d characters", entry, MaxNameLength)
+		}
+	}
+}
+
+// trimNames trims every name, keeping nil as nil
+func trimNames(names []string) []string {
+	if names == nil {
+		return nil
+	}
+
+	trimmed := make([]string, len(names))
+	for i, name := range names {
+		trimmed[i] = strings.TrimSpace(name)
+	}
+	return trimmed
+}
@@ -1,59 +1,204 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// Driver is the storage backend selected by configuration
+type Driver string
+
+const (
+	DriverSQLite   Driver = "sqlite"
+	DriverPostgres Driver = "postgres"
+	// DriverMemory keeps everything in process memory, no database is opened
+	DriverMemory Driver = "memory"
+)
+
+// MigrationMode controls what New does with the schema on startup
+type MigrationMode string
+
+const (
+	// MigrateUp applies pending migrations on startup
+	MigrateUp MigrationMode = "up"
+	// MigrateCheck refuses to start unless the schema is exactly up to date
+	MigrateCheck MigrationMode = "check"
+)
+
+// DefaultQueryTimeout bounds the queries of the repository unless
+// configured otherwise
+const DefaultQueryTimeout = 5 * time.Second
+
+// Config holds database configuration
+type Config struct {
+	Driver        Driver // defaults to DriverSQLite
+	DSN           string
+	MigrationMode MigrationMode
+	// QueryTimeout bounds every query of the repository and the ping on
+	// startup, DefaultQueryTimeout when zero and no bound when negative
+	QueryTimeout time.Duration
+}
+
 // DB wraps the sql.DB connection
 type DB struct {
 	*sql.DB
+	Driver Driver
+	// QueryTimeout is the bound for repository queries, zero for none.
+	// Pass it on with repository.WithQueryTimeout.
+	QueryTimeout time.Duration
 }
 
-// New creates a new database connection and initializes the schema
-func New(dataSourceName string) (*DB, error) {
-	db, err := sql.Open("sqlite3", dataSourceName)
+// New creates a new database connection and migrates the schema.
+// It refuses to start on a dirty schema or a schema newer than the binary.
+func New(config Config) (*DB, error) {
+	db, err := Open(config)
+	if err != nil {
+		return nil, err
+	}
+
+	driver := config.driver()
+	if err := migrate(db, driver, config.MigrationMode); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	return &DB{DB: db, Driver: driver, QueryTimeout: config.queryTimeout()}, nil
+}
+
+// Open connects to the database without touching the schema. Like New it
+// enforces foreign keys on SQLite, so tools working on the schema, such
+// as the migrate command, see the same cascades as the server.
+func Open(config Config) (*sql.DB, error) {
+	driver := config.driver()
+
+	driverName, err := sqlDriverName(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	dsn := config.DSN
+	if driver == DriverSQLite {
+		dsn = sqliteDSN(dsn)
+	}
+
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	if err := db.Ping(); err != nil {
+	if err := ping(db, config.queryTimeout()); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	if err := initSchema(db); err != nil {
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	if driver == DriverSQLite {
+		if err := checkForeignKeys(db); err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
 
-	return &DB{db}, nil
+	return db, nil
 }
 
-// initSchema creates the necessary tables
-func initSchema(db *sql.DB) error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS teams (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
+// driver returns the configured driver, DriverSQLite when empty
+func (c Config) driver() Driver {
+	if c.Driver == "" {
+		return DriverSQLite
+	}
+	return c.Driver
+}
+
+// queryTimeout returns the configured query timeout, zero for no bound
+func (c Config) queryTimeout() time.Duration {
+	switch {
+	case c.QueryTimeout == 0:
+		return DefaultQueryTimeout
+	case c.QueryTimeout < 0:
+		return 0
+	default:
+		return c.QueryTimeout
+	}
+}
+
+// ping checks the connection, giving up after timeout unless it is zero
+func ping(db *sql.DB, timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return db.PingContext(ctx)
+}
+
+// sqliteDSN enables foreign key enforcement, which SQLite leaves off by
+// default. It is a connection setting, so it goes into the DSN to apply
+// to every connection of the pool rather than a one-off PRAGMA.
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "_foreign_keys=") || strings.Contains(dsn, "_fk=") {
+		return dsn
+	}
+
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + "_foreign_keys=on"
+}
 
-	CREATE TABLE IF NOT EXISTS users (
-		id TEXT PRIMARY KEY,
-		team_id TEXT NOT NULL,
-		first_name TEXT NOT NULL,
-		initials TEXT,
-		parent_names TEXT,       -- JSON array
-		grandparent_names TEXT,  -- JSON array
-		country TEXT,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
-	);
+// checkForeignKeys makes sure cascading deletes will actually happen
+func checkForeignKeys(db *sql.DB) error {
+	var enabled bool
+	if err := db.QueryRow(`PRAGMA foreign_keys`).Scan(&enabled); err != nil {
+		return fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	if !enabled {
+		return fmt.Errorf("foreign keys are disabled, remove _foreign_keys=off from the DSN")
+	}
+	return nil
+}
 
-	CREATE INDEX IF NOT EXISTS idx_users_team_id ON users(team_id);
-	`
+// sqlDriverName maps a Driver to the registered database/sql driver name
+func sqlDriverName(driver Driver) (string, error) {
+	switch driver {
+	case DriverSQLite:
+		return "sqlite3", nil
+	case DriverPostgres:
+		return "postgres", nil
+	default:
+		return "", fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
 
-	_, err := db.Exec(schema)
-	return err
+// migrate brings the schema up to date according to the migration mode
+func migrate(db *sql.DB, driver Driver, mode MigrationMode) error {
+	migrations, err := Migrations(driver)
+	if err != nil {
+		return err
+	}
+
+	migrator := NewMigrator(db, migrations)
+
+	switch mode {
+	case MigrateUp, "":
+		return migrator.Up()
+	case MigrateCheck:
+		status, err := migrator.Verify()
+		if err != nil {
+			return err
+		}
+		if len(status.Pending) > 0 {
+			return fmt.Errorf("%w: at version %d, latest is %d", ErrPendingMigrations, status.Version, status.Latest)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migration mode %q", mode)
+	}
 }
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/metrics"
+)
+
+// RegisterMetrics exposes the statistics of the connection pool in reg
+func (d *DB) RegisterMetrics(reg *metrics.Registry) {
+	name := func(suffix string) string {
+		return metrics.Namespace + "db_" + suffix
+	}
+
+	reg.NewGaugeFunc(name("max_open_connections"), "Maximum number of open connections to the database.", func() float64 {
+		return float64(d.Stats().MaxOpenConnections)
+	})
+	reg.NewGaugeFunc(name("open_connections"), "Established connections, in use and idle.", func() float64 {
+		return float64(d.Stats().OpenConnections)
+	})
+	reg.NewGaugeFunc(name("in_use_connections"), "Connections currently in use.", func() float64 {
+		return float64(d.Stats().InUse)
+	})
+	reg.NewGaugeFunc(name("idle_connections"), "Idle connections.", func() float64 {
+		return float64(d.Stats().Idle)
+	})
+	reg.NewCounterFunc(name("wait_count_total"), "Connections waited for.", func() float64 {
+		return float64(d.Stats().WaitCount)
+	})
+	reg.NewCounterFunc(name("wait_duration_seconds_total"), "Time blocked waiting for a new connection.", func() float64 {
+		return d.Stats().WaitDuration.Seconds()
+	})
+	reg.NewCounterFunc(name("max_idle_closed_total"), "Connections closed due to the idle limit.", func() float64 {
+		return float64(d.Stats().MaxIdleClosed)
+	})
+	reg.NewCounterFunc(name("max_lifetime_closed_total"), "Connections closed due to their maximum lifetime.", func() float64 {
+		return float64(d.Stats().MaxLifetimeClosed)
+	})
+}
@@ -0,0 +1,433 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// ErrDirtySchema is returned when a previous migration failed half-way
+var ErrDirtySchema = errors.New("database schema is dirty")
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer binary
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// ErrChecksumMismatch is returned when an applied migration was edited afterwards
+var ErrChecksumMismatch = errors.New("applied migration checksum mismatch")
+
+// ErrPendingMigrations is returned in check mode when migrations are not applied yet
+var ErrPendingMigrations = errors.New("database schema has pending migrations")
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 of the up script
+}
+
+// AppliedMigration is a row of the schema_migrations table
+type AppliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	Dirty     bool
+	AppliedAt time.Time
+}
+
+// MigrationStatus describes the schema state compared to the known migrations
+type MigrationStatus struct {
+	Version int // highest applied version, 0 for an empty database
+	Latest  int // highest version known to this binary
+	Dirty   bool
+	Applied []AppliedMigration
+	Pending []Migration
+}
+
+// Migrations returns the migrations embedded into the binary for a driver
+func Migrations(driver Driver) ([]Migration, error) {
+	switch driver {
+	case DriverSQLite, DriverPostgres:
+	default:
+		return nil, fmt.Errorf("no migrations for driver %q", driver)
+	}
+
+	sub, err := fs.Sub(migrationFiles, path.Join("migrations", string(driver)))
+	if err != nil {
+		return nil, err
+	}
+	return LoadMigrations(sub)
+}
+
+// LoadMigrations reads migrations named NNNN_name.up.sql / NNNN_name.down.sql
+// from the root of fsys. Versions must start at 1 and have no gaps.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		version, name, direction, err := parseMigrationName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, name)
+		}
+
+		switch direction {
+		case "up":
+			m.Up = string(content)
+			m.Checksum = checksum(m.Up)
+		case "down":
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be sequential, expected %d, got %d", i+1, m.Version)
+		}
+	}
+
+	return migrations, nil
+}
+
+// parseMigrationName splits "0001_init.up.sql" into (1, "init", "up")
+func parseMigrationName(filename string) (int, string, string, error) {
+	base := strings.TrimSuffix(filename, ".sql")
+
+	dot := strings.LastIndex(base, ".")
+	if dot < 0 {
+		return 0, "", "", fmt.Errorf("invalid migration file name %q", filename)
+	}
+	direction := base[dot+1:]
+	if direction != "up" && direction != "down" {
+		return 0, "", "", fmt.Errorf("invalid migration direction in %q", filename)
+	}
+	base = base[:dot]
+
+	underscore := strings.Index(base, "_")
+	if underscore < 0 {
+		return 0, "", "", fmt.Errorf("invalid migration file name %q", filename)
+	}
+
+	version, err := strconv.Atoi(base[:underscore])
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("invalid migration version in %q", filename)
+	}
+
+	return version, base[underscore+1:], direction, nil
+}
+
+func checksum(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+// Migrator applies and reverts migrations, tracking them in schema_migrations
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator creates a new migrator for the given migrations
+func NewMigrator(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+// ensureTable creates the schema_migrations table
+func (m *Migrator) ensureTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		dirty BOOLEAN NOT NULL DEFAULT FALSE,
+		applied_at TIMESTAMP NOT NULL
+	)`
+
+	if _, err := m.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	return nil
+}
+
+// hasTable reports whether the schema_migrations table exists, without
+// creating it
+func (m *Migrator) hasTable() (bool, error) {
+	query := `SELECT to_regclass('schema_migrations') IS NOT NULL`
+	if _, ok := m.db.Driver().(*sqlite3.SQLiteDriver); ok {
+		query = `SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`
+	}
+
+	var exists bool
+	if err := m.db.QueryRow(query).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look for schema_migrations: %w", err)
+	}
+	return exists, nil
+}
+
+// applied returns all rows of schema_migrations ordered by version, none
+// when the table does not exist yet
+func (m *Migrator) applied() ([]AppliedMigration, error) {
+	exists, err := m.hasTable()
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(`SELECT version, name, checksum, dirty, applied_at
+			  FROM schema_migrations ORDER BY version ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var a AppliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.Dirty, &a.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		applied = append(applied, a)
+	}
+
+	return applied, rows.Err()
+}
+
+// Status reports the applied and pending migrations. It only reads, a
+// database without schema_migrations is at version 0.
+func (m *Migrator) Status() (*MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{
+		Applied: applied,
+	}
+	if len(m.migrations) > 0 {
+		status.Latest = m.migrations[len(m.migrations)-1].Version
+	}
+
+	done := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		done[a.Version] = true
+		if a.Version > status.Version {
+			status.Version = a.Version
+		}
+		if a.Dirty {
+			status.Dirty = true
+		}
+	}
+
+	for _, mig := range m.migrations {
+		if !done[mig.Version] {
+			status.Pending = append(status.Pending, mig)
+		}
+	}
+
+	return status, nil
+}
+
+// Verify checks that the schema is clean, not newer than the binary and
+// that every applied migration still matches its checksum
+func (m *Migrator) Verify() (*MigrationStatus, error) {
+	status, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range status.Applied {
+		if a.Dirty {
+			return status, fmt.Errorf("%w: migration %d (%s) did not complete", ErrDirtySchema, a.Version, a.Name)
+		}
+		if a.Version > status.Latest {
+			return status, fmt.Errorf("%w: database is at version %d, binary knows up to %d", ErrSchemaTooNew, status.Version, status.Latest)
+		}
+		if known := m.migrations[a.Version-1]; known.Checksum != a.Checksum {
+			return status, fmt.Errorf("%w: migration %d (%s)", ErrChecksumMismatch, a.Version, a.Name)
+		}
+	}
+
+	return status, nil
+}
+
+// Up applies all pending migrations in order
+func (m *Migrator) Up() error {
+	if err := m.ensureTable(); err != nil {
+		return err
+	}
+
+	status, err := m.Verify()
+	if err != nil {
+		return err
+	}
+
+	for _, mig := range status.Pending {
+		if err := m.apply(mig); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Down reverts the given number of most recently applied migrations and
+// returns how many were reverted, fewer when fewer were applied
+func (m *Migrator) Down(steps int) (int, error) {
+	status, err := m.Verify()
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for i := len(status.Applied) - 1; i >= 0 && reverted < steps; i-- {
+		if err := m.revert(m.migrations[status.Applied[i].Version-1]); err != nil {
+			return reverted, err
+		}
+		reverted++
+	}
+
+	return reverted, nil
+}
+
+// Force marks the schema as being at the given version and clears the dirty
+// flag, without running any scripts. Used to recover from a failed migration.
+func (m *Migrator) Force(version int) error {
+	if version < 0 || version > len(m.migrations) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	if err := m.ensureTable(); err != nil {
+		return err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM schema_migrations`); err != nil {
+		return fmt.Errorf("failed to reset schema_migrations: %w", err)
+	}
+
+	for _, mig := range m.migrations[:version] {
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name, checksum, dirty, applied_at)
+			  VALUES ($1, $2, $3, FALSE, $4)`, mig.Version, mig.Name, mig.Checksum, time.Now().UTC()); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", mig.Version, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// apply runs a single up migration. The version is recorded as dirty first
+// and only cleared once the script has been committed.
+func (m *Migrator) apply(mig Migration) error {
+	if _, err := m.db.Exec(`INSERT INTO schema_migrations (version, name, checksum, dirty, applied_at)
+			  VALUES ($1, $2, $3, TRUE, $4)`, mig.Version, mig.Name, mig.Checksum, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", mig.Version, err)
+	}
+
+	err := m.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(mig.Up); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`UPDATE schema_migrations SET dirty = FALSE WHERE version = $1`, mig.Version)
+		return err
+	})
+	if err != nil {
+		// The script ran in a rolled back transaction, so the schema is
+		// untouched and the dirty marker can be dropped again
+		if _, cleanupErr := m.db.Exec(`DELETE FROM schema_migrations WHERE version = $1`, mig.Version); cleanupErr != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w (schema left dirty: %v)", mig.Version, mig.Name, err, cleanupErr)
+		}
+		return fmt.Errorf("failed to apply migration %d (%s): %w", mig.Version, mig.Name, err)
+	}
+
+	return nil
+}
+
+// revert runs a single down migration
+func (m *Migrator) revert(mig Migration) error {
+	if mig.Down == "" {
+		return fmt.Errorf("migration %d (%s) has no down script", mig.Version, mig.Name)
+	}
+
+	err := m.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(mig.Down); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revert migration %d (%s): %w", mig.Version, mig.Name, err)
+	}
+
+	return nil
+}
+
+func (m *Migrator) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
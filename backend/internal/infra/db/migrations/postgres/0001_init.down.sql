@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_team_id;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS teams;
@@ -0,0 +1,21 @@
+CREATE TABLE users (
+	id TEXT PRIMARY KEY,
+	team_id TEXT NOT NULL,
+	first_name TEXT NOT NULL,
+	initials TEXT,
+	parent_names TEXT,       -- JSON array
+	grandparent_names TEXT,  -- JSON array
+	country TEXT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+);
+
+-- A user ID present in several teams keeps only its oldest membership
+INSERT INTO users (id, team_id, first_name, initials, parent_names, grandparent_names, country, created_at)
+SELECT DISTINCT ON (user_id) user_id, team_id, first_name, initials, parent_names, grandparent_names, country, created_at
+FROM team_members
+ORDER BY user_id, created_at ASC;
+
+CREATE INDEX IF NOT EXISTS idx_users_team_id ON users(team_id);
+
+DROP TABLE team_members;
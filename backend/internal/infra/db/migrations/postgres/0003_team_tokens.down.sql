@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_team_tokens_team_id;
+DROP TABLE team_tokens;
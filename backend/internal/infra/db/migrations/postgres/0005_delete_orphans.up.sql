@@ -0,0 +1,5 @@
+-- SQLite did not enforce foreign keys before, so deleted teams could leave
+-- members, secrets and invites behind. Remove them before enforcement starts.
+DELETE FROM team_members WHERE team_id NOT IN (SELECT id FROM teams);
+DELETE FROM team_tokens WHERE team_id NOT IN (SELECT id FROM teams);
+DELETE FROM team_invites WHERE team_id NOT IN (SELECT id FROM teams);
@@ -0,0 +1,7 @@
+-- Deleted teams and members are kept until the purge job removes them.
+-- deleted_at is NULL for live rows.
+ALTER TABLE teams ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE team_members ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX idx_teams_deleted_at ON teams(deleted_at);
+CREATE INDEX idx_team_members_deleted_at ON team_members(deleted_at);
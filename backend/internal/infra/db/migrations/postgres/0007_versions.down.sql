@@ -0,0 +1,4 @@
+ALTER TABLE team_members DROP COLUMN updated_at;
+ALTER TABLE team_members DROP COLUMN version;
+ALTER TABLE teams DROP COLUMN updated_at;
+ALTER TABLE teams DROP COLUMN version;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_webhook_id;
+DROP INDEX IF EXISTS idx_webhook_deliveries_due;
+DROP TABLE webhook_deliveries;
+DROP INDEX IF EXISTS idx_team_webhooks_team_id;
+DROP TABLE team_webhooks;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_audit_log_created_at;
+DROP INDEX IF EXISTS idx_audit_log_team_id;
+DROP TABLE audit_log;
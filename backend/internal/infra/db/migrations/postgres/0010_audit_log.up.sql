@@ -0,0 +1,21 @@
+-- Audit trail of every change of a team: who did what to which target
+CREATE TABLE audit_log (
+	id BIGSERIAL PRIMARY KEY,
+	team_id TEXT NOT NULL,
+	action TEXT NOT NULL,       -- e.g. member.added
+	actor_type TEXT NOT NULL,   -- token, invite or anonymous
+	actor_role TEXT,
+	actor_id TEXT,              -- token fingerprint or invite code
+	target_type TEXT NOT NULL,  -- team, member, token, invite or webhook
+	target_id TEXT NOT NULL,
+	before_json TEXT,           -- snapshot of the target before the change
+	after_json TEXT,            -- snapshot of the target after the change
+	request_id TEXT,
+	ip TEXT,
+	user_agent TEXT,
+	created_at TIMESTAMPTZ NOT NULL,
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_audit_log_team_id ON audit_log(team_id, id);
+CREATE INDEX idx_audit_log_created_at ON audit_log(team_id, created_at);
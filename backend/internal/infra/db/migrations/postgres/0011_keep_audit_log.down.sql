@@ -0,0 +1,4 @@
+-- Entries of purged teams are dropped with the foreign key back in place
+DELETE FROM audit_log WHERE team_id NOT IN (SELECT id FROM teams);
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_team_id_fkey
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE;
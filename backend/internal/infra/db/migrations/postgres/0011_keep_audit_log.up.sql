@@ -0,0 +1,3 @@
+-- The audit log outlives the teams it describes: purging a team must not
+-- erase the record of who deleted it
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_team_id_fkey;
@@ -0,0 +1,21 @@
+-- Members are identified by (team_id, user_id), so the same user ID can be
+-- used independently in several teams
+CREATE TABLE team_members (
+	team_id TEXT NOT NULL,
+	user_id TEXT NOT NULL,
+	first_name TEXT NOT NULL,
+	initials TEXT,
+	parent_names TEXT,       -- JSON array
+	grandparent_names TEXT,  -- JSON array
+	country TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (team_id, user_id),
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+);
+
+INSERT INTO team_members (team_id, user_id, first_name, initials, parent_names, grandparent_names, country, created_at)
+SELECT team_id, id, first_name, initials, parent_names, grandparent_names, country, created_at
+FROM users;
+
+DROP INDEX IF EXISTS idx_users_team_id;
+DROP TABLE users;
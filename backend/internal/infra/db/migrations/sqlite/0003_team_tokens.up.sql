@@ -0,0 +1,11 @@
+-- Access secrets of a team, stored as sha256 hashes.
+-- role is either 'admin' (read and write) or 'viewer' (read only).
+CREATE TABLE team_tokens (
+	token_hash TEXT PRIMARY KEY,
+	team_id TEXT NOT NULL,
+	role TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_team_tokens_team_id ON team_tokens(team_id);
@@ -0,0 +1,13 @@
+-- Invite codes let people join a team without knowing its secrets
+CREATE TABLE team_invites (
+	code TEXT PRIMARY KEY,
+	team_id TEXT NOT NULL,
+	role TEXT NOT NULL,         -- role granted on join
+	max_uses INTEGER,           -- NULL means unlimited
+	uses INTEGER NOT NULL DEFAULT 0,
+	expires_at DATETIME,        -- NULL means never
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_team_invites_team_id ON team_invites(team_id);
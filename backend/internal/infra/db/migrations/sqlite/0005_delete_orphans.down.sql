@@ -0,0 +1 @@
+-- Deleted orphans are not restored
@@ -0,0 +1,9 @@
+-- Without the column deleted rows would come back to life
+DELETE FROM team_members WHERE deleted_at IS NOT NULL;
+DELETE FROM teams WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_team_members_deleted_at;
+DROP INDEX IF EXISTS idx_teams_deleted_at;
+
+ALTER TABLE team_members DROP COLUMN deleted_at;
+ALTER TABLE teams DROP COLUMN deleted_at;
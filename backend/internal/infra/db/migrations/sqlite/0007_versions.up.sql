@@ -0,0 +1,9 @@
+-- Versions for optimistic concurrency: every change increments them,
+-- writes may require the version the client has seen
+ALTER TABLE teams ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE teams ADD COLUMN updated_at DATETIME;
+ALTER TABLE team_members ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE team_members ADD COLUMN updated_at DATETIME;
+
+UPDATE teams SET updated_at = created_at;
+UPDATE team_members SET updated_at = created_at;
@@ -0,0 +1,12 @@
+-- Log of team changes, streamed to clients and replayed from the
+-- last event ID they have seen
+CREATE TABLE team_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	team_id TEXT NOT NULL,
+	type TEXT NOT NULL,
+	payload TEXT NOT NULL,      -- JSON of the event
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_team_events_team_id ON team_events(team_id, id);
@@ -0,0 +1,33 @@
+-- Webhooks receive the events of a team as signed HTTP requests
+CREATE TABLE team_webhooks (
+	id TEXT PRIMARY KEY,
+	team_id TEXT NOT NULL,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,       -- HMAC key, the receiver knows it too
+	events TEXT NOT NULL,       -- JSON array of event types, empty means all
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_team_webhooks_team_id ON team_webhooks(team_id);
+
+-- Delivery queue and log: one row per event and webhook, retried until
+-- delivered or out of attempts
+CREATE TABLE webhook_deliveries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	webhook_id TEXT NOT NULL,
+	event_id INTEGER NOT NULL,
+	event_type TEXT NOT NULL,
+	payload TEXT NOT NULL,      -- JSON body as sent
+	status TEXT NOT NULL,       -- pending, delivered or failed
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at DATETIME NOT NULL,
+	last_attempt_at DATETIME,
+	response_status INTEGER,    -- HTTP status of the last attempt
+	last_error TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (webhook_id) REFERENCES team_webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, id);
@@ -0,0 +1,25 @@
+-- Entries of purged teams are dropped with the foreign key back in place
+CREATE TABLE audit_log_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	team_id TEXT NOT NULL,
+	action TEXT NOT NULL,
+	actor_type TEXT NOT NULL,
+	actor_role TEXT,
+	actor_id TEXT,
+	target_type TEXT NOT NULL,
+	target_id TEXT NOT NULL,
+	before_json TEXT,
+	after_json TEXT,
+	request_id TEXT,
+	ip TEXT,
+	user_agent TEXT,
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+);
+
+INSERT INTO audit_log_old SELECT * FROM audit_log WHERE team_id IN (SELECT id FROM teams);
+DROP TABLE audit_log;
+ALTER TABLE audit_log_old RENAME TO audit_log;
+
+CREATE INDEX idx_audit_log_team_id ON audit_log(team_id, id);
+CREATE INDEX idx_audit_log_created_at ON audit_log(team_id, created_at);
@@ -0,0 +1,26 @@
+-- The audit log outlives the teams it describes: purging a team must not
+-- erase the record of who deleted it. SQLite cannot drop a foreign key,
+-- so the table is rebuilt without it.
+CREATE TABLE audit_log_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	team_id TEXT NOT NULL,
+	action TEXT NOT NULL,
+	actor_type TEXT NOT NULL,
+	actor_role TEXT,
+	actor_id TEXT,
+	target_type TEXT NOT NULL,
+	target_id TEXT NOT NULL,
+	before_json TEXT,
+	after_json TEXT,
+	request_id TEXT,
+	ip TEXT,
+	user_agent TEXT,
+	created_at DATETIME NOT NULL
+);
+
+INSERT INTO audit_log_new SELECT * FROM audit_log;
+DROP TABLE audit_log;
+ALTER TABLE audit_log_new RENAME TO audit_log;
+
+CREATE INDEX idx_audit_log_team_id ON audit_log(team_id, id);
+CREATE INDEX idx_audit_log_created_at ON audit_log(team_id, created_at);
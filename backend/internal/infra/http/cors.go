@@ -0,0 +1,207 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+)
+
+// DefaultCORSOrigins are the origins of the mobile apps: Capacitor and
+// Ionic serve them from their own schemes on iOS and from localhost on
+// Android. Only native apps can have these origins.
+var DefaultCORSOrigins = []string{"capacitor://*", "ionic://*", "http://localhost", "https://localhost"}
+
+// DefaultCORSMethods are the methods of the API
+var DefaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// DefaultCORSHeaders are the request headers clients of the API send
+var DefaultCORSHeaders = []string{
+	"Content-Type", "Authorization", "X-Requested-With", "Accept",
+	"If-Match", "If-None-Match", "Last-Event-ID", RequestIDHeader, "Traceparent", "Tracestate",
+}
+
+// DefaultCORSExposedHeaders are the response headers clients may read
+var DefaultCORSExposedHeaders = []string{
+	"Content-Length", "Content-Type", "ETag", "Deprecation", RequestIDHeader,
+	RetryAfterHeader, RateLimitLimitHeader, RateLimitRemainingHeader, RateLimitResetHeader,
+}
+
+// DefaultCORSMaxAge is how long browsers may cache a preflight response
+const DefaultCORSMaxAge = 24 * time.Hour
+
+// CORSConfig is the policy for requests from other origins. Requests
+// from the origin serving the API, like the bundled frontend, are always
+// allowed. Empty fields take the defaults above.
+type CORSConfig struct {
+	// AllowedOrigins lists origins like https://app.example.com. A * in
+	// a pattern matches any characters but /, like capacitor://* or
+	// https://*.example.com. A single * allows every origin.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and TLS client
+	// certificates. The API authorizes by bearer tokens, so it is off by
+	// default, and it cannot be combined with allowing every origin.
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// Validate reports patterns that can never match and a wildcard origin
+// combined with credentials
+func (c CORSConfig) Validate() error {
+	for _, pattern := range c.AllowedOrigins {
+		if pattern == "*" {
+			if c.AllowCredentials {
+				return errors.New("CORS credentials cannot be allowed for every origin")
+			}
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid CORS origin pattern %q: %w", pattern, err)
+		}
+	}
+	if c.MaxAge < 0 {
+		return errors.New("CORS max age must not be negative")
+	}
+	return nil
+}
+
+// withDefaults fills the empty fields with the defaults
+func (c CORSConfig) withDefaults() CORSConfig {
+	if len(c.AllowedOrigins) == 0 {
+		c.AllowedOrigins = DefaultCORSOrigins
+	}
+	if len(c.AllowedMethods) == 0 {
+		c.AllowedMethods = DefaultCORSMethods
+	}
+	if len(c.AllowedHeaders) == 0 {
+		c.AllowedHeaders = DefaultCORSHeaders
+	}
+	if len(c.ExposedHeaders) == 0 {
+		c.ExposedHeaders = DefaultCORSExposedHeaders
+	}
+	if c.MaxAge == 0 {
+		c.MaxAge = DefaultCORSMaxAge
+	}
+	return c
+}
+
+// cors applies a CORS policy in front of the router
+type cors struct {
+	config    CORSConfig
+	anyOrigin bool
+	methods   string
+	headers   string
+	exposed   string
+	maxAge    string
+}
+
+// newCORS prepares the headers of a policy
+func newCORS(config CORSConfig) *cors {
+	config = config.withDefaults()
+
+	c := &cors{
+		config:  config,
+		methods: strings.Join(config.AllowedMethods, ", "),
+		headers: strings.Join(config.AllowedHeaders, ", "),
+		exposed: strings.Join(config.ExposedHeaders, ", "),
+		maxAge:  strconv.Itoa(int(config.MaxAge.Seconds())),
+	}
+	for _, pattern := range config.AllowedOrigins {
+		if pattern == "*" {
+			c.anyOrigin = true
+		}
+	}
+	return c
+}
+
+// handler answers preflight requests for every route and rejects
+// requests from origins the policy does not allow, before they reach next
+func (c *cors) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || sameOrigin(origin, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !c.allowsOrigin(origin) {
+			sendError(w, http.StatusForbidden, model.ErrorResponse{Code: CodeForbidden, Error: "Origin not allowed"})
+			return
+		}
+
+		if c.anyOrigin {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if c.config.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			w.Header().Set("Access-Control-Expose-Headers", c.exposed)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !c.allowsMethod(r.Header.Get("Access-Control-Request-Method")) {
+			sendError(w, http.StatusForbidden, model.ErrorResponse{Code: CodeForbidden, Error: "Method not allowed"})
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", c.methods)
+		w.Header().Set("Access-Control-Allow-Headers", c.headers)
+		w.Header().Set("Access-Control-Max-Age", c.maxAge)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allowsOrigin matches an origin against the allowed patterns
+func (c *cors) allowsOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	for _, pattern := range c.config.AllowedOrigins {
+		if ok, _ := path.Match(strings.ToLower(pattern), origin); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsMethod reports whether a preflight asks for an allowed method
+func (c *cors) allowsMethod(method string) bool {
+	for _, allowed := range c.config.AllowedMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether origin is the host serving r. Browsers send
+// the Origin header on some same-origin requests too.
+func sameOrigin(origin string, r *http.Request) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+)
+
+// Error codes of failures that are not domain errors
+const (
+	CodeInvalidRequest = "invalid_request"
+	CodeUnauthorized   = "unauthorized"
+	CodeForbidden      = "forbidden"
+	CodeNotFound       = "not_found"
+	CodeConflict       = "conflict"
+	CodeGone           = "gone"
+	CodePrecondition   = "precondition_failed"
+	CodeRateLimited    = "rate_limited"
+	CodeClientClosed   = "client_closed"
+	CodeUnavailable    = "unavailable"
+	CodeInternal       = "internal"
+)
+
+// StatusClientClosedRequest is the status of requests canceled because
+// their client went away, as nginx logs them. Clients never see it.
+const StatusClientClosedRequest = 499
+
+// StatusOf returns the HTTP status for an error by its domain kind.
+// Timeouts make the service unavailable, canceled requests were given up
+// by their client, other errors without a kind are internal failures.
+func StatusOf(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrExpired):
+		return http.StatusGone
+	case errors.Is(err, domain.ErrPrecondition):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, context.DeadlineExceeded):
+		// The database took too long, likely overloaded
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// SendDomainError sends err with the status of its kind and its code.
+// Internal failures are added to the request log and reported with the
+// fallback message only, so that details of the storage never leak to
+// clients. Canceled requests are not failures of the server: they are
+// sent 499, or 503 when aborted by a shutdown, and not logged as errors.
+func SendDomainError(w http.ResponseWriter, err error, fallback string) {
+	status := StatusOf(err)
+	if status == StatusClientClosedRequest {
+		if abortedByShutdown(w) {
+			status = http.StatusServiceUnavailable
+		}
+		sendError(w, status, model.ErrorResponse{Code: codeOf(status), Error: fallback})
+		return
+	}
+
+	var domainErr *domain.Error
+	if status >= http.StatusInternalServerError || !errors.As(err, &domainErr) {
+		if status >= http.StatusInternalServerError {
+			recordError(w, fallback, err)
+		}
+		sendError(w, status, model.ErrorResponse{Code: codeOf(status), Error: fallback})
+		return
+	}
+
+	sendError(w, status, model.ErrorResponse{
+		Code:   domainErr.Code,
+		Error:  domainErr.Message,
+		Fields: domainErr.Fields,
+	})
+}
+
+// codeOf returns the generic error code of a status
+func codeOf(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusGone:
+		return CodeGone
+	case http.StatusPreconditionFailed:
+		return CodePrecondition
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case StatusClientClosedRequest:
+		return CodeClientClosed
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	default:
+		return CodeInternal
+	}
+}
+
+// sendError sends an error response
+func sendError(w http.ResponseWriter, status int, resp model.ErrorResponse) {
+	resp.Success = false
+	SendJSON(w, status, resp)
+}
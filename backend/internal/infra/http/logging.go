@@ -0,0 +1,237 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// RequestIDHeader carries the ID of a request. Clients may send one,
+// otherwise it is generated; either way the response echoes it.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key holding the request ID
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request ctx belongs to
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// observeRequests assigns every request an ID, traces it in a server span,
+// puts a logger with both into the request context and logs and measures
+// the request once it has been served. Only the path is logged: query strings may hold access tokens.
+func (s *Server) observeRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		route := s.routeTemplate(r)
+		ctx, span := startSpan(r, route)
+
+		logger := s.logger.With("request_id", requestID)
+		if id := traceID(ctx); id != "" {
+			logger = logger.With("trace_id", id)
+		}
+		ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+		ctx = logging.WithLogger(ctx, logger)
+
+		recorder := &responseRecorder{ResponseWriter: w, ctx: ctx}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		duration := time.Since(start)
+		endSpan(span, status, recorder.err)
+
+		method := metricMethod(r.Method)
+		s.requests.Inc(method, route, strconv.Itoa(status))
+		s.latency.Observe(duration.Seconds(), method, route)
+
+		// Requests given up by their client or aborted by a shutdown are
+		// expected, not failures worth a warning
+		level := slog.LevelInfo
+		switch {
+		case status == StatusClientClosedRequest, errors.Is(context.Cause(ctx), errShuttingDown):
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Int64("bytes", recorder.bytes),
+			slog.Float64("duration_ms", float64(duration.Microseconds())/1000),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("user_agent", r.UserAgent()),
+		}
+		if recorder.err != nil {
+			attrs = append(attrs, slog.String("error", recorder.err.Error()))
+		}
+
+		logger.LogAttrs(ctx, level, "request", attrs...)
+	})
+}
+
+// routeTemplate returns the path template of the route serving r, like
+// /api/v1/teams/{teamId}, or an empty string when no route matches
+func (s *Server) routeTemplate(r *http.Request) string {
+	var match mux.RouteMatch
+	if !s.router.Match(r, &match) || match.Route == nil {
+		return ""
+	}
+
+	template, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return template
+}
+
+// metricMethod bounds the methods in metric labels to the standard ones
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	default:
+		return "other"
+	}
+}
+
+// validRequestID accepts IDs of letters, digits and -_.: only, so that
+// they are safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// responseRecorder remembers the status, size and internal error of a
+// response. It passes flushing and hijacking on, so streams keep working.
+type responseRecorder struct {
+	http.ResponseWriter
+	// ctx is the context of the request, telling why it was canceled
+	ctx    context.Context
+	status int
+	bytes  int64
+	err    error
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher
+func (rec *responseRecorder) Flush() {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker
+func (rec *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(rec.ResponseWriter).Hijack()
+	if err == nil {
+		rec.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// recordError adds an internal error to the request log, or logs it
+// right away when w is not recorded
+func recordError(w http.ResponseWriter, message string, err error) {
+	if rec := recorderOf(w); rec != nil {
+		rec.err = errors.Join(rec.err, fmt.Errorf("%s: %w", message, err))
+		return
+	}
+
+	slog.Error(message, "error", err)
+}
+
+// recordedStatus returns the status sent through w, 0 when w is not
+// recorded or nothing was sent
+func recordedStatus(w http.ResponseWriter) int {
+	if rec := recorderOf(w); rec != nil {
+		return rec.status
+	}
+	return 0
+}
+
+// recorderOf finds the recorder w writes through, nil when there is none
+func recorderOf(w http.ResponseWriter) *responseRecorder {
+	for {
+		if rec, ok := w.(*responseRecorder); ok {
+			return rec
+		}
+
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = unwrapper.Unwrap()
+	}
+}
@@ -0,0 +1,424 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/metrics"
+)
+
+// Default limits of the API, see RateLimitConfig
+const (
+	DefaultRateLimitIPPerMinute     = 300
+	DefaultRateLimitIPBurst         = 100
+	DefaultRateLimitTeamPerMinute   = 600
+	DefaultRateLimitTeamBurst       = 200
+	DefaultRateLimitFailuresPerHour = 30
+	DefaultRateLimitFailureBurst    = 20
+	DefaultRateLimitBackoff         = 5 * time.Second
+	DefaultRateLimitMaxBackoff      = 15 * time.Minute
+)
+
+// Headers of the rate limits. The RateLimit headers follow the IETF
+// draft: the requests left in the most restrictive limit and the seconds
+// until it is full again.
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RetryAfterHeader         = "Retry-After"
+)
+
+// RateLimitConfig limits the requests to the API, the /api routes. Every
+// client IP has a token bucket, refilled at a steady rate up to a burst,
+// and so has every team, charged by the requests authorized by its
+// tokens only. Failed lookups and authorizations, 401, 403
+// and 404 responses, also drain a stricter bucket of the client: once it
+// is empty, every further failure blocks the client twice as long as the
+// one before, so that guessing team IDs, tokens and invite codes is
+// impractical. Empty fields take the defaults above.
+type RateLimitConfig struct {
+	Enabled         bool
+	IPPerMinute     int
+	IPBurst         int
+	TeamPerMinute   int
+	TeamBurst       int
+	FailuresPerHour int
+	FailureBurst    int
+	// Backoff is the first block once the failures are used up, doubled
+	// for every further failure up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// TrustProxy takes the client IP from the last X-Forwarded-For entry,
+	// for servers behind a reverse proxy. Without a proxy, clients would
+	// choose their own IP.
+	TrustProxy bool
+}
+
+// Validate reports negative limits and a maximum backoff below the first
+func (c RateLimitConfig) Validate() error {
+	for _, n := range []int{c.IPPerMinute, c.IPBurst, c.TeamPerMinute, c.TeamBurst, c.FailuresPerHour, c.FailureBurst} {
+		if n < 0 {
+			return errors.New("rate limits must not be negative")
+		}
+	}
+	if c.Backoff < 0 || c.MaxBackoff < 0 {
+		return errors.New("rate limit backoff must not be negative")
+	}
+	if c = c.withDefaults(); c.MaxBackoff < c.Backoff {
+		return errors.New("rate limit max backoff must not be below the backoff")
+	}
+	return nil
+}
+
+// withDefaults fills the empty fields with the defaults
+func (c RateLimitConfig) withDefaults() RateLimitConfig {
+	orDefault := func(value *int, defaultValue int) {
+		if *value == 0 {
+			*value = defaultValue
+		}
+	}
+	orDefault(&c.IPPerMinute, DefaultRateLimitIPPerMinute)
+	orDefault(&c.IPBurst, DefaultRateLimitIPBurst)
+	orDefault(&c.TeamPerMinute, DefaultRateLimitTeamPerMinute)
+	orDefault(&c.TeamBurst, DefaultRateLimitTeamBurst)
+	orDefault(&c.FailuresPerHour, DefaultRateLimitFailuresPerHour)
+	orDefault(&c.FailureBurst, DefaultRateLimitFailureBurst)
+	if c.Backoff == 0 {
+		c.Backoff = DefaultRateLimitBackoff
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = DefaultRateLimitMaxBackoff
+	}
+	return c
+}
+
+// Reasons for rejecting a request, the label of the rate limit metric
+const (
+	limitedByIP      = "ip"
+	limitedByTeam    = "team"
+	limitedByBackoff = "backoff"
+)
+
+// sweepInterval is how often buckets back to full are forgotten
+const sweepInterval = time.Minute
+
+// rateLimiter holds the buckets of the clients and teams
+type rateLimiter struct {
+	config   RateLimitConfig
+	ip       limit
+	team     limit
+	failures limit
+	limited  *metrics.Counter
+
+	mu      sync.Mutex
+	clients map[string]*client
+	teams   map[string]*bucket
+	swept   time.Time
+}
+
+// client is the state of a client IP
+type client struct {
+	requests bucket
+	failures bucket
+	// strikes counts the failures since the failure bucket ran empty
+	strikes      int
+	blockedUntil time.Time
+}
+
+// newRateLimiter creates a limiter, counting rejections in registry
+// when set
+func newRateLimiter(config RateLimitConfig, registry *metrics.Registry) *rateLimiter {
+	config = config.withDefaults()
+
+	l := &rateLimiter{
+		config:   config,
+		ip:       limit{burst: float64(config.IPBurst), perSecond: float64(config.IPPerMinute) / 60},
+		team:     limit{burst: float64(config.TeamBurst), perSecond: float64(config.TeamPerMinute) / 60},
+		failures: limit{burst: float64(config.FailureBurst), perSecond: float64(config.FailuresPerHour) / 3600},
+		clients:  make(map[string]*client),
+		teams:    make(map[string]*bucket),
+	}
+	if registry != nil {
+		l.limited = registry.NewCounter(metrics.Namespace+"http_rate_limited_total",
+			"API requests rejected by the rate limits, by reason.", "reason")
+	}
+	return l
+}
+
+// teamLimitKey is the context key of the *teamLimit of a request
+type teamLimitKey struct{}
+
+// teamLimit is what LimitTeam needs of a request let through by the
+// limits of its client
+type teamLimit struct {
+	limiter *rateLimiter
+	client  quota
+}
+
+// limitRequests rejects API requests over the limits of their client with
+// 429 and counts the failed ones once served. Without a limiter it
+// returns next.
+func (s *Server) limitRequests(next http.Handler) http.Handler {
+	l := s.limiter
+	if l == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ip := l.clientIP(r)
+		reason, q, retry := l.allow(ip, time.Now())
+		q.setHeaders(w.Header())
+		if reason != "" {
+			l.reject(w, reason, retry)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), teamLimitKey{}, &teamLimit{limiter: l, client: q})
+		next.ServeHTTP(w, r.WithContext(ctx))
+
+		switch recordedStatus(w) {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+			l.fail(ip, time.Now())
+		}
+	})
+}
+
+// LimitTeam takes a token of the team of an authorized request, sending
+// 429 and returning false when the team is over its limit. Teams are
+// charged once their token is verified, so that clients without one can't
+// drain the bucket of a team. Without a limiter it returns true.
+func LimitTeam(w http.ResponseWriter, r *http.Request, teamID string) bool {
+	t, ok := r.Context().Value(teamLimitKey{}).(*teamLimit)
+	if !ok {
+		return true
+	}
+
+	q, retry, ok := t.limiter.allowTeam(teamID, time.Now())
+	if q.remaining < t.client.remaining {
+		q.setHeaders(w.Header())
+	}
+	if !ok {
+		t.limiter.reject(w, limitedByTeam, retry)
+		return false
+	}
+	return true
+}
+
+// reject sends 429 for a request over a limit and counts it
+func (l *rateLimiter) reject(w http.ResponseWriter, reason string, retry time.Duration) {
+	l.limited.Inc(reason)
+	w.Header().Set(RetryAfterHeader, seconds(retry))
+	sendError(w, http.StatusTooManyRequests, model.ErrorResponse{
+		Code:  CodeRateLimited,
+		Error: "Too many requests, retry after " + seconds(retry) + "s",
+	})
+}
+
+// allow takes a token of the client and returns the remaining quota. A
+// rejected request has a reason and the time to wait before retrying.
+func (l *rateLimiter) allow(ip string, now time.Time) (reason string, q quota, retry time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	c := l.client(ip, now)
+	if now.Before(c.blockedUntil) {
+		retry = c.blockedUntil.Sub(now)
+		return limitedByBackoff, quota{limit: l.ip.burst, reset: retry}, retry
+	}
+
+	if !l.ip.take(&c.requests, now) {
+		retry = l.ip.wait(&c.requests, 1)
+		return limitedByIP, l.ip.quota(&c.requests), retry
+	}
+	return "", l.ip.quota(&c.requests), 0
+}
+
+// allowTeam takes a token of the team and returns its remaining quota. A
+// rejected request has the time to wait before retrying.
+func (l *rateLimiter) allowTeam(team string, now time.Time) (q quota, retry time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	b, ok := l.teams[team]
+	if !ok {
+		b = &bucket{}
+		l.teams[team] = b
+	}
+	if !l.team.take(b, now) {
+		return l.team.quota(b), l.team.wait(b, 1), false
+	}
+	return l.team.quota(b), 0, true
+}
+
+// fail counts a failed request of the client. Once the failure bucket is
+// empty, the client is blocked for the backoff, doubled for every further
+// failure until the bucket is full again.
+func (l *rateLimiter) fail(ip string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.client(ip, now)
+	if l.failures.take(&c.failures, now) {
+		return
+	}
+
+	c.strikes++
+	backoff := l.config.MaxBackoff
+	if shift := c.strikes - 1; shift < 32 && l.config.Backoff<<shift < backoff {
+		backoff = l.config.Backoff << shift
+	}
+	c.blockedUntil = now.Add(backoff)
+}
+
+// client returns the state of a client IP, forgiving its strikes once its
+// failure bucket is full again. Callers hold the lock.
+func (l *rateLimiter) client(ip string, now time.Time) *client {
+	c, ok := l.clients[ip]
+	if !ok {
+		c = &client{}
+		l.clients[ip] = c
+	}
+
+	l.failures.refill(&c.failures, now)
+	if l.failures.full(&c.failures) {
+		c.strikes = 0
+	}
+	return c
+}
+
+// sweep forgets the clients and teams whose buckets are full again, as
+// they would be created. Callers hold the lock.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+
+	for ip, c := range l.clients {
+		l.ip.refill(&c.requests, now)
+		l.failures.refill(&c.failures, now)
+		if l.ip.full(&c.requests) && l.failures.full(&c.failures) && !now.Before(c.blockedUntil) {
+			delete(l.clients, ip)
+		}
+	}
+	for team, b := range l.teams {
+		l.team.refill(b, now)
+		if l.team.full(b) {
+			delete(l.teams, team)
+		}
+	}
+}
+
+// clientIP returns the address requests are counted by. IPv6 clients
+// usually get a whole /64, so they are counted by their /64.
+func (l *rateLimiter) clientIP(r *http.Request) string {
+	addr := r.RemoteAddr
+	if l.config.TrustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			entries := strings.Split(forwarded[len(forwarded)-1], ",")
+			addr = strings.TrimSpace(entries[len(entries)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+
+	ip = ip.WithZone("").Unmap()
+	if ip.Is6() {
+		prefix, _ := ip.Prefix(64)
+		return prefix.String()
+	}
+	return ip.String()
+}
+
+// limit is the rate and capacity of token buckets
+type limit struct {
+	burst     float64
+	perSecond float64
+}
+
+// bucket holds the tokens left, a new bucket is full
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens earned since the last refill
+func (l limit) refill(b *bucket, now time.Time) {
+	if b.updated.IsZero() {
+		b.tokens = l.burst
+	} else if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+elapsed.Seconds()*l.perSecond)
+	}
+	b.updated = now
+}
+
+// take refills b and takes a token, reporting whether there was one
+func (l limit) take(b *bucket, now time.Time) bool {
+	l.refill(b, now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// full reports whether a refilled b is at its burst
+func (l limit) full(b *bucket) bool {
+	return b.tokens >= l.burst
+}
+
+// wait returns how long until a refilled b holds n tokens
+func (l limit) wait(b *bucket, n float64) time.Duration {
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / l.perSecond * float64(time.Second))
+}
+
+// quota returns the quota of a refilled b
+func (l limit) quota(b *bucket) quota {
+	return quota{limit: l.burst, remaining: math.Floor(b.tokens), reset: l.wait(b, l.burst)}
+}
+
+// quota is what the RateLimit headers report
+type quota struct {
+	limit     float64
+	remaining float64
+	reset     time.Duration
+}
+
+// setHeaders sets the RateLimit headers
+func (q quota) setHeaders(h http.Header) {
+	h.Set(RateLimitLimitHeader, strconv.Itoa(int(q.limit)))
+	h.Set(RateLimitRemainingHeader, strconv.Itoa(int(q.remaining)))
+	h.Set(RateLimitResetHeader, seconds(q.reset))
+}
+
+// seconds formats d in whole seconds, rounded up
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
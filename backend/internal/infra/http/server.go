@@ -1,18 +1,53 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
-	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/metrics"
 )
 
+// Default timeouts of the server
+const (
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultReadTimeout       = 15 * time.Second
+	DefaultWriteTimeout      = 30 * time.Second
+	DefaultIdleTimeout       = 2 * time.Minute
+)
+
+// DefaultFrontendPath is where the built frontend is served from
+const DefaultFrontendPath = "./frontend/dist"
+
 // Config holds server configuration
 type Config struct {
 	Port string
+	// FrontendPath is the directory of the built frontend,
+	// DefaultFrontendPath when empty
+	FrontendPath string
+	// Timeouts of the underlying http.Server, the defaults when zero.
+	// Event streams and WebSockets lift the read and write timeouts.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// Logger logs the requests and the server, slog.Default() when nil
+	Logger *slog.Logger
+	// Metrics, when set, receives the request metrics and is served at
+	// /metrics
+	Metrics *metrics.Registry
+	// CORS is the policy for requests from other origins
+	CORS CORSConfig
+	// RateLimit limits the API requests of every client and team, when
+	// enabled
+	RateLimit RateLimitConfig
 }
 
 // RouteHandler represents a handler with its HTTP method
@@ -24,21 +59,78 @@ type RouteHandler struct {
 
 // Server represents the HTTP server
 type Server struct {
-	config   Config
-	router   *mux.Router
-	handlers []RouteHandler
+	config      Config
+	logger      *slog.Logger
+	requests    *metrics.Counter
+	latency     *metrics.Histogram
+	cors        *cors
+	limiter     *rateLimiter
+	server      *http.Server
+	router      *mux.Router
+	handlers    []RouteHandler
+	routesReady bool
+	// abort cancels the contexts of the requests in flight
+	abort context.CancelCauseFunc
 }
 
+// errShuttingDown is the cause of requests aborted by Shutdown
+var errShuttingDown = errors.New("server shutting down")
+
 // NewServer creates a new server instance
 func NewServer(config Config) *Server {
-	return &Server{
-		config:   config,
+	addr := config.Port
+	if addr == "" {
+		addr = ":8080"
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	// Requests run in a context canceled when shutting down takes too long
+	base, abort := context.WithCancelCause(context.Background())
+
+	s := &Server{
+		config: config,
+		logger: logger,
+		cors:   newCORS(config.CORS),
+		abort:  abort,
+		server: &http.Server{
+			Addr:              addr,
+			ReadHeaderTimeout: orDefault(config.ReadHeaderTimeout, DefaultReadHeaderTimeout),
+			ReadTimeout:       orDefault(config.ReadTimeout, DefaultReadTimeout),
+			WriteTimeout:      orDefault(config.WriteTimeout, DefaultWriteTimeout),
+			IdleTimeout:       orDefault(config.IdleTimeout, DefaultIdleTimeout),
+			ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+			BaseContext:       func(net.Listener) context.Context { return base },
+		},
 		router:   mux.NewRouter(),
 		handlers: make([]RouteHandler, 0),
 	}
+
+	if config.Metrics != nil {
+		s.requests = config.Metrics.NewCounter(metrics.Namespace+"http_requests_total",
+			"HTTP requests by method, route and status.", "method", "route", "status")
+		s.latency = config.Metrics.NewHistogram(metrics.Namespace+"http_request_duration_seconds",
+			"Latency of HTTP requests by method and route.", nil, "method", "route")
+	}
+	if config.RateLimit.Enabled {
+		s.limiter = newRateLimiter(config.RateLimit, config.Metrics)
+	}
+
+	return s
+}
+
+// orDefault returns value, or defaultValue when value is zero
+func orDefault(value, defaultValue time.Duration) time.Duration {
+	if value == 0 {
+		return defaultValue
+	}
+	return value
 }
 
-// Handle registers a handler with CORS middleware for a specific HTTP method
+// Handle registers a handler for a specific HTTP method
 func (s *Server) Handle(method, pattern string, handler http.HandlerFunc) {
 	s.handlers = append(s.handlers, RouteHandler{
 		Pattern: pattern,
@@ -47,6 +139,23 @@ func (s *Server) Handle(method, pattern string, handler http.HandlerFunc) {
 	})
 }
 
+// Handler returns the router with all registered routes, logging every
+// request and applying the CORS policy and the rate limits. Routes
+// registered after the first call are ignored.
+func (s *Server) Handler() http.Handler {
+	return s.observeRequests(s.cors.handler(s.limitRequests(s.Router())))
+}
+
+// Router returns the router with all registered routes, without
+// middleware. Routes registered after the first call are ignored.
+func (s *Server) Router() *mux.Router {
+	if !s.routesReady {
+		s.setupRoutes()
+		s.routesReady = true
+	}
+	return s.router
+}
+
 // setupRoutes configures all routes
 func (s *Server) setupRoutes() {
 	// API routes - all API handlers registered without /api prefix
@@ -57,24 +166,28 @@ func (s *Server) setupRoutes() {
 		if route.Pattern == "/health" {
 			continue
 		}
-		apiRouter.HandleFunc(route.Pattern, corsMiddleware(route.Handler)).Methods(route.Method)
+		apiRouter.HandleFunc(route.Pattern, route.Handler).Methods(route.Method)
 	}
 
 	// Health check - outside /api prefix
 	for _, route := range s.handlers {
 		if route.Pattern == "/health" {
-			s.router.HandleFunc("/health", corsMiddleware(route.Handler)).Methods(route.Method)
+			s.router.HandleFunc("/health", route.Handler).Methods(route.Method)
 			break
 		}
 	}
 
-	// Get frontend path from environment or use default
-	frontendPath := os.Getenv("FRONTEND_PATH")
+	// Metrics - outside /api prefix, like health
+	if s.config.Metrics != nil {
+		s.router.Handle("/metrics", s.config.Metrics.Handler()).Methods("GET")
+	}
+
+	frontendPath := s.config.FrontendPath
 	if frontendPath == "" {
-		frontendPath = "./frontend/dist"
+		frontendPath = DefaultFrontendPath
 	}
 
-	log.Printf("Serving frontend from: %s", frontendPath)
+	s.logger.Info("serving frontend", "path", frontendPath)
 
 	// Serve static frontend files
 	// Serve index.html for root path
@@ -87,55 +200,48 @@ func (s *Server) setupRoutes() {
 	s.router.PathPrefix("/").Handler(http.StripPrefix("/", fileServer))
 }
 
-// Start starts the HTTP server
+// Start listens on the configured port and serves until Shutdown.
+// It returns nil once shut down.
 func (s *Server) Start() error {
-	// Setup routes before starting
-	s.setupRoutes()
-
-	addr := s.config.Port
-	if addr == "" {
-		addr = ":8080"
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.server.Addr, err)
 	}
 
-	log.Printf("Server starting on %s", addr)
-	return http.ListenAndServe(addr, s.router)
+	return s.Serve(listener)
 }
 
-// corsMiddleware adds CORS headers to responses
-func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		origin := r.Header.Get("Origin")
-
-		// Log origin for debugging
-		if origin != "" {
-			log.Printf("[CORS] Request from origin: %s", origin)
-		}
-
-		// Allow requests from any origin (including Capacitor apps)
-		// Capacitor typically uses origins like:
-		// - capacitor://localhost
-		// - http://localhost
-		// - ionic://localhost
-		if origin != "" {
-			w.Header().Set("Access-Control-Allow-Origin", origin)
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
-		} else {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-		}
-
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Accept, Origin")
-		w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type")
-		w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
+// Serve serves on listener until Shutdown. It returns nil once shut down.
+func (s *Server) Serve(listener net.Listener) error {
+	// Setup routes before starting
+	s.server.Handler = s.Handler()
 
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+	s.logger.Info("server starting", "addr", listener.Addr().String())
+	err := s.server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
 
-		next(w, r)
+// Shutdown stops accepting connections and waits for the requests in
+// flight to finish, or for ctx to be done. Requests still running then
+// have their contexts canceled, which aborts their database work.
+// Hijacked connections, like WebSockets, are not waited for.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.logger.Info("server shutting down")
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		s.abort(errShuttingDown)
 	}
+	return err
+}
+
+// abortedByShutdown reports whether the request w answers was canceled
+// by Shutdown rather than by its client
+func abortedByShutdown(w http.ResponseWriter) bool {
+	rec := recorderOf(w)
+	return rec != nil && rec.ctx != nil && errors.Is(context.Cause(rec.ctx), errShuttingDown)
 }
 
 // SendJSON sends a JSON response
@@ -143,14 +249,11 @@ func SendJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("Error encoding JSON response: %v", err)
+		recordError(w, "failed to encode JSON response", err)
 	}
 }
 
-// SendError sends an error response
+// SendError sends an error response with the generic code of the status
 func SendError(w http.ResponseWriter, status int, message string) {
-	SendJSON(w, status, model.ErrorResponse{
-		Success: false,
-		Error:   message,
-	})
+	sendError(w, status, model.ErrorResponse{Code: codeOf(status), Error: message})
 }
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the spans of the server in traces
+const tracerName = "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+
+// startSpan starts the server span of a request, continuing the trace of
+// the client when it sent a traceparent header. The span is named after
+// the route, like GET /api/v1/teams/{teamId}, to keep the names few.
+func startSpan(r *http.Request, route string) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+	name := r.Method
+	if route != "" {
+		name += " " + route
+	}
+
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(metricMethod(r.Method)),
+			semconv.HTTPRoute(route),
+			semconv.URLPath(r.URL.Path),
+			semconv.UserAgentOriginal(r.UserAgent()),
+		))
+}
+
+// endSpan records the status of the response and ends the span.
+// Only server errors mark the span as failed.
+func endSpan(span trace.Span, status int, err error) {
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// traceID returns the ID of the trace ctx belongs to, empty when the
+// request is not traced
+func traceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
@@ -0,0 +1,77 @@
+// Package logging configures the structured logger and carries it through
+// contexts, so that everything logged for a request has its request ID
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Formats of the log output
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config holds logging configuration
+type Config struct {
+	// Level is debug, info, warn or error, info when empty
+	Level string
+	// Format is json or text, json when empty
+	Format string
+}
+
+// New creates a logger writing to w
+func New(w io.Writer, config Config) (*slog.Logger, error) {
+	level, err := ParseLevel(config.Level)
+	if err != nil {
+		return nil, err
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(config.Format) {
+	case FormatJSON, "":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected json or text", config.Format)
+	}
+}
+
+// ParseLevel parses a level name like debug or warn, info when empty
+func ParseLevel(name string) (slog.Level, error) {
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// loggerKey is the context key holding the logger
+type loggerKey struct{}
+
+// WithLogger returns a context carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger of ctx, the default logger without one
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With returns a context whose logger adds args to every record
+func With(ctx context.Context, args ...interface{}) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
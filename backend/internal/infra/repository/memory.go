@@ -0,0 +1,821 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository keeps all data in process memory.
+// It is meant for tests and throwaway local runs.
+type MemoryRepository struct {
+	mu    sync.Mutex
+	state *memoryState
+}
+
+// memoryState is everything stored, cloned to roll back transactions
+type memoryState struct {
+	teams      map[string]Team
+	users      map[memberKey]memoryUser
+	tokens     map[string]Token
+	invites    map[string]Invite
+	events     []Event
+	webhooks   map[string]Webhook
+	deliveries []Delivery
+	audit      []Audit
+	seq        int64
+	eventID    int64
+	deliveryID int64
+	auditID    int64
+}
+
+// memberKey identifies a user within a team
+type memberKey struct {
+	teamID string
+	userID string
+}
+
+// memoryUser remembers insertion order to keep listings stable
+type memoryUser struct {
+	User
+	seq int64
+}
+
+// memoryTxKey is the context key marking a running in-memory transaction
+type memoryTxKey struct{}
+
+// NewMemory creates an empty in-memory repository
+func NewMemory() *MemoryRepository {
+	return &MemoryRepository{
+		state: &memoryState{
+			teams:    make(map[string]Team),
+			users:    make(map[memberKey]memoryUser),
+			tokens:   make(map[string]Token),
+			invites:  make(map[string]Invite),
+			webhooks: make(map[string]Webhook),
+		},
+	}
+}
+
+func (s *memoryState) clone() *memoryState {
+	c := &memoryState{
+		teams:      make(map[string]Team, len(s.teams)),
+		users:      make(map[memberKey]memoryUser, len(s.users)),
+		tokens:     make(map[string]Token, len(s.tokens)),
+		invites:    make(map[string]Invite, len(s.invites)),
+		events:     append([]Event(nil), s.events...),
+		webhooks:   make(map[string]Webhook, len(s.webhooks)),
+		deliveries: make([]Delivery, len(s.deliveries)),
+		audit:      append([]Audit(nil), s.audit...),
+		seq:        s.seq,
+		eventID:    s.eventID,
+		deliveryID: s.deliveryID,
+		auditID:    s.auditID,
+	}
+	for id, team := range s.teams {
+		team.DeletedAt = copyTime(team.DeletedAt)
+		c.teams[id] = team
+	}
+	for key, user := range s.users {
+		user.User = copyUser(user.User)
+		c.users[key] = user
+	}
+	for hash, token := range s.tokens {
+		c.tokens[hash] = token
+	}
+	for code, invite := range s.invites {
+		c.invites[code] = invite
+	}
+	for id, webhook := range s.webhooks {
+		c.webhooks[id] = webhook
+	}
+	for i, delivery := range s.deliveries {
+		c.deliveries[i] = copyDelivery(delivery)
+	}
+	return c
+}
+
+// lock takes the repository lock unless ctx already runs in a transaction
+// of this repository, which holds the lock for its whole duration
+func (r *MemoryRepository) lock(ctx context.Context) func() {
+	if tx, ok := ctx.Value(memoryTxKey{}).(*MemoryRepository); ok && tx == r {
+		return func() {}
+	}
+	r.mu.Lock()
+	return r.mu.Unlock
+}
+
+// WithinTx runs fn with exclusive access, restoring the previous state on error
+func (r *MemoryRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if tx, ok := ctx.Value(memoryTxKey{}).(*MemoryRepository); ok && tx == r {
+		return fn(ctx)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := r.state.clone()
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, r)); err != nil {
+		r.state = snapshot
+		return err
+	}
+
+	return nil
+}
+
+// ============================================
+// TEAM OPERATIONS
+// ============================================
+
+// CreateTeam saves a new team
+func (r *MemoryRepository) CreateTeam(ctx context.Context, team *Team) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.state.teams[team.ID]; ok {
+		return fmt.Errorf("failed to create team: %w", ErrAlreadyExists)
+	}
+
+	created := *team
+	created.Version = 1
+	created.UpdatedAt = team.CreatedAt
+	created.DeletedAt = nil
+	r.state.teams[team.ID] = created
+	return nil
+}
+
+// GetTeam retrieves a team by ID
+func (r *MemoryRepository) GetTeam(ctx context.Context, id string) (*Team, error) {
+	defer r.lock(ctx)()
+
+	team, ok := r.state.teams[id]
+	if !ok || team.DeletedAt != nil {
+		return nil, nil // Team not found is not an error
+	}
+
+	return &team, nil
+}
+
+// UpdateTeam updates the name of a team
+func (r *MemoryRepository) UpdateTeam(ctx context.Context, team *Team) error {
+	defer r.lock(ctx)()
+
+	stored, ok := r.state.teams[team.ID]
+	if !ok || stored.DeletedAt != nil {
+		return fmt.Errorf("failed to update team: %w", ErrNotFound)
+	}
+
+	stored.Name = team.Name
+	r.state.teams[team.ID] = stored
+	return nil
+}
+
+// BumpTeamVersion increments the version of a team
+func (r *MemoryRepository) BumpTeamVersion(ctx context.Context, id string, expected int64, at time.Time) (int64, error) {
+	defer r.lock(ctx)()
+
+	// Errors match the SQL implementation, which can't tell a missing
+	// team from a stale version when a version is expected
+	team, ok := r.state.teams[id]
+	if expected != 0 && (!ok || team.DeletedAt != nil || team.Version != expected) {
+		return 0, fmt.Errorf("failed to bump team version: %w", ErrVersionMismatch)
+	}
+	if !ok || team.DeletedAt != nil {
+		return 0, fmt.Errorf("failed to bump team version: %w", ErrNotFound)
+	}
+
+	team.Version++
+	team.UpdatedAt = at
+	r.state.teams[id] = team
+	return team.Version, nil
+}
+
+// DeleteTeam moves a team to the trash
+func (r *MemoryRepository) DeleteTeam(ctx context.Context, id string, at time.Time) error {
+	defer r.lock(ctx)()
+
+	team, ok := r.state.teams[id]
+	if !ok || team.DeletedAt != nil {
+		return fmt.Errorf("failed to delete team: %w", ErrNotFound)
+	}
+
+	team.DeletedAt = &at
+	r.state.teams[id] = team
+	return nil
+}
+
+// RestoreTeam brings a team back from the trash
+func (r *MemoryRepository) RestoreTeam(ctx context.Context, id string) error {
+	defer r.lock(ctx)()
+
+	team, ok := r.state.teams[id]
+	if !ok || team.DeletedAt == nil {
+		return fmt.Errorf("team is not in the trash: %w", ErrNotFound)
+	}
+
+	team.DeletedAt = nil
+	r.state.teams[id] = team
+	return nil
+}
+
+// purgeTeam removes a team and everything belonging to it, like ON
+// DELETE CASCADE does in the SQL schema. The audit log is kept.
+func (r *MemoryRepository) purgeTeam(id string) {
+	delete(r.state.teams, id)
+	for key := range r.state.users {
+		if key.teamID == id {
+			delete(r.state.users, key)
+		}
+	}
+	for hash, token := range r.state.tokens {
+		if token.TeamID == id {
+			delete(r.state.tokens, hash)
+		}
+	}
+	for code, invite := range r.state.invites {
+		if invite.TeamID == id {
+			delete(r.state.invites, code)
+		}
+	}
+
+	events := r.state.events[:0]
+	for _, event := range r.state.events {
+		if event.TeamID != id {
+			events = append(events, event)
+		}
+	}
+	r.state.events = events
+
+	for webhookID, webhook := range r.state.webhooks {
+		if webhook.TeamID == id {
+			r.deleteWebhook(webhookID)
+		}
+	}
+}
+
+// ============================================
+// USER OPERATIONS
+// ============================================
+
+// CreateUser saves a new user. A member with the same ID, even a deleted
+// one, is reported as ErrAlreadyExists.
+func (r *MemoryRepository) CreateUser(ctx context.Context, user *User) error {
+	defer r.lock(ctx)()
+
+	key := memberKey{teamID: user.TeamID, userID: user.ID}
+	if _, ok := r.state.users[key]; ok {
+		return fmt.Errorf("failed to create user: %w", ErrAlreadyExists)
+	}
+
+	created := copyUser(*user)
+	created.Version = 1
+	created.UpdatedAt = user.CreatedAt
+	created.DeletedAt = nil
+	r.state.seq++
+	r.state.users[key] = memoryUser{User: created, seq: r.state.seq}
+	return nil
+}
+
+// GetUser retrieves a member of a team by user ID
+func (r *MemoryRepository) GetUser(ctx context.Context, teamID, userID string) (*User, error) {
+	defer r.lock(ctx)()
+
+	stored, ok := r.state.users[memberKey{teamID: teamID, userID: userID}]
+	if !ok || stored.DeletedAt != nil {
+		return nil, nil // User not found is not an error
+	}
+
+	user := copyUser(stored.User)
+	return &user, nil
+}
+
+// UpdateUser updates an existing member, keeping its creation time
+func (r *MemoryRepository) UpdateUser(ctx context.Context, user *User) error {
+	defer r.lock(ctx)()
+
+	key := memberKey{teamID: user.TeamID, userID: user.ID}
+	stored, ok := r.state.users[key]
+	if !ok || stored.DeletedAt != nil || (user.Version != 0 && stored.Version != user.Version) {
+		if user.Version != 0 {
+			return fmt.Errorf("failed to update user: %w", ErrVersionMismatch)
+		}
+		return nil // Same as an UPDATE matching no rows
+	}
+
+	updated := copyUser(*user)
+	updated.CreatedAt = stored.CreatedAt
+	updated.Version = stored.Version + 1
+	updated.DeletedAt = nil
+	stored.User = updated
+	r.state.users[key] = stored
+	return nil
+}
+
+// DeleteUser moves a member of a team to the trash
+func (r *MemoryRepository) DeleteUser(ctx context.Context, teamID, userID string, at time.Time) error {
+	defer r.lock(ctx)()
+
+	key := memberKey{teamID: teamID, userID: userID}
+	stored, ok := r.state.users[key]
+	if !ok || stored.DeletedAt != nil {
+		return fmt.Errorf("user is not a member of the team: %w", ErrNotFound)
+	}
+
+	stored.DeletedAt = &at
+	r.state.users[key] = stored
+	return nil
+}
+
+// RestoreUser brings a member back from the trash
+func (r *MemoryRepository) RestoreUser(ctx context.Context, teamID, userID string) error {
+	defer r.lock(ctx)()
+
+	key := memberKey{teamID: teamID, userID: userID}
+	stored, ok := r.state.users[key]
+	if !ok || stored.DeletedAt == nil {
+		return fmt.Errorf("user is not in the trash: %w", ErrNotFound)
+	}
+
+	stored.DeletedAt = nil
+	r.state.users[key] = stored
+	return nil
+}
+
+// GetDeletedUsers retrieves the members of a team in the trash, last deleted first
+func (r *MemoryRepository) GetDeletedUsers(ctx context.Context, teamID string) ([]User, error) {
+	defer r.lock(ctx)()
+
+	var users []User
+	for _, u := range r.state.users {
+		if u.TeamID == teamID && u.DeletedAt != nil {
+			users = append(users, copyUser(u.User))
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].DeletedAt.After(*users[j].DeletedAt)
+	})
+
+	return users, nil
+}
+
+// GetTeamUsers retrieves all users for a team ordered by creation
+func (r *MemoryRepository) GetTeamUsers(ctx context.Context, teamID string) ([]User, error) {
+	defer r.lock(ctx)()
+
+	var stored []memoryUser
+	for _, u := range r.state.users {
+		if u.TeamID == teamID && u.DeletedAt == nil {
+			stored = append(stored, u)
+		}
+	}
+
+	sort.Slice(stored, func(i, j int) bool {
+		if !stored[i].CreatedAt.Equal(stored[j].CreatedAt) {
+			return stored[i].CreatedAt.Before(stored[j].CreatedAt)
+		}
+		return stored[i].seq < stored[j].seq
+	})
+
+	var users []User
+	for _, u := range stored {
+		users = append(users, copyUser(u.User))
+	}
+
+	return users, nil
+}
+
+// ============================================
+// TOKEN OPERATIONS
+// ============================================
+
+// CreateToken saves a new team secret hash
+func (r *MemoryRepository) CreateToken(ctx context.Context, token *Token) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.state.tokens[token.Hash]; ok {
+		return fmt.Errorf("failed to create token: %w", ErrAlreadyExists)
+	}
+
+	r.state.tokens[token.Hash] = *token
+	return nil
+}
+
+// GetToken retrieves a team secret by its hash
+func (r *MemoryRepository) GetToken(ctx context.Context, hash string) (*Token, error) {
+	defer r.lock(ctx)()
+
+	token, ok := r.state.tokens[hash]
+	if !ok {
+		return nil, nil // Unknown token is not an error
+	}
+
+	return &token, nil
+}
+
+// HasTeamTokens reports whether the team has any secrets
+func (r *MemoryRepository) HasTeamTokens(ctx context.Context, teamID string) (bool, error) {
+	defer r.lock(ctx)()
+
+	for _, token := range r.state.tokens {
+		if token.TeamID == teamID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// DeleteTeamTokens removes all secrets of a team with the given role
+func (r *MemoryRepository) DeleteTeamTokens(ctx context.Context, teamID, role string) error {
+	defer r.lock(ctx)()
+
+	for hash, token := range r.state.tokens {
+		if token.TeamID == teamID && token.Role == role {
+			delete(r.state.tokens, hash)
+		}
+	}
+
+	return nil
+}
+
+// ============================================
+// INVITE OPERATIONS
+// ============================================
+
+// CreateInvite saves a new invite code
+func (r *MemoryRepository) CreateInvite(ctx context.Context, invite *Invite) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.state.invites[invite.Code]; ok {
+		return fmt.Errorf("failed to create invite: %w", ErrAlreadyExists)
+	}
+
+	r.state.invites[invite.Code] = *invite
+	return nil
+}
+
+// GetInvite retrieves an invite by code
+func (r *MemoryRepository) GetInvite(ctx context.Context, code string) (*Invite, error) {
+	defer r.lock(ctx)()
+
+	invite, ok := r.state.invites[code]
+	if !ok {
+		return nil, nil // Unknown code is not an error
+	}
+
+	return &invite, nil
+}
+
+// GetTeamInvites retrieves all invites of a team, newest first
+func (r *MemoryRepository) GetTeamInvites(ctx context.Context, teamID string) ([]Invite, error) {
+	defer r.lock(ctx)()
+
+	var invites []Invite
+	for _, invite := range r.state.invites {
+		if invite.TeamID == teamID {
+			invites = append(invites, invite)
+		}
+	}
+
+	sort.Slice(invites, func(i, j int) bool {
+		return invites[i].CreatedAt.After(invites[j].CreatedAt)
+	})
+
+	return invites, nil
+}
+
+// DeleteInvite removes an invite code
+func (r *MemoryRepository) DeleteInvite(ctx context.Context, code string) error {
+	defer r.lock(ctx)()
+
+	delete(r.state.invites, code)
+	return nil
+}
+
+// UseInvite increments the use counter unless the invite is used up
+func (r *MemoryRepository) UseInvite(ctx context.Context, code string) (bool, error) {
+	defer r.lock(ctx)()
+
+	invite, ok := r.state.invites[code]
+	if !ok || (invite.MaxUses > 0 && invite.Uses >= invite.MaxUses) {
+		return false, nil
+	}
+
+	invite.Uses++
+	r.state.invites[code] = invite
+	return true, nil
+}
+
+// ============================================
+// EVENT OPERATIONS
+// ============================================
+
+// AppendEvent saves an event to the log of its team
+func (r *MemoryRepository) AppendEvent(ctx context.Context, event *Event) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.state.teams[event.TeamID]; !ok {
+		return fmt.Errorf("failed to append event: team %s does not exist", event.TeamID)
+	}
+
+	r.state.eventID++
+	event.ID = r.state.eventID
+
+	stored := *event
+	stored.Payload = append([]byte(nil), event.Payload...)
+	r.state.events = append(r.state.events, stored)
+	return nil
+}
+
+// GetEvents lists events of a team after the given ID
+func (r *MemoryRepository) GetEvents(ctx context.Context, teamID string, afterID int64, limit int) ([]Event, error) {
+	defer r.lock(ctx)()
+
+	// Events are appended in ID order
+	var events []Event
+	for _, event := range r.state.events {
+		if len(events) == limit {
+			break
+		}
+		if event.TeamID == teamID && event.ID > afterID {
+			event.Payload = append([]byte(nil), event.Payload...)
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+// GetLastEventID returns the ID of the latest event of a team
+func (r *MemoryRepository) GetLastEventID(ctx context.Context, teamID string) (int64, error) {
+	defer r.lock(ctx)()
+
+	for i := len(r.state.events) - 1; i >= 0; i-- {
+		if r.state.events[i].TeamID == teamID {
+			return r.state.events[i].ID, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// ============================================
+// WEBHOOK OPERATIONS
+// ============================================
+
+// CreateWebhook saves a new webhook
+func (r *MemoryRepository) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.state.webhooks[webhook.ID]; ok {
+		return fmt.Errorf("failed to create webhook: %w", ErrAlreadyExists)
+	}
+	if _, ok := r.state.teams[webhook.TeamID]; !ok {
+		return fmt.Errorf("failed to create webhook: team %s does not exist", webhook.TeamID)
+	}
+
+	stored := *webhook
+	stored.Events = append([]string(nil), webhook.Events...)
+	r.state.webhooks[webhook.ID] = stored
+	return nil
+}
+
+// GetWebhook retrieves a webhook by ID
+func (r *MemoryRepository) GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	defer r.lock(ctx)()
+
+	webhook, ok := r.state.webhooks[id]
+	if !ok {
+		return nil, nil
+	}
+
+	webhook.Events = append([]string(nil), webhook.Events...)
+	return &webhook, nil
+}
+
+// GetTeamWebhooks lists the webhooks of a team
+func (r *MemoryRepository) GetTeamWebhooks(ctx context.Context, teamID string) ([]Webhook, error) {
+	defer r.lock(ctx)()
+
+	var webhooks []Webhook
+	for _, webhook := range r.state.webhooks {
+		if webhook.TeamID == teamID {
+			webhook.Events = append([]string(nil), webhook.Events...)
+			webhooks = append(webhooks, webhook)
+		}
+	}
+
+	sort.Slice(webhooks, func(i, j int) bool {
+		if !webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+		}
+		return webhooks[i].ID < webhooks[j].ID
+	})
+
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook with its deliveries
+func (r *MemoryRepository) DeleteWebhook(ctx context.Context, id string) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.state.webhooks[id]; !ok {
+		return fmt.Errorf("failed to delete webhook: %w", ErrNotFound)
+	}
+
+	r.deleteWebhook(id)
+	return nil
+}
+
+// deleteWebhook removes a webhook, like ON DELETE CASCADE its deliveries too
+func (r *MemoryRepository) deleteWebhook(id string) {
+	delete(r.state.webhooks, id)
+
+	deliveries := r.state.deliveries[:0]
+	for _, delivery := range r.state.deliveries {
+		if delivery.WebhookID != id {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	r.state.deliveries = deliveries
+}
+
+// CreateDelivery queues a delivery
+func (r *MemoryRepository) CreateDelivery(ctx context.Context, delivery *Delivery) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.state.webhooks[delivery.WebhookID]; !ok {
+		return fmt.Errorf("failed to create delivery: webhook %s does not exist", delivery.WebhookID)
+	}
+
+	r.state.deliveryID++
+	delivery.ID = r.state.deliveryID
+	r.state.deliveries = append(r.state.deliveries, copyDelivery(*delivery))
+	return nil
+}
+
+// ClaimDeliveries picks due deliveries, oldest due first
+func (r *MemoryRepository) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Delivery, error) {
+	defer r.lock(ctx)()
+
+	var due []int
+	for i, delivery := range r.state.deliveries {
+		if delivery.Status == DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, i)
+		}
+	}
+
+	sort.SliceStable(due, func(a, b int) bool {
+		return r.state.deliveries[due[a]].NextAttemptAt.Before(r.state.deliveries[due[b]].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]Delivery, 0, len(due))
+	for _, i := range due {
+		r.state.deliveries[i].NextAttemptAt = leaseUntil
+		claimed = append(claimed, copyDelivery(r.state.deliveries[i]))
+	}
+
+	return claimed, nil
+}
+
+// UpdateDelivery saves the outcome of an attempt
+func (r *MemoryRepository) UpdateDelivery(ctx context.Context, delivery *Delivery) error {
+	defer r.lock(ctx)()
+
+	for i := range r.state.deliveries {
+		stored := &r.state.deliveries[i]
+		if stored.ID != delivery.ID {
+			continue
+		}
+
+		stored.Status = delivery.Status
+		stored.Attempts = delivery.Attempts
+		stored.NextAttemptAt = delivery.NextAttemptAt
+		stored.LastAttemptAt = copyTime(delivery.LastAttemptAt)
+		stored.ResponseStatus = delivery.ResponseStatus
+		stored.LastError = delivery.LastError
+		return nil
+	}
+
+	return fmt.Errorf("failed to update delivery: %w", ErrNotFound)
+}
+
+// GetDeliveries lists the deliveries of a webhook, newest first
+func (r *MemoryRepository) GetDeliveries(ctx context.Context, webhookID string, limit int) ([]Delivery, error) {
+	defer r.lock(ctx)()
+
+	// Deliveries are appended in ID order
+	var deliveries []Delivery
+	for i := len(r.state.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if r.state.deliveries[i].WebhookID == webhookID {
+			deliveries = append(deliveries, copyDelivery(r.state.deliveries[i]))
+		}
+	}
+
+	return deliveries, nil
+}
+
+// ============================================
+// AUDIT OPERATIONS
+// ============================================
+
+// AppendAudit saves an entry to the audit log
+func (r *MemoryRepository) AppendAudit(ctx context.Context, entry *Audit) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.state.teams[entry.TeamID]; !ok {
+		return fmt.Errorf("failed to append audit entry: team %s does not exist", entry.TeamID)
+	}
+
+	r.state.auditID++
+	entry.ID = r.state.auditID
+
+	stored := *entry
+	stored.Before = append([]byte(nil), entry.Before...)
+	stored.After = append([]byte(nil), entry.After...)
+	r.state.audit = append(r.state.audit, stored)
+	return nil
+}
+
+// GetAudit lists entries of the audit log of a team
+func (r *MemoryRepository) GetAudit(ctx context.Context, query AuditQuery) ([]Audit, error) {
+	defer r.lock(ctx)()
+
+	// Entries are appended in ID order, walk them newest first
+	var entries []Audit
+	for i := len(r.state.audit) - 1; i >= 0 && len(entries) < query.Limit; i-- {
+		entry := r.state.audit[i]
+		if entry.TeamID != query.TeamID {
+			continue
+		}
+		if query.BeforeID > 0 && entry.ID >= query.BeforeID {
+			continue
+		}
+		if !query.From.IsZero() && entry.CreatedAt.Before(query.From) {
+			continue
+		}
+		if !query.To.IsZero() && !entry.CreatedAt.Before(query.To) {
+			continue
+		}
+
+		entry.Before = append([]byte(nil), entry.Before...)
+		entry.After = append([]byte(nil), entry.After...)
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ============================================
+// TRASH OPERATIONS
+// ============================================
+
+// PurgeDeleted removes teams and members deleted before the given time
+func (r *MemoryRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	defer r.lock(ctx)()
+
+	var purged int64
+	for key, user := range r.state.users {
+		if user.DeletedAt != nil && user.DeletedAt.Before(before) {
+			delete(r.state.users, key)
+			purged++
+		}
+	}
+	for id, team := range r.state.teams {
+		if team.DeletedAt != nil && team.DeletedAt.Before(before) {
+			r.purgeTeam(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// copyUser detaches the name slices so callers can't mutate stored data
+func copyUser(user User) User {
+	user.ParentNames = append([]string(nil), user.ParentNames...)
+	user.GrandParentsNames = append([]string(nil), user.GrandParentsNames...)
+	user.DeletedAt = copyTime(user.DeletedAt)
+	return user
+}
+
+// copyDelivery detaches the payload and times of a delivery
+func copyDelivery(delivery Delivery) Delivery {
+	delivery.Payload = append([]byte(nil), delivery.Payload...)
+	delivery.LastAttemptAt = copyTime(delivery.LastAttemptAt)
+	return delivery
+}
+
+// copyTime detaches a stored time pointer
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
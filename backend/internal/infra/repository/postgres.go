@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// postgresDialect uses PostgreSQL's numbered `$n` placeholders
+type postgresDialect struct{}
+
+func (postgresDialect) rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// NewPostgres creates a repository backed by PostgreSQL
+func NewPostgres(db *sql.DB, opts ...SQLOption) *SQLRepository {
+	return newSQL(db, postgresDialect{}, opts)
+}
+
+// isRetryable reports serialization failures and deadlocks
+func (postgresDialect) isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+	return false
+}
+
+// isUniqueViolation reports unique_violation errors
+func (postgresDialect) isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	return false
+}
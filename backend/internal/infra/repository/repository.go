@@ -1,20 +1,35 @@
 package repository
 
 import (
-	"database/sql"
-	"encoding/json"
-	"fmt"
+	"context"
 	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
 )
 
+// ErrAlreadyExists is returned when creating a record whose key is taken
+var ErrAlreadyExists = domain.Conflict("already_exists", "already exists")
+
+// ErrVersionMismatch is returned when a record changed since the
+// version a write expects
+var ErrVersionMismatch = domain.PreconditionFailed("version_mismatch", "version does not match")
+
+// ErrNotFound is returned when changing or deleting a missing record.
+// Lookups return nil, nil instead.
+var ErrNotFound = domain.NotFound("not_found", "not found")
+
 // Team represents a stored team
 type Team struct {
 	ID        string
 	Name      string
+	Version   int64
 	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time // set while the team is in the trash
 }
 
-// User represents a stored user
+// User represents a stored team member. Users are identified by
+// (TeamID, ID), the same ID in two teams refers to two independent members.
 type User struct {
 	ID                string
 	TeamID            string
@@ -23,232 +38,246 @@ type User struct {
 	ParentNames       []string
 	GrandParentsNames []string
 	Country           string
+	Version           int64
 	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         *time.Time // set while the member is in the trash
+}
+
+// Token is a hashed team secret granting a role
+type Token struct {
+	Hash      string
+	TeamID    string
+	Role      string
+	CreatedAt time.Time
+}
+
+// Invite is a code that lets people join a team
+type Invite struct {
+	Code      string
+	TeamID    string
+	Role      string
+	MaxUses   int // 0 means unlimited
+	Uses      int
+	ExpiresAt *time.Time
+	CreatedAt time.Time
+}
+
+// Event is a stored change of a team. Payload holds the JSON of the
+// event as sent to clients.
+type Event struct {
+	ID        int64
+	TeamID    string
+	Type      string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// Webhook is a registered receiver of team events
+type Webhook struct {
+	ID        string
+	TeamID    string
+	URL       string
+	Secret    string
+	Events    []string // event types to deliver, empty means all
+	CreatedAt time.Time
 }
 
-// Repository handles database operations
-type Repository struct {
-	db *sql.DB
+// Delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed" // out of attempts
+)
+
+// Delivery is one event sent to one webhook, with the outcome of the
+// latest attempt
+type Delivery struct {
+	ID             int64
+	WebhookID      string
+	EventID        int64
+	EventType      string
+	Payload        []byte
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastAttemptAt  *time.Time
+	ResponseStatus int // 0 when no response was received
+	LastError      string
+	CreatedAt      time.Time
 }
 
-// New creates a new repository
-func New(db *sql.DB) *Repository {
-	return &Repository{db: db}
+// Audit is one entry of the audit log. Before and After hold JSON
+// snapshots of the target, nil when there is none.
+type Audit struct {
+	ID         int64
+	TeamID     string
+	Action     string
+	ActorType  string
+	ActorRole  string
+	ActorID    string
+	TargetType string
+	TargetID   string
+	Before     []byte
+	After      []byte
+	RequestID  string
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time
 }
 
-// ============================================
-// TEAM OPERATIONS
-// ============================================
-
-// CreateTeam saves a new team to the database
-func (r *Repository) CreateTeam(team *Team) error {
-	query := `INSERT INTO teams (id, name, created_at)
-			  VALUES (?, ?, ?)`
-
-	_, err := r.db.Exec(query,
-		team.ID,
-		team.Name,
-		team.CreatedAt,
-	)
-
-	if err != nil {
-		return fmt.Errorf("failed to create team: %w", err)
-	}
-
-	return nil
+// AuditQuery selects entries of the audit log of a team
+type AuditQuery struct {
+	TeamID string
+	// From and To bound CreatedAt, From inclusive and To exclusive.
+	// Zero values leave the range open.
+	From time.Time
+	To   time.Time
+	// BeforeID continues a listing below the last ID seen, 0 starts at the newest
+	BeforeID int64
+	Limit    int
 }
 
-// GetTeam retrieves a team by ID
-func (r *Repository) GetTeam(id string) (*Team, error) {
-	query := `SELECT id, name, created_at
-			  FROM teams WHERE id = ?`
+// TeamRepository stores teams. Deleted teams stay in the trash until
+// purged and are invisible to everything but RestoreTeam.
+type TeamRepository interface {
+	// CreateTeam returns ErrAlreadyExists when the ID is taken,
+	// also by a team in the trash
+	CreateTeam(ctx context.Context, team *Team) error
+	// GetTeam returns nil, nil when the team does not exist or is deleted
+	GetTeam(ctx context.Context, id string) (*Team, error)
+	// UpdateTeam saves the name of a team, ErrNotFound when it does not
+	// exist. The version is changed by BumpTeamVersion only.
+	UpdateTeam(ctx context.Context, team *Team) error
+	// BumpTeamVersion increments the version of a team and returns the new
+	// one. A non-zero expected version must match, otherwise
+	// ErrVersionMismatch is returned.
+	BumpTeamVersion(ctx context.Context, id string, expected int64, at time.Time) (int64, error)
+	// DeleteTeam moves a team to the trash, keeping its members, secrets
+	// and invites. It returns ErrNotFound when the team does not exist.
+	DeleteTeam(ctx context.Context, id string, at time.Time) error
+	// RestoreTeam returns ErrNotFound when the team is not in the trash
+	RestoreTeam(ctx context.Context, id string) error
+}
 
-	team := &Team{}
-	err := r.db.QueryRow(query, id).Scan(
-		&team.ID,
-		&team.Name,
-		&team.CreatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, nil // Team not found is not an error
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to get team: %w", err)
-	}
-
-	return team, nil
-}
-
-// ============================================
-// USER OPERATIONS
-// ============================================
-
-// CreateUser saves a new user to the database
-func (r *Repository) CreateUser(user *User) error {
-	parentNamesJSON, err := json.Marshal(user.ParentNames)
-	if err != nil {
-		return fmt.Errorf("failed to marshal parent_names: %w", err)
-	}
-
-	grandParentsNamesJSON, err := json.Marshal(user.GrandParentsNames)
-	if err != nil {
-		return fmt.Errorf("failed to marshal grandparent_names: %w", err)
-	}
-
-	query := `INSERT INTO users (id, team_id, first_name, initials, parent_names, grandparent_names, country, created_at)
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-
-	_, err = r.db.Exec(query,
-		user.ID,
-		user.TeamID,
-		user.FirstName,
-		user.Initials,
-		string(parentNamesJSON),
-		string(grandParentsNamesJSON),
-		user.Country,
-		user.CreatedAt,
-	)
+// UserRepository stores team members. Like teams, deleted members stay
+// in the trash and are only visible through GetDeletedUsers.
+type UserRepository interface {
+	// CreateUser returns ErrAlreadyExists when the user is already in the
+	// team or in its trash
+	CreateUser(ctx context.Context, user *User) error
+	// GetUser returns nil, nil when the user is not a member of the team
+	GetUser(ctx context.Context, teamID, userID string) (*User, error)
+	// UpdateUser saves a member and increments its version. A non-zero
+	// user.Version must match, otherwise ErrVersionMismatch is returned.
+	UpdateUser(ctx context.Context, user *User) error
+	// DeleteUser moves a member to the trash, ErrNotFound when it is not a member
+	DeleteUser(ctx context.Context, teamID, userID string, at time.Time) error
+	// RestoreUser returns ErrNotFound when the member is not in the trash
+	RestoreUser(ctx context.Context, teamID, userID string) error
+	GetTeamUsers(ctx context.Context, teamID string) ([]User, error)
+	// GetDeletedUsers lists the members in the trash, last deleted first
+	GetDeletedUsers(ctx context.Context, teamID string) ([]User, error)
+}
 
-	if err != nil {
-		return fmt.Errorf("failed to create user: %w", err)
-	}
+// TrashRepository empties the trash
+type TrashRepository interface {
+	// PurgeDeleted permanently removes teams and members deleted before
+	// the given time and returns how many were removed
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+}
+
+// TokenRepository stores team secrets
+type TokenRepository interface {
+	CreateToken(ctx context.Context, token *Token) error
+	// GetToken returns nil, nil when no token has this hash
+	GetToken(ctx context.Context, hash string) (*Token, error)
+	// HasTeamTokens reports whether the team has any secret at all
+	HasTeamTokens(ctx context.Context, teamID string) (bool, error)
+	// DeleteTeamTokens revokes all secrets of a team with the given role
+	DeleteTeamTokens(ctx context.Context, teamID, role string) error
+}
+
+// InviteRepository stores invite codes
+type InviteRepository interface {
+	// CreateInvite returns ErrAlreadyExists when the code is taken
+	CreateInvite(ctx context.Context, invite *Invite) error
+	// GetInvite returns nil, nil when the code does not exist
+	GetInvite(ctx context.Context, code string) (*Invite, error)
+	// GetTeamInvites lists the invites of a team, newest first
+	GetTeamInvites(ctx context.Context, teamID string) ([]Invite, error)
+	DeleteInvite(ctx context.Context, code string) error
+	// UseInvite counts one use of an invite. It returns false without
+	// changing anything when the invite has no uses left.
+	UseInvite(ctx context.Context, code string) (bool, error)
+}
+
+// EventRepository stores the log of team changes
+type EventRepository interface {
+	// AppendEvent stores an event and sets its ID. IDs increase with every
+	// event; those of one team are ordered as long as the team row is
+	// changed first in the same transaction.
+	AppendEvent(ctx context.Context, event *Event) error
+	// GetEvents lists up to limit events of a team with IDs above afterID,
+	// oldest first
+	GetEvents(ctx context.Context, teamID string, afterID int64, limit int) ([]Event, error)
+	// GetLastEventID returns the ID of the latest event of a team,
+	// 0 when there is none
+	GetLastEventID(ctx context.Context, teamID string) (int64, error)
+}
+
+// WebhookRepository stores webhooks and their delivery queue
+type WebhookRepository interface {
+	// CreateWebhook returns ErrAlreadyExists when the ID is taken
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	// GetWebhook returns nil, nil when the webhook does not exist
+	GetWebhook(ctx context.Context, id string) (*Webhook, error)
+	// GetTeamWebhooks lists the webhooks of a team, oldest first
+	GetTeamWebhooks(ctx context.Context, teamID string) ([]Webhook, error)
+	// DeleteWebhook removes a webhook with its deliveries,
+	// ErrNotFound when it does not exist
+	DeleteWebhook(ctx context.Context, id string) error
+	// CreateDelivery queues a delivery and sets its ID
+	CreateDelivery(ctx context.Context, delivery *Delivery) error
+	// ClaimDeliveries picks up to limit pending deliveries due at now and
+	// postpones them to leaseUntil, so that no other worker picks them up
+	// while they are being sent
+	ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Delivery, error)
+	// UpdateDelivery saves the outcome of an attempt,
+	// ErrNotFound when the delivery is gone
+	UpdateDelivery(ctx context.Context, delivery *Delivery) error
+	// GetDeliveries lists up to limit deliveries of a webhook, newest first
+	GetDeliveries(ctx context.Context, webhookID string, limit int) ([]Delivery, error)
+}
+
+// AuditRepository stores the audit log
+type AuditRepository interface {
+	// AppendAudit stores an entry and sets its ID
+	AppendAudit(ctx context.Context, entry *Audit) error
+	// GetAudit lists entries matching the query, newest first
+	GetAudit(ctx context.Context, query AuditQuery) ([]Audit, error)
+}
+
+// Transactor runs a unit of work in a single transaction
+type Transactor interface {
+	// WithinTx calls fn with a context bound to a transaction. Repository
+	// calls made with that context take part in the transaction, which is
+	// committed when fn returns nil and rolled back otherwise. Transient
+	// conflicts (e.g. SQLITE_BUSY) re-run fn from the start, so fn must not
+	// have side effects outside the repository. Nested calls join the
+	// outer transaction.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
 
-	return nil
-}
-
-// GetUser retrieves a user by ID
-func (r *Repository) GetUser(id string) (*User, error) {
-	query := `SELECT id, team_id, first_name, initials, parent_names, grandparent_names, country, created_at
-			  FROM users WHERE id = ?`
-
-	user := &User{}
-	var parentNamesJSON string
-	var grandParentsNamesJSON string
-
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID,
-		&user.TeamID,
-		&user.FirstName,
-		&user.Initials,
-		&parentNamesJSON,
-		&grandParentsNamesJSON,
-		&user.Country,
-		&user.CreatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, nil // User not found is not an error
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
-	}
-
-	if err := json.Unmarshal([]byte(parentNamesJSON), &user.ParentNames); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal parent_names: %w", err)
-	}
-
-	if err := json.Unmarshal([]byte(grandParentsNamesJSON), &user.GrandParentsNames); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal grandparent_names: %w", err)
-	}
-
-	return user, nil
-}
-
-// UpdateUser updates an existing user in the database
-func (r *Repository) UpdateUser(user *User) error {
-	parentNamesJSON, err := json.Marshal(user.ParentNames)
-	if err != nil {
-		return fmt.Errorf("failed to marshal parent_names: %w", err)
-	}
-
-	grandParentsNamesJSON, err := json.Marshal(user.GrandParentsNames)
-	if err != nil {
-		return fmt.Errorf("failed to marshal grandparent_names: %w", err)
-	}
-
-	query := `UPDATE users 
-			  SET team_id = ?, first_name = ?, initials = ?, parent_names = ?, grandparent_names = ?, country = ?
-			  WHERE id = ?`
-
-	_, err = r.db.Exec(query,
-		user.TeamID,
-		user.FirstName,
-		user.Initials,
-		string(parentNamesJSON),
-		string(grandParentsNamesJSON),
-		user.Country,
-		user.ID,
-	)
-
-	if err != nil {
-		return fmt.Errorf("failed to update user: %w", err)
-	}
-
-	return nil
-}
-
-// DeleteUser removes a user from the database
-func (r *Repository) DeleteUser(teamID, userID string) error {
-	query := `DELETE FROM users WHERE id = ? AND team_id = ?`
-
-	result, err := r.db.Exec(query, userID, teamID)
-	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("user not found or does not belong to team")
-	}
-
-	return nil
-}
-
-// GetTeamUsers retrieves all users for a team
-func (r *Repository) GetTeamUsers(teamID string) ([]User, error) {
-	query := `SELECT id, team_id, first_name, initials, parent_names, grandparent_names, country, created_at
-			  FROM users WHERE team_id = ? ORDER BY created_at ASC`
-
-	rows, err := r.db.Query(query, teamID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get team users: %w", err)
-	}
-	defer rows.Close()
-
-	var users []User
-	for rows.Next() {
-		var user User
-		var parentNamesJSON string
-		var grandParentsNamesJSON string
-
-		if err := rows.Scan(
-			&user.ID,
-			&user.TeamID,
-			&user.FirstName,
-			&user.Initials,
-			&parentNamesJSON,
-			&grandParentsNamesJSON,
-			&user.Country,
-			&user.CreatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan user: %w", err)
-		}
-
-		if err := json.Unmarshal([]byte(parentNamesJSON), &user.ParentNames); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal parent_names: %w", err)
-		}
-
-		if err := json.Unmarshal([]byte(grandParentsNamesJSON), &user.GrandParentsNames); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal grandparent_names: %w", err)
-		}
-
-		users = append(users, user)
-	}
-
-	return users, nil
+// Repository is the full storage interface used by the usecases
+type Repository interface {
+	Transactor
+	TeamRepository
+	UserRepository
+	TokenRepository
+	InviteRepository
+	TrashRepository
+	EventRepository
+	WebhookRepository
+	AuditRepository
 }
@@ -0,0 +1,1269 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/metrics"
+)
+
+// SQLRepository handles database operations for SQL databases.
+// Queries are written with `?` placeholders and rebound by the dialect.
+type SQLRepository struct {
+	db      *sql.DB
+	dialect dialect
+	// queries measures query durations by statement, nil when not measured
+	queries *metrics.Histogram
+	// timeout bounds every query, no bound when zero
+	timeout time.Duration
+}
+
+// SQLOption configures optional features of a SQLRepository
+type SQLOption func(*SQLRepository)
+
+// WithQueryMetrics measures the duration of every query in reg
+func WithQueryMetrics(reg *metrics.Registry) SQLOption {
+	return func(r *SQLRepository) {
+		r.queries = reg.NewHistogram(metrics.Namespace+"db_query_duration_seconds",
+			"Duration of database queries by statement.", nil, "statement")
+	}
+}
+
+// WithQueryTimeout aborts queries running longer than timeout, like
+// db.DB.QueryTimeout. Zero leaves queries bounded by their context only.
+func WithQueryTimeout(timeout time.Duration) SQLOption {
+	return func(r *SQLRepository) {
+		r.timeout = timeout
+	}
+}
+
+// newSQL creates a repository for a dialect
+func newSQL(db *sql.DB, dialect dialect, opts []SQLOption) *SQLRepository {
+	r := &SQLRepository{db: db, dialect: dialect}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// dialect holds the differences between SQL databases
+type dialect interface {
+	// rebind converts `?` placeholders into the database's own syntax
+	rebind(query string) string
+	// isRetryable reports whether a transaction failed on a transient conflict
+	isRetryable(err error) bool
+	// isUniqueViolation reports whether a write failed on a duplicate key
+	isUniqueViolation(err error) bool
+}
+
+// exec runs a statement that does not return rows
+func (r *SQLRepository) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer r.observe(query, time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return r.conn(ctx).ExecContext(ctx, r.dialect.rebind(query), args...)
+}
+
+// query runs a statement that returns rows. The timeout of the query
+// lasts until the rows are closed.
+func (r *SQLRepository) query(ctx context.Context, query string, args ...interface{}) (*rows, error) {
+	defer r.observe(query, time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	result, err := r.conn(ctx).QueryContext(ctx, r.dialect.rebind(query), args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &rows{Rows: result, cancel: cancel}, nil
+}
+
+// queryRow runs a statement that returns at most one row. The timeout of
+// the query lasts until the row is scanned.
+func (r *SQLRepository) queryRow(ctx context.Context, query string, args ...interface{}) *row {
+	defer r.observe(query, time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	return &row{Row: r.conn(ctx).QueryRowContext(ctx, r.dialect.rebind(query), args...), cancel: cancel}
+}
+
+// withTimeout bounds a query by the query timeout. Canceling ctx, like a
+// client disconnecting, aborts the query either way.
+func (r *SQLRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+// rows releases the timeout of a query once closed
+type rows struct {
+	*sql.Rows
+	cancel context.CancelFunc
+}
+
+func (r *rows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+// row releases the timeout of a query once scanned
+type row struct {
+	*sql.Row
+	cancel context.CancelFunc
+}
+
+func (r *row) Scan(dest ...interface{}) error {
+	defer r.cancel()
+	return r.Row.Scan(dest...)
+}
+
+// observe records the duration of a query started at start
+func (r *SQLRepository) observe(query string, start time.Time) {
+	if r.queries == nil {
+		return
+	}
+	r.queries.Observe(time.Since(start).Seconds(), statementOf(query))
+}
+
+// statementOf returns the kind of a query, like select or insert
+func statementOf(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "other"
+	}
+
+	switch statement := strings.ToLower(fields[0]); statement {
+	case "select", "insert", "update", "delete", "with":
+		return statement
+	default:
+		return "other"
+	}
+}
+
+// ============================================
+// TEAM OPERATIONS
+// ============================================
+
+// CreateTeam saves a new team to the database.
+// A taken ID is detected with ON CONFLICT instead of a failing statement,
+// so the caller can retry inside the same transaction.
+func (r *SQLRepository) CreateTeam(ctx context.Context, team *Team) error {
+	query := `INSERT INTO teams (id, name, version, created_at, updated_at)
+			  VALUES (?, ?, 1, ?, ?)
+			  ON CONFLICT (id) DO NOTHING`
+
+	result, err := r.exec(ctx, query,
+		team.ID,
+		team.Name,
+		team.CreatedAt,
+		team.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create team: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("failed to create team: %w", ErrAlreadyExists)
+	}
+
+	return nil
+}
+
+// GetTeam retrieves a team by ID
+func (r *SQLRepository) GetTeam(ctx context.Context, id string) (*Team, error) {
+	query := `SELECT id, name, version, created_at, updated_at
+			  FROM teams WHERE id = ? AND deleted_at IS NULL`
+
+	team := &Team{}
+	err := r.queryRow(ctx, query, id).Scan(
+		&team.ID,
+		&team.Name,
+		&team.Version,
+		&team.CreatedAt,
+		&team.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil // Team not found is not an error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team: %w", err)
+	}
+
+	return team, nil
+}
+
+// UpdateTeam updates the name of a team
+func (r *SQLRepository) UpdateTeam(ctx context.Context, team *Team) error {
+	query := `UPDATE teams SET name = ? WHERE id = ? AND deleted_at IS NULL`
+
+	result, err := r.exec(ctx, query, team.Name, team.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update team: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("failed to update team: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// BumpTeamVersion increments the version of a team, which every change
+// of the team or its members does. A non-zero expected version must
+// match the current one, otherwise ErrVersionMismatch is returned.
+func (r *SQLRepository) BumpTeamVersion(ctx context.Context, id string, expected int64, at time.Time) (int64, error) {
+	query := `UPDATE teams SET version = version + 1, updated_at = ?
+			  WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)
+			  RETURNING version`
+
+	var version int64
+	err := r.queryRow(ctx, query, at, id, expected, expected).Scan(&version)
+	if err == sql.ErrNoRows {
+		if expected != 0 {
+			return 0, fmt.Errorf("failed to bump team version: %w", ErrVersionMismatch)
+		}
+		return 0, fmt.Errorf("failed to bump team version: %w", ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to bump team version: %w", err)
+	}
+
+	return version, nil
+}
+
+// DeleteTeam moves a team to the trash
+func (r *SQLRepository) DeleteTeam(ctx context.Context, id string, at time.Time) error {
+	query := `UPDATE teams SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`
+
+	result, err := r.exec(ctx, query, at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete team: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("failed to delete team: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// RestoreTeam brings a team back from the trash
+func (r *SQLRepository) RestoreTeam(ctx context.Context, id string) error {
+	query := `UPDATE teams SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`
+
+	result, err := r.exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore team: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("team is not in the trash: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// ============================================
+// USER OPERATIONS
+// ============================================
+
+// userColumns are the columns read by scanUser
+const userColumns = `user_id, team_id, first_name, initials, parent_names, grandparent_names, country, version, created_at, updated_at, deleted_at`
+
+// CreateUser saves a new user to the database. A member with the same ID,
+// even a deleted one, is reported as ErrAlreadyExists.
+func (r *SQLRepository) CreateUser(ctx context.Context, user *User) error {
+	parentNamesJSON, err := json.Marshal(user.ParentNames)
+	if err != nil {
+		return fmt.Errorf("failed to marshal parent_names: %w", err)
+	}
+
+	grandParentsNamesJSON, err := json.Marshal(user.GrandParentsNames)
+	if err != nil {
+		return fmt.Errorf("failed to marshal grandparent_names: %w", err)
+	}
+
+	// Doing nothing on conflict keeps the transaction usable, so that the
+	// caller can find out whether the member is in the trash
+	query := `INSERT INTO team_members (team_id, user_id, first_name, initials, parent_names, grandparent_names, country, version, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?)
+			  ON CONFLICT (team_id, user_id) DO NOTHING`
+
+	result, err := r.exec(ctx, query,
+		user.TeamID,
+		user.ID,
+		user.FirstName,
+		user.Initials,
+		string(parentNamesJSON),
+		string(grandParentsNamesJSON),
+		user.Country,
+		user.CreatedAt,
+		user.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("failed to create user: %w", ErrAlreadyExists)
+	}
+
+	return nil
+}
+
+// GetUser retrieves a member of a team by user ID
+func (r *SQLRepository) GetUser(ctx context.Context, teamID, userID string) (*User, error) {
+	query := `SELECT ` + userColumns + `
+			  FROM team_members WHERE team_id = ? AND user_id = ? AND deleted_at IS NULL`
+
+	user, err := scanUser(r.queryRow(ctx, query, teamID, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil // User not found is not an error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// UpdateUser updates an existing member of a team and increments its
+// version. A non-zero user.Version must match the stored version.
+func (r *SQLRepository) UpdateUser(ctx context.Context, user *User) error {
+	parentNamesJSON, err := json.Marshal(user.ParentNames)
+	if err != nil {
+		return fmt.Errorf("failed to marshal parent_names: %w", err)
+	}
+
+	grandParentsNamesJSON, err := json.Marshal(user.GrandParentsNames)
+	if err != nil {
+		return fmt.Errorf("failed to marshal grandparent_names: %w", err)
+	}
+
+	query := `UPDATE team_members
+			  SET first_name = ?, initials = ?, parent_names = ?, grandparent_names = ?, country = ?,
+			      version = version + 1, updated_at = ?
+			  WHERE team_id = ? AND user_id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)`
+
+	result, err := r.exec(ctx, query,
+		user.FirstName,
+		user.Initials,
+		string(parentNamesJSON),
+		string(grandParentsNamesJSON),
+		user.Country,
+		user.UpdatedAt,
+		user.TeamID,
+		user.ID,
+		user.Version,
+		user.Version,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 && user.Version != 0 {
+		return fmt.Errorf("failed to update user: %w", ErrVersionMismatch)
+	}
+
+	return nil
+}
+
+// DeleteUser moves a member of a team to the trash
+func (r *SQLRepository) DeleteUser(ctx context.Context, teamID, userID string, at time.Time) error {
+	query := `UPDATE team_members SET deleted_at = ?
+			  WHERE team_id = ? AND user_id = ? AND deleted_at IS NULL`
+
+	result, err := r.exec(ctx, query, at.UTC(), teamID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user is not a member of the team: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// RestoreUser brings a member back from the trash
+func (r *SQLRepository) RestoreUser(ctx context.Context, teamID, userID string) error {
+	query := `UPDATE team_members SET deleted_at = NULL
+			  WHERE team_id = ? AND user_id = ? AND deleted_at IS NOT NULL`
+
+	result, err := r.exec(ctx, query, teamID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to restore user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user is not in the trash: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetTeamUsers retrieves all users for a team
+func (r *SQLRepository) GetTeamUsers(ctx context.Context, teamID string) ([]User, error) {
+	query := `SELECT ` + userColumns + `
+			  FROM team_members WHERE team_id = ? AND deleted_at IS NULL ORDER BY created_at ASC`
+
+	return r.queryUsers(ctx, query, teamID)
+}
+
+// GetDeletedUsers retrieves the members of a team in the trash
+func (r *SQLRepository) GetDeletedUsers(ctx context.Context, teamID string) ([]User, error) {
+	query := `SELECT ` + userColumns + `
+			  FROM team_members WHERE team_id = ? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC`
+
+	return r.queryUsers(ctx, query, teamID)
+}
+
+// queryUsers runs a query selecting userColumns
+func (r *SQLRepository) queryUsers(ctx context.Context, query string, args ...interface{}) ([]User, error) {
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, *user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get team users: %w", err)
+	}
+
+	return users, nil
+}
+
+// ============================================
+// TOKEN OPERATIONS
+// ============================================
+
+// CreateToken saves a new team secret hash
+func (r *SQLRepository) CreateToken(ctx context.Context, token *Token) error {
+	query := `INSERT INTO team_tokens (token_hash, team_id, role, created_at)
+			  VALUES (?, ?, ?, ?)`
+
+	_, err := r.exec(ctx, query,
+		token.Hash,
+		token.TeamID,
+		token.Role,
+		token.CreatedAt,
+	)
+
+	if r.dialect.isUniqueViolation(err) {
+		return fmt.Errorf("failed to create token: %w", ErrAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create token: %w", err)
+	}
+
+	return nil
+}
+
+// GetToken retrieves a team secret by its hash
+func (r *SQLRepository) GetToken(ctx context.Context, hash string) (*Token, error) {
+	query := `SELECT token_hash, team_id, role, created_at
+			  FROM team_tokens WHERE token_hash = ?`
+
+	token := &Token{}
+	err := r.queryRow(ctx, query, hash).Scan(
+		&token.Hash,
+		&token.TeamID,
+		&token.Role,
+		&token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil // Unknown token is not an error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	return token, nil
+}
+
+// HasTeamTokens reports whether the team has any secrets
+func (r *SQLRepository) HasTeamTokens(ctx context.Context, teamID string) (bool, error) {
+	query := `SELECT COUNT(*) FROM team_tokens WHERE team_id = ?`
+
+	var count int
+	if err := r.queryRow(ctx, query, teamID).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to count team tokens: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// DeleteTeamTokens removes all secrets of a team with the given role
+func (r *SQLRepository) DeleteTeamTokens(ctx context.Context, teamID, role string) error {
+	query := `DELETE FROM team_tokens WHERE team_id = ? AND role = ?`
+
+	if _, err := r.exec(ctx, query, teamID, role); err != nil {
+		return fmt.Errorf("failed to delete team tokens: %w", err)
+	}
+
+	return nil
+}
+
+// ============================================
+// INVITE OPERATIONS
+// ============================================
+
+// CreateInvite saves a new invite code. Like CreateTeam, a taken code
+// does not abort the surrounding transaction.
+func (r *SQLRepository) CreateInvite(ctx context.Context, invite *Invite) error {
+	query := `INSERT INTO team_invites (code, team_id, role, max_uses, uses, expires_at, created_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?)
+			  ON CONFLICT (code) DO NOTHING`
+
+	var maxUses sql.NullInt64
+	if invite.MaxUses > 0 {
+		maxUses = sql.NullInt64{Int64: int64(invite.MaxUses), Valid: true}
+	}
+
+	var expiresAt sql.NullTime
+	if invite.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *invite.ExpiresAt, Valid: true}
+	}
+
+	result, err := r.exec(ctx, query,
+		invite.Code,
+		invite.TeamID,
+		invite.Role,
+		maxUses,
+		invite.Uses,
+		expiresAt,
+		invite.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create invite: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("failed to create invite: %w", ErrAlreadyExists)
+	}
+
+	return nil
+}
+
+// GetInvite retrieves an invite by code
+func (r *SQLRepository) GetInvite(ctx context.Context, code string) (*Invite, error) {
+	query := `SELECT code, team_id, role, max_uses, uses, expires_at, created_at
+			  FROM team_invites WHERE code = ?`
+
+	invite, err := scanInvite(r.queryRow(ctx, query, code))
+	if err == sql.ErrNoRows {
+		return nil, nil // Unknown code is not an error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite: %w", err)
+	}
+
+	return invite, nil
+}
+
+// GetTeamInvites retrieves all invites of a team
+func (r *SQLRepository) GetTeamInvites(ctx context.Context, teamID string) ([]Invite, error) {
+	query := `SELECT code, team_id, role, max_uses, uses, expires_at, created_at
+			  FROM team_invites WHERE team_id = ? ORDER BY created_at DESC`
+
+	rows, err := r.query(ctx, query, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team invites: %w", err)
+	}
+	defer rows.Close()
+
+	var invites []Invite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invite: %w", err)
+		}
+		invites = append(invites, *invite)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get team invites: %w", err)
+	}
+
+	return invites, nil
+}
+
+// DeleteInvite removes an invite code
+func (r *SQLRepository) DeleteInvite(ctx context.Context, code string) error {
+	query := `DELETE FROM team_invites WHERE code = ?`
+
+	if _, err := r.exec(ctx, query, code); err != nil {
+		return fmt.Errorf("failed to delete invite: %w", err)
+	}
+
+	return nil
+}
+
+// UseInvite increments the use counter unless the invite is used up
+func (r *SQLRepository) UseInvite(ctx context.Context, code string) (bool, error) {
+	query := `UPDATE team_invites SET uses = uses + 1
+			  WHERE code = ? AND (max_uses IS NULL OR uses < max_uses)`
+
+	result, err := r.exec(ctx, query, code)
+	if err != nil {
+		return false, fmt.Errorf("failed to use invite: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// ============================================
+// EVENT OPERATIONS
+// ============================================
+
+// AppendEvent saves an event to the log of its team
+func (r *SQLRepository) AppendEvent(ctx context.Context, event *Event) error {
+	query := `INSERT INTO team_events (team_id, type, payload, created_at)
+			  VALUES (?, ?, ?, ?)
+			  RETURNING id`
+
+	err := r.queryRow(ctx, query,
+		event.TeamID,
+		event.Type,
+		string(event.Payload),
+		event.CreatedAt,
+	).Scan(&event.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to append event: %w", err)
+	}
+
+	return nil
+}
+
+// GetEvents lists events of a team after the given ID
+func (r *SQLRepository) GetEvents(ctx context.Context, teamID string, afterID int64, limit int) ([]Event, error) {
+	query := `SELECT id, team_id, type, payload, created_at
+			  FROM team_events WHERE team_id = ? AND id > ?
+			  ORDER BY id LIMIT ?`
+
+	rows, err := r.query(ctx, query, teamID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var event Event
+		var payload string
+		if err := rows.Scan(&event.ID, &event.TeamID, &event.Type, &payload, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		event.Payload = []byte(payload)
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate events: %w", err)
+	}
+
+	return events, nil
+}
+
+// GetLastEventID returns the ID of the latest event of a team
+func (r *SQLRepository) GetLastEventID(ctx context.Context, teamID string) (int64, error) {
+	query := `SELECT COALESCE(MAX(id), 0) FROM team_events WHERE team_id = ?`
+
+	var id int64
+	if err := r.queryRow(ctx, query, teamID).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to get last event id: %w", err)
+	}
+
+	return id, nil
+}
+
+// ============================================
+// WEBHOOK OPERATIONS
+// ============================================
+
+// CreateWebhook saves a new webhook
+func (r *SQLRepository) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	query := `INSERT INTO team_webhooks (id, team_id, url, secret, events, created_at)
+			  VALUES (?, ?, ?, ?, ?, ?)
+			  ON CONFLICT (id) DO NOTHING`
+
+	eventsJSON, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal events: %w", err)
+	}
+
+	result, err := r.exec(ctx, query,
+		webhook.ID,
+		webhook.TeamID,
+		webhook.URL,
+		webhook.Secret,
+		string(eventsJSON),
+		webhook.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("failed to create webhook: %w", ErrAlreadyExists)
+	}
+
+	return nil
+}
+
+// GetWebhook retrieves a webhook by ID
+func (r *SQLRepository) GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	query := `SELECT id, team_id, url, secret, events, created_at
+			  FROM team_webhooks WHERE id = ?`
+
+	webhook, err := scanWebhook(r.queryRow(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// GetTeamWebhooks lists the webhooks of a team
+func (r *SQLRepository) GetTeamWebhooks(ctx context.Context, teamID string) ([]Webhook, error) {
+	query := `SELECT id, team_id, url, secret, events, created_at
+			  FROM team_webhooks WHERE team_id = ?
+			  ORDER BY created_at, id`
+
+	rows, err := r.query(ctx, query, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook, its deliveries go by ON DELETE CASCADE
+func (r *SQLRepository) DeleteWebhook(ctx context.Context, id string) error {
+	result, err := r.exec(ctx, `DELETE FROM team_webhooks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("failed to delete webhook: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// CreateDelivery queues a delivery
+func (r *SQLRepository) CreateDelivery(ctx context.Context, delivery *Delivery) error {
+	query := `INSERT INTO webhook_deliveries
+			  (webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			  RETURNING id`
+
+	err := r.queryRow(ctx, query,
+		delivery.WebhookID,
+		delivery.EventID,
+		delivery.EventType,
+		string(delivery.Payload),
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt.UTC(),
+		delivery.CreatedAt,
+	).Scan(&delivery.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to create delivery: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimDeliveries picks due deliveries. Every row is claimed by a
+// conditional update, so two workers never claim the same one.
+func (r *SQLRepository) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Delivery, error) {
+	query := `SELECT ` + deliveryColumns + `
+			  FROM webhook_deliveries WHERE status = ? AND next_attempt_at <= ?
+			  ORDER BY next_attempt_at, id LIMIT ?`
+
+	due, err := r.queryDeliveries(ctx, query, DeliveryPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := due[:0]
+	for _, delivery := range due {
+		result, err := r.exec(ctx,
+			`UPDATE webhook_deliveries SET next_attempt_at = ?
+			 WHERE id = ? AND status = ? AND next_attempt_at <= ?`,
+			leaseUntil.UTC(), delivery.ID, DeliveryPending, now.UTC(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim delivery: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rowsAffected == 1 {
+			delivery.NextAttemptAt = leaseUntil
+			claimed = append(claimed, delivery)
+		}
+	}
+
+	return claimed, nil
+}
+
+// UpdateDelivery saves the outcome of an attempt
+func (r *SQLRepository) UpdateDelivery(ctx context.Context, delivery *Delivery) error {
+	query := `UPDATE webhook_deliveries
+			  SET status = ?, attempts = ?, next_attempt_at = ?, last_attempt_at = ?,
+			      response_status = ?, last_error = ?
+			  WHERE id = ?`
+
+	var lastAttemptAt sql.NullTime
+	if delivery.LastAttemptAt != nil {
+		lastAttemptAt = sql.NullTime{Time: delivery.LastAttemptAt.UTC(), Valid: true}
+	}
+
+	var responseStatus sql.NullInt64
+	if delivery.ResponseStatus != 0 {
+		responseStatus = sql.NullInt64{Int64: int64(delivery.ResponseStatus), Valid: true}
+	}
+
+	var lastError sql.NullString
+	if delivery.LastError != "" {
+		lastError = sql.NullString{String: delivery.LastError, Valid: true}
+	}
+
+	result, err := r.exec(ctx, query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt.UTC(),
+		lastAttemptAt,
+		responseStatus,
+		lastError,
+		delivery.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update delivery: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("failed to update delivery: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetDeliveries lists the deliveries of a webhook, newest first
+func (r *SQLRepository) GetDeliveries(ctx context.Context, webhookID string, limit int) ([]Delivery, error) {
+	query := `SELECT ` + deliveryColumns + `
+			  FROM webhook_deliveries WHERE webhook_id = ?
+			  ORDER BY id DESC LIMIT ?`
+
+	return r.queryDeliveries(ctx, query, webhookID, limit)
+}
+
+// deliveryColumns are the columns scanDelivery expects, in order
+const deliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts,
+			  next_attempt_at, last_attempt_at, response_status, last_error, created_at`
+
+// queryDeliveries runs a query selecting deliveryColumns
+func (r *SQLRepository) queryDeliveries(ctx context.Context, query string, args ...interface{}) ([]Delivery, error) {
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// ============================================
+// AUDIT OPERATIONS
+// ============================================
+
+// AppendAudit saves an entry to the audit log
+func (r *SQLRepository) AppendAudit(ctx context.Context, entry *Audit) error {
+	query := `INSERT INTO audit_log
+			  (team_id, action, actor_type, actor_role, actor_id, target_type, target_id,
+			   before_json, after_json, request_id, ip, user_agent, created_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			  RETURNING id`
+
+	err := r.queryRow(ctx, query,
+		entry.TeamID,
+		entry.Action,
+		entry.ActorType,
+		nullString(entry.ActorRole),
+		nullString(entry.ActorID),
+		entry.TargetType,
+		entry.TargetID,
+		nullString(string(entry.Before)),
+		nullString(string(entry.After)),
+		nullString(entry.RequestID),
+		nullString(entry.IP),
+		nullString(entry.UserAgent),
+		entry.CreatedAt.UTC(),
+	).Scan(&entry.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// GetAudit lists entries of the audit log of a team
+func (r *SQLRepository) GetAudit(ctx context.Context, query AuditQuery) ([]Audit, error) {
+	sqlQuery := `SELECT id, team_id, action, actor_type, actor_role, actor_id, target_type, target_id,
+			  before_json, after_json, request_id, ip, user_agent, created_at
+			  FROM audit_log WHERE team_id = ?`
+	args := []interface{}{query.TeamID}
+
+	if !query.From.IsZero() {
+		sqlQuery += ` AND created_at >= ?`
+		args = append(args, query.From.UTC())
+	}
+	if !query.To.IsZero() {
+		sqlQuery += ` AND created_at < ?`
+		args = append(args, query.To.UTC())
+	}
+	if query.BeforeID > 0 {
+		sqlQuery += ` AND id < ?`
+		args = append(args, query.BeforeID)
+	}
+	sqlQuery += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, query.Limit)
+
+	rows, err := r.query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Audit
+	for rows.Next() {
+		entry, err := scanAudit(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// ============================================
+// TRASH OPERATIONS
+// ============================================
+
+// PurgeDeleted removes teams and members deleted before the given time.
+// Members, secrets and invites of purged teams go by ON DELETE CASCADE,
+// their audit log is kept.
+func (r *SQLRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	for _, query := range []string{
+		`DELETE FROM team_members WHERE deleted_at IS NOT NULL AND deleted_at < ?`,
+		`DELETE FROM teams WHERE deleted_at IS NOT NULL AND deleted_at < ?`,
+	} {
+		result, err := r.exec(ctx, query, before.UTC())
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge deleted rows: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return purged, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		purged += rowsAffected
+	}
+
+	return purged, nil
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner) (*User, error) {
+	user := &User{}
+	var parentNamesJSON string
+	var grandParentsNamesJSON string
+	var deletedAt sql.NullTime
+
+	if err := row.Scan(
+		&user.ID,
+		&user.TeamID,
+		&user.FirstName,
+		&user.Initials,
+		&parentNamesJSON,
+		&grandParentsNamesJSON,
+		&user.Country,
+		&user.Version,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&deletedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(parentNamesJSON), &user.ParentNames); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal parent_names: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(grandParentsNamesJSON), &user.GrandParentsNames); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal grandparent_names: %w", err)
+	}
+
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+
+	return user, nil
+}
+
+func scanInvite(row scanner) (*Invite, error) {
+	invite := &Invite{}
+	var maxUses sql.NullInt64
+	var expiresAt sql.NullTime
+
+	if err := row.Scan(
+		&invite.Code,
+		&invite.TeamID,
+		&invite.Role,
+		&maxUses,
+		&invite.Uses,
+		&expiresAt,
+		&invite.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	invite.MaxUses = int(maxUses.Int64)
+	if expiresAt.Valid {
+		invite.ExpiresAt = &expiresAt.Time
+	}
+
+	return invite, nil
+}
+
+func scanWebhook(row scanner) (*Webhook, error) {
+	webhook := &Webhook{}
+	var eventsJSON string
+
+	if err := row.Scan(
+		&webhook.ID,
+		&webhook.TeamID,
+		&webhook.URL,
+		&webhook.Secret,
+		&eventsJSON,
+		&webhook.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(eventsJSON), &webhook.Events); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal events: %w", err)
+	}
+
+	return webhook, nil
+}
+
+func scanDelivery(row scanner) (*Delivery, error) {
+	delivery := &Delivery{}
+	var payload string
+	var lastAttemptAt sql.NullTime
+	var responseStatus sql.NullInt64
+	var lastError sql.NullString
+
+	if err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&lastAttemptAt,
+		&responseStatus,
+		&lastError,
+		&delivery.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	delivery.Payload = []byte(payload)
+	delivery.ResponseStatus = int(responseStatus.Int64)
+	delivery.LastError = lastError.String
+	if lastAttemptAt.Valid {
+		delivery.LastAttemptAt = &lastAttemptAt.Time
+	}
+
+	return delivery, nil
+}
+
+func scanAudit(row scanner) (*Audit, error) {
+	entry := &Audit{}
+	var actorRole, actorID, before, after, requestID, ip, userAgent sql.NullString
+
+	if err := row.Scan(
+		&entry.ID,
+		&entry.TeamID,
+		&entry.Action,
+		&entry.ActorType,
+		&actorRole,
+		&actorID,
+		&entry.TargetType,
+		&entry.TargetID,
+		&before,
+		&after,
+		&requestID,
+		&ip,
+		&userAgent,
+		&entry.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	entry.ActorRole = actorRole.String
+	entry.ActorID = actorID.String
+	if before.Valid {
+		entry.Before = []byte(before.String)
+	}
+	if after.Valid {
+		entry.After = []byte(after.String)
+	}
+	entry.RequestID = requestID.String
+	entry.IP = ip.String
+	entry.UserAgent = userAgent.String
+
+	return entry, nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
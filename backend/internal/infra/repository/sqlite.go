@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDialect uses SQLite's native `?` placeholders
+type sqliteDialect struct{}
+
+func (sqliteDialect) rebind(query string) string {
+	return query
+}
+
+// NewSQLite creates a repository backed by SQLite
+func NewSQLite(db *sql.DB, opts ...SQLOption) *SQLRepository {
+	return newSQL(db, sqliteDialect{}, opts)
+}
+
+// isRetryable reports SQLITE_BUSY and SQLITE_LOCKED, which SQLite returns
+// when another connection holds a conflicting lock
+func (sqliteDialect) isRetryable(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+// isUniqueViolation reports primary key and unique constraint failures
+func (sqliteDialect) isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	return false
+}
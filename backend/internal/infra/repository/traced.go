@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the spans of the repository in traces
+const tracerName = "github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+
+// traced creates a span for every call of the repository it wraps
+type traced struct {
+	next Repository
+}
+
+// NewTraced wraps a repository so that every call is traced as a span
+// named like Repository.GetTeamUsers, a child of the span in its context.
+// Spans go to the global tracer provider, see tracing.Setup.
+func NewTraced(repo Repository) Repository {
+	return &traced{next: repo}
+}
+
+// start starts a span for a repository call
+func start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+}
+
+// teamAttr identifies the team a call is about
+func teamAttr(teamID string) attribute.KeyValue {
+	return attribute.String("team.id", teamID)
+}
+
+// recordError marks the span as failed when err is not nil and returns err
+func recordError(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+func (t *traced) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, span := start(ctx, "Repository.WithinTx")
+	defer span.End()
+	return recordError(span, t.next.WithinTx(ctx, fn))
+}
+
+func (t *traced) CreateTeam(ctx context.Context, team *Team) error {
+	ctx, span := start(ctx, "Repository.CreateTeam")
+	defer span.End()
+	return recordError(span, t.next.CreateTeam(ctx, team))
+}
+
+func (t *traced) GetTeam(ctx context.Context, id string) (*Team, error) {
+	ctx, span := start(ctx, "Repository.GetTeam", teamAttr(id))
+	defer span.End()
+	result, err := t.next.GetTeam(ctx, id)
+	return result, recordError(span, err)
+}
+
+func (t *traced) UpdateTeam(ctx context.Context, team *Team) error {
+	ctx, span := start(ctx, "Repository.UpdateTeam")
+	defer span.End()
+	return recordError(span, t.next.UpdateTeam(ctx, team))
+}
+
+func (t *traced) BumpTeamVersion(ctx context.Context, id string, expected int64, at time.Time) (int64, error) {
+	ctx, span := start(ctx, "Repository.BumpTeamVersion", teamAttr(id))
+	defer span.End()
+	result, err := t.next.BumpTeamVersion(ctx, id, expected, at)
+	return result, recordError(span, err)
+}
+
+func (t *traced) DeleteTeam(ctx context.Context, id string, at time.Time) error {
+	ctx, span := start(ctx, "Repository.DeleteTeam", teamAttr(id))
+	defer span.End()
+	return recordError(span, t.next.DeleteTeam(ctx, id, at))
+}
+
+func (t *traced) RestoreTeam(ctx context.Context, id string) error {
+	ctx, span := start(ctx, "Repository.RestoreTeam", teamAttr(id))
+	defer span.End()
+	return recordError(span, t.next.RestoreTeam(ctx, id))
+}
+
+func (t *traced) CreateUser(ctx context.Context, user *User) error {
+	ctx, span := start(ctx, "Repository.CreateUser")
+	defer span.End()
+	return recordError(span, t.next.CreateUser(ctx, user))
+}
+
+func (t *traced) GetUser(ctx context.Context, teamID, userID string) (*User, error) {
+	ctx, span := start(ctx, "Repository.GetUser", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.GetUser(ctx, teamID, userID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) UpdateUser(ctx context.Context, user *User) error {
+	ctx, span := start(ctx, "Repository.UpdateUser")
+	defer span.End()
+	return recordError(span, t.next.UpdateUser(ctx, user))
+}
+
+func (t *traced) DeleteUser(ctx context.Context, teamID, userID string, at time.Time) error {
+	ctx, span := start(ctx, "Repository.DeleteUser", teamAttr(teamID))
+	defer span.End()
+	return recordError(span, t.next.DeleteUser(ctx, teamID, userID, at))
+}
+
+func (t *traced) RestoreUser(ctx context.Context, teamID, userID string) error {
+	ctx, span := start(ctx, "Repository.RestoreUser", teamAttr(teamID))
+	defer span.End()
+	return recordError(span, t.next.RestoreUser(ctx, teamID, userID))
+}
+
+func (t *traced) GetTeamUsers(ctx context.Context, teamID string) ([]User, error) {
+	ctx, span := start(ctx, "Repository.GetTeamUsers", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.GetTeamUsers(ctx, teamID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) GetDeletedUsers(ctx context.Context, teamID string) ([]User, error) {
+	ctx, span := start(ctx, "Repository.GetDeletedUsers", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.GetDeletedUsers(ctx, teamID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := start(ctx, "Repository.PurgeDeleted")
+	defer span.End()
+	result, err := t.next.PurgeDeleted(ctx, before)
+	return result, recordError(span, err)
+}
+
+func (t *traced) CreateToken(ctx context.Context, token *Token) error {
+	ctx, span := start(ctx, "Repository.CreateToken")
+	defer span.End()
+	return recordError(span, t.next.CreateToken(ctx, token))
+}
+
+func (t *traced) GetToken(ctx context.Context, hash string) (*Token, error) {
+	ctx, span := start(ctx, "Repository.GetToken")
+	defer span.End()
+	result, err := t.next.GetToken(ctx, hash)
+	return result, recordError(span, err)
+}
+
+func (t *traced) HasTeamTokens(ctx context.Context, teamID string) (bool, error) {
+	ctx, span := start(ctx, "Repository.HasTeamTokens", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.HasTeamTokens(ctx, teamID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) DeleteTeamTokens(ctx context.Context, teamID, role string) error {
+	ctx, span := start(ctx, "Repository.DeleteTeamTokens", teamAttr(teamID))
+	defer span.End()
+	return recordError(span, t.next.DeleteTeamTokens(ctx, teamID, role))
+}
+
+func (t *traced) CreateInvite(ctx context.Context, invite *Invite) error {
+	ctx, span := start(ctx, "Repository.CreateInvite")
+	defer span.End()
+	return recordError(span, t.next.CreateInvite(ctx, invite))
+}
+
+func (t *traced) GetInvite(ctx context.Context, code string) (*Invite, error) {
+	ctx, span := start(ctx, "Repository.GetInvite")
+	defer span.End()
+	result, err := t.next.GetInvite(ctx, code)
+	return result, recordError(span, err)
+}
+
+func (t *traced) GetTeamInvites(ctx context.Context, teamID string) ([]Invite, error) {
+	ctx, span := start(ctx, "Repository.GetTeamInvites", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.GetTeamInvites(ctx, teamID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) DeleteInvite(ctx context.Context, code string) error {
+	ctx, span := start(ctx, "Repository.DeleteInvite")
+	defer span.End()
+	return recordError(span, t.next.DeleteInvite(ctx, code))
+}
+
+func (t *traced) UseInvite(ctx context.Context, code string) (bool, error) {
+	ctx, span := start(ctx, "Repository.UseInvite")
+	defer span.End()
+	result, err := t.next.UseInvite(ctx, code)
+	return result, recordError(span, err)
+}
+
+func (t *traced) AppendEvent(ctx context.Context, event *Event) error {
+	ctx, span := start(ctx, "Repository.AppendEvent")
+	defer span.End()
+	return recordError(span, t.next.AppendEvent(ctx, event))
+}
+
+func (t *traced) GetEvents(ctx context.Context, teamID string, afterID int64, limit int) ([]Event, error) {
+	ctx, span := start(ctx, "Repository.GetEvents", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.GetEvents(ctx, teamID, afterID, limit)
+	return result, recordError(span, err)
+}
+
+func (t *traced) GetLastEventID(ctx context.Context, teamID string) (int64, error) {
+	ctx, span := start(ctx, "Repository.GetLastEventID", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.GetLastEventID(ctx, teamID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	ctx, span := start(ctx, "Repository.CreateWebhook")
+	defer span.End()
+	return recordError(span, t.next.CreateWebhook(ctx, webhook))
+}
+
+func (t *traced) GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	ctx, span := start(ctx, "Repository.GetWebhook")
+	defer span.End()
+	result, err := t.next.GetWebhook(ctx, id)
+	return result, recordError(span, err)
+}
+
+func (t *traced) GetTeamWebhooks(ctx context.Context, teamID string) ([]Webhook, error) {
+	ctx, span := start(ctx, "Repository.GetTeamWebhooks", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.GetTeamWebhooks(ctx, teamID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) DeleteWebhook(ctx context.Context, id string) error {
+	ctx, span := start(ctx, "Repository.DeleteWebhook")
+	defer span.End()
+	return recordError(span, t.next.DeleteWebhook(ctx, id))
+}
+
+func (t *traced) CreateDelivery(ctx context.Context, delivery *Delivery) error {
+	ctx, span := start(ctx, "Repository.CreateDelivery")
+	defer span.End()
+	return recordError(span, t.next.CreateDelivery(ctx, delivery))
+}
+
+func (t *traced) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Delivery, error) {
+	ctx, span := start(ctx, "Repository.ClaimDeliveries")
+	defer span.End()
+	result, err := t.next.ClaimDeliveries(ctx, now, leaseUntil, limit)
+	return result, recordError(span, err)
+}
+
+func (t *traced) UpdateDelivery(ctx context.Context, delivery *Delivery) error {
+	ctx, span := start(ctx, "Repository.UpdateDelivery")
+	defer span.End()
+	return recordError(span, t.next.UpdateDelivery(ctx, delivery))
+}
+
+func (t *traced) GetDeliveries(ctx context.Context, webhookID string, limit int) ([]Delivery, error) {
+	ctx, span := start(ctx, "Repository.GetDeliveries")
+	defer span.End()
+	result, err := t.next.GetDeliveries(ctx, webhookID, limit)
+	return result, recordError(span, err)
+}
+
+func (t *traced) AppendAudit(ctx context.Context, entry *Audit) error {
+	ctx, span := start(ctx, "Repository.AppendAudit")
+	defer span.End()
+	return recordError(span, t.next.AppendAudit(ctx, entry))
+}
+
+func (t *traced) GetAudit(ctx context.Context, query AuditQuery) ([]Audit, error) {
+	ctx, span := start(ctx, "Repository.GetAudit")
+	defer span.End()
+	result, err := t.next.GetAudit(ctx, query)
+	return result, recordError(span, err)
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+const (
+	// maxTxAttempts limits how often a transaction is retried on conflicts
+	maxTxAttempts = 5
+	// txRetryDelay is the base delay between retries, doubled every attempt
+	txRetryDelay = 10 * time.Millisecond
+)
+
+// txKey is the context key holding the current transaction
+type txKey struct{}
+
+// sqlTx ties a transaction to the database it was started on
+type sqlTx struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+// executor is implemented by both *sql.DB and *sql.Tx
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn returns the transaction bound to ctx, or the database itself
+func (r *SQLRepository) conn(ctx context.Context) executor {
+	if tx, ok := ctx.Value(txKey{}).(*sqlTx); ok && tx.db == r.db {
+		return tx.tx
+	}
+	return r.db
+}
+
+// WithinTx runs fn inside a database transaction, retrying on transient conflicts
+func (r *SQLRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sqlTx); ok && tx.db == r.db {
+		return fn(ctx)
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = r.runTx(ctx, fn)
+		if err == nil || !r.dialect.isRetryable(err) {
+			return err
+		}
+
+		if attempt < maxTxAttempts {
+			delay := txRetryDelay << (attempt - 1)
+			logging.FromContext(ctx).Debug("retrying transaction",
+				"attempt", attempt, "delay", delay, "error", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+	}
+
+	return fmt.Errorf("transaction failed after %d attempts: %w", maxTxAttempts, err)
+}
+
+// runTx makes a single attempt of a transaction
+func (r *SQLRepository) runTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	txCtx := context.WithValue(ctx, txKey{}, &sqlTx{db: r.db, tx: tx})
+	if err := fn(txCtx); err != nil {
+		// A canceled context has rolled back already
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			logging.FromContext(ctx).Warn("failed to roll back transaction", "error", rollbackErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+// Package tracing configures where the OpenTelemetry spans of the
+// handlers, usecases and repository are exported to
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Exporters spans can be sent to
+const (
+	// ExporterNone drops all spans
+	ExporterNone = "none"
+	// ExporterOTLP sends spans to an OTLP/HTTP collector
+	ExporterOTLP = "otlp"
+	// ExporterStdout writes spans as JSON, meant for development and tests
+	ExporterStdout = "stdout"
+)
+
+// DefaultServiceName identifies the server in traces
+const DefaultServiceName = "cup-of-team"
+
+// Config holds tracing configuration
+type Config struct {
+	// Exporter is none, otlp or stdout, none when empty
+	Exporter string
+	// Endpoint is the host:port of the OTLP collector, like
+	// localhost:4318. When empty the OTEL_EXPORTER_OTLP_* variables
+	// apply, falling back to localhost:4318.
+	Endpoint string
+	// Insecure sends spans to the collector over plain HTTP
+	Insecure bool
+	// ServiceName defaults to DefaultServiceName
+	ServiceName string
+	// Writer receives the spans of the stdout exporter, os.Stdout when nil
+	Writer io.Writer
+}
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. The returned function flushes the spans that have not been
+// exported yet and stops the provider. With the none exporter nothing is
+// installed and spans are dropped.
+func Setup(ctx context.Context, config Config) (shutdown func(context.Context) error, err error) {
+	var exporter sdktrace.SpanExporter
+	switch strings.ToLower(config.Exporter) {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+
+	case ExporterStdout:
+		var opts []stdouttrace.Option
+		if config.Writer != nil {
+			opts = append(opts, stdouttrace.WithWriter(config.Writer))
+		}
+		exporter, err = stdouttrace.New(opts...)
+
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, expected none, otlp or stdout", config.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", config.Exporter, err)
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,124 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	// HeaderSignature holds Sign of the timestamp and the body
+	HeaderSignature = "X-Webhook-Signature"
+	// HeaderTimestamp holds the Unix time the attempt was sent at
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderEvent holds the event type, e.g. member_added
+	HeaderEvent = "X-Webhook-Event"
+	// HeaderDelivery holds the delivery ID, the same for every retry
+	HeaderDelivery = "X-Webhook-Delivery"
+)
+
+// maxResponseBody bounds how much of a response is read before closing it
+const maxResponseBody = 64 << 10
+
+// Request is one delivery attempt
+type Request struct {
+	URL        string
+	Secret     string
+	DeliveryID int64
+	EventType  string
+	Payload    []byte
+}
+
+// Sign returns the signature of a payload sent at timestamp:
+// "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<payload>" keyed with
+// the webhook secret. Receivers recompute it and reject old timestamps to
+// prevent replays.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sender posts deliveries over HTTP
+type Sender struct {
+	client    *http.Client
+	allowlist Allowlist
+}
+
+// NewSender creates a sender whose attempts time out after timeout.
+// It only connects to public addresses and those in allowlist.
+func NewSender(timeout time.Duration, allowlist Allowlist) *Sender {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: allowlist.control,
+	}
+
+	return &Sender{
+		allowlist: allowlist,
+		client: &http.Client{
+			Timeout: timeout,
+			// No proxy: the addresses connected to are the ones checked
+			Transport: &http.Transport{
+				DialContext:           dialer.DialContext,
+				ForceAttemptHTTP2:     true,
+				MaxIdleConns:          100,
+				IdleConnTimeout:       90 * time.Second,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ExpectContinueTimeout: time.Second,
+			},
+			// A redirect would turn the POST into a GET, report it instead
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// CheckURL rejects targets the sender would refuse to connect to, as far
+// as it can tell without resolving names, with ErrForbiddenTarget
+func (s *Sender) CheckURL(rawURL string) error {
+	return s.allowlist.CheckURL(rawURL)
+}
+
+// Send posts the payload and returns the response status, 0 when no
+// response was received. Statuses other than 2xx are errors.
+func (s *Sender) Send(ctx context.Context, req Request) (int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "cup-of-team-webhooks")
+	httpReq.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	httpReq.Header.Set(HeaderSignature, Sign(req.Secret, timestamp, req.Payload))
+	httpReq.Header.Set(HeaderEvent, req.EventType)
+	httpReq.Header.Set(HeaderDelivery, strconv.FormatInt(req.DeliveryID, 10))
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Drain a bit of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
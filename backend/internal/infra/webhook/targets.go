@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrForbiddenTarget is returned for webhook targets in internal
+// networks, which team admins must not make the server call
+var ErrForbiddenTarget = errors.New("webhook target is an internal address")
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, and
+// thisNetwork the addresses of RFC 1122 that reach the local host
+var (
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+	thisNetwork        = netip.MustParsePrefix("0.0.0.0/8")
+)
+
+// Allowlist lists internal networks webhooks may target anyway, for tests
+// and local development. Public addresses are always allowed.
+type Allowlist []netip.Prefix
+
+// ParseAllowlist parses networks like 127.0.0.0/8 or single addresses
+// like ::1
+func ParseAllowlist(entries []string) (Allowlist, error) {
+	allowlist := make(Allowlist, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q: %w", entry, err)
+			}
+			allowlist = append(allowlist, netip.PrefixFrom(ip, ip.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", entry, err)
+		}
+		allowlist = append(allowlist, prefix.Masked())
+	}
+	return allowlist, nil
+}
+
+// Allows reports whether webhooks may be sent to ip: public addresses and
+// the allowed networks, but not loopback, private, link-local, multicast
+// or unspecified ones
+func (a Allowlist) Allows(ip netip.Addr) bool {
+	ip = ip.WithZone("").Unmap()
+	for _, prefix := range a {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() &&
+		!sharedAddressSpace.Contains(ip) && !thisNetwork.Contains(ip)
+}
+
+// CheckURL rejects URLs whose host is a forbidden address or a name of
+// the local host. Other names are checked once resolved, when sending.
+func (a Allowlist) CheckURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		host = "127.0.0.1"
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return nil
+	}
+	if !a.Allows(ip) {
+		return ErrForbiddenTarget
+	}
+	return nil
+}
+
+// control checks the resolved address of every connection, so that names
+// resolving to internal addresses, maybe only after being registered,
+// are refused too
+func (a Allowlist) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !a.Allows(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenTarget, ip)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+)
+
+// actorKey is the context key holding the domain.Actor of a request
+type actorKey struct{}
+
+// requestInfoKey is the context key holding the domain.RequestInfo of a request
+type requestInfoKey struct{}
+
+// WithActor returns a context telling the usecases who acts,
+// as recorded in the audit log
+func WithActor(ctx context.Context, actor domain.Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor of ctx, anonymous when none is set
+func ActorFrom(ctx context.Context) domain.Actor {
+	if actor, ok := ctx.Value(actorKey{}).(domain.Actor); ok {
+		return actor
+	}
+	return domain.Actor{Type: domain.ActorAnonymous}
+}
+
+// WithRequestInfo returns a context carrying the request metadata
+// recorded in the audit log
+func WithRequestInfo(ctx context.Context, info domain.RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFrom returns the request metadata of ctx, empty when none is set
+func RequestInfoFrom(ctx context.Context) domain.RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(domain.RequestInfo)
+	return info
+}
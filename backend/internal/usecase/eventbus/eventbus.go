@@ -0,0 +1,102 @@
+package eventbus
+
+import (
+	"sync"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+)
+
+// DefaultBuffer is how many events a subscriber may fall behind
+const DefaultBuffer = 64
+
+// Bus delivers published events to the subscribers of their team.
+// Publishing never blocks: a subscriber whose buffer is full is dropped
+// and its channel closed, it has to catch up from the event log.
+type Bus struct {
+	mu     sync.Mutex
+	buffer int
+	subs   map[string]map[*Subscription]struct{}
+}
+
+// Subscription receives the events of one team
+type Subscription struct {
+	bus    *Bus
+	teamID string
+	events chan domain.Event
+	closed bool
+}
+
+// New creates a bus with subscriber buffers of the given size,
+// DefaultBuffer when not positive
+func New(buffer int) *Bus {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+
+	return &Bus{
+		buffer: buffer,
+		subs:   make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe starts receiving the events of a team published from now on
+func (b *Bus) Subscribe(teamID string) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &Subscription{
+		bus:    b,
+		teamID: teamID,
+		events: make(chan domain.Event, b.buffer),
+	}
+
+	if b.subs[teamID] == nil {
+		b.subs[teamID] = make(map[*Subscription]struct{})
+	}
+	b.subs[teamID][sub] = struct{}{}
+
+	return sub
+}
+
+// Publish sends an event to all subscribers of its team
+func (b *Bus) Publish(event domain.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs[event.TeamID] {
+		select {
+		case sub.events <- event:
+		default:
+			// Too slow, drop it rather than block the writer
+			b.remove(sub)
+		}
+	}
+}
+
+// remove unsubscribes and closes sub, the caller holds the lock
+func (b *Bus) remove(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.events)
+
+	delete(b.subs[sub.teamID], sub)
+	if len(b.subs[sub.teamID]) == 0 {
+		delete(b.subs, sub.teamID)
+	}
+}
+
+// Events returns the channel of events. It is closed when the
+// subscription is closed or dropped for falling behind.
+func (s *Subscription) Events() <-chan domain.Event {
+	return s.events
+}
+
+// Close unsubscribes, it is safe to call more than once
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.remove(s)
+}
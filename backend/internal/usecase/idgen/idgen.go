@@ -0,0 +1,72 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// randomBytes is the entropy of a random ID, 128 bits
+const randomBytes = 16
+
+// encoding is lowercase base32 without padding, 26 characters for 16 bytes
+var encoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// Generator produces identifiers for new entities
+type Generator interface {
+	NewID() (string, error)
+}
+
+// Func adapts a function to the Generator interface
+type Func func() (string, error)
+
+// NewID calls f
+func (f Func) NewID() (string, error) {
+	return f()
+}
+
+// Random generates unguessable IDs: the prefix followed by
+// 128 random bits from crypto/rand
+type Random struct {
+	prefix string
+}
+
+// NewRandom creates a random generator, e.g. NewRandom("team_")
+func NewRandom(prefix string) *Random {
+	return &Random{prefix: prefix}
+}
+
+// NewID returns a new random ID
+func (g *Random) NewID() (string, error) {
+	b := make([]byte, randomBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+
+	return g.prefix + encoding.EncodeToString(b), nil
+}
+
+// Sequence generates predictable IDs (prefix1, prefix2, ...) for tests
+type Sequence struct {
+	mu     sync.Mutex
+	prefix string
+	next   int
+}
+
+// NewSequence creates a sequence generator starting at 1
+func NewSequence(prefix string) *Sequence {
+	return &Sequence{prefix: prefix, next: 1}
+}
+
+// NewID returns the next ID of the sequence
+func (g *Sequence) NewID() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := g.prefix + strconv.Itoa(g.next)
+	g.next++
+
+	return id, nil
+}
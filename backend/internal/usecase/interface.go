@@ -1,13 +1,103 @@
 package usecase
 
-import "github.com/kvloginov/cup-of-team/backend/internal/domain"
+import (
+	"context"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+)
+
+// ErrTeamNotFound is returned for operations on an unknown team
+var ErrTeamNotFound = domain.NotFound("team_not_found", "team not found")
+
+// ErrUserNotFound is returned when the user is not a member of the team
+var ErrUserNotFound = domain.NotFound("user_not_found", "user not found")
+
+// ErrVersionMismatch is returned when a write expects a version of a team
+// or user that is no longer current
+var ErrVersionMismatch = domain.PreconditionFailed("version_mismatch", "changed since the given version, reload and retry")
+
+// ErrNotInTrash is returned when restoring something that is not deleted
+var ErrNotInTrash = domain.NotFound("not_in_trash", "not found in the trash")
+
+// ErrUserConflict is returned when a member was created concurrently
+var ErrUserConflict = domain.Conflict("user_conflict", "user already exists in team")
+
+// ErrUserInTrash is returned when adding a member with the ID of a deleted
+// one, which must be restored or purged first
+var ErrUserInTrash = domain.Conflict("user_in_trash", "user is in the trash, restore it or wait for it to be purged")
+
+// ErrUnauthorized is returned for an unknown team secret
+var ErrUnauthorized = domain.Unauthorized("invalid_token", "invalid team secret")
+
+// ErrInvalidRole is returned for roles other than admin and viewer
+var ErrInvalidRole = domain.Validation("invalid_role", "role must be admin or viewer")
+
+// ErrInviteNotFound is returned for unknown or revoked invite codes
+var ErrInviteNotFound = domain.NotFound("invite_not_found", "invite not found")
+
+// ErrInviteExpired is returned for invites past their expiry or use limit
+var ErrInviteExpired = domain.Expired("invite_expired", "invite has expired or is used up")
+
+// ErrWebhookNotFound is returned for webhooks unknown to the team
+var ErrWebhookNotFound = domain.NotFound("webhook_not_found", "webhook not found")
 
 // TeamUsecase defines the interface for team-related business logic
 type TeamUsecase interface {
-	CreateTeam(params CreateTeamParams) (*CreateTeamResult, error)
-	GetTeam(teamID string) (*domain.Team, error)
-	AddUser(params AddUserParams) (*domain.User, error)
-	RemoveUser(teamID, userID string) error
+	CreateTeam(ctx context.Context, params CreateTeamParams) (*CreateTeamResult, error)
+	GetTeam(ctx context.Context, teamID string) (*domain.Team, error)
+	UpdateTeam(ctx context.Context, params UpdateTeamParams) (*domain.Team, error)
+	// DeleteTeam moves the team to the trash. Its secrets keep working,
+	// so that an admin can restore it before it is purged.
+	// Like all writes taking an expected team version, 0 skips the check.
+	DeleteTeam(ctx context.Context, teamID string, expectedVersion int64) error
+	RestoreTeam(ctx context.Context, teamID string) (*domain.Team, error)
+	// GetUser returns a member of a team with the version of the team,
+	// ErrUserNotFound for users that are not members or are in the trash
+	GetUser(ctx context.Context, teamID, userID string) (*GetUserResult, error)
+	AddUser(ctx context.Context, params AddUserParams) (*AddUserResult, error)
+	// RemoveUser moves the member to the trash of the team and returns
+	// the new version of the team
+	RemoveUser(ctx context.Context, teamID, userID string, expectedVersion int64) (int64, error)
+	RestoreUser(ctx context.Context, teamID, userID string) (*domain.User, error)
+	// ListTrash returns the deleted members of a team, last deleted first
+	ListTrash(ctx context.Context, teamID string) ([]domain.DeletedUser, error)
+	// WatchTeam streams the events of a team until ctx is done. Events
+	// after lastEventID are replayed from the log first, 0 starts with
+	// the next change.
+	WatchTeam(ctx context.Context, teamID string, lastEventID int64) (*TeamWatch, error)
+	// PurgeDeleted permanently removes teams and members that have been
+	// in the trash for longer than retention
+	PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error)
+
+	// Authorize resolves a team secret into the access it grants
+	Authorize(ctx context.Context, secret string) (*domain.Access, error)
+	// RotateToken replaces the secret of a role and returns the new one.
+	// The team ID and the secrets of the other role stay unchanged, except
+	// for legacy teams, which get the secrets of both roles at once.
+	RotateToken(ctx context.Context, teamID string, role domain.Role) (*RotateTokenResult, error)
+
+	CreateInvite(ctx context.Context, params CreateInviteParams) (*domain.Invite, error)
+	ListInvites(ctx context.Context, teamID string) ([]domain.Invite, error)
+	RevokeInvite(ctx context.Context, teamID, code string) error
+	// JoinByInvite redeems an invite code: the user is added to the team
+	// and receives a new secret with the invite's role
+	JoinByInvite(ctx context.Context, params JoinByInviteParams) (*JoinByInviteResult, error)
+
+	// CreateWebhook registers a receiver of the team's events. The result
+	// carries the signing secret, which is not shown again.
+	CreateWebhook(ctx context.Context, params CreateWebhookParams) (*domain.Webhook, error)
+	ListWebhooks(ctx context.Context, teamID string) ([]domain.Webhook, error)
+	// DeleteWebhook stops deliveries and removes the delivery log
+	DeleteWebhook(ctx context.Context, teamID, webhookID string) error
+	// ListWebhookDeliveries returns up to limit deliveries, newest first
+	ListWebhookDeliveries(ctx context.Context, teamID, webhookID string, limit int) ([]domain.WebhookDelivery, error)
+	// DeliverWebhooks sends the deliveries that are due and returns how
+	// many succeeded. Failed ones are retried with exponential backoff.
+	DeliverWebhooks(ctx context.Context) (int, error)
+
+	// ListAudit returns a page of the audit log of a team, newest first
+	ListAudit(ctx context.Context, params ListAuditParams) (*AuditPage, error)
 }
 
 // CreateTeamParams contains parameters for creating a team
@@ -17,12 +107,109 @@ type CreateTeamParams struct {
 
 // CreateTeamResult contains the result of creating a team
 type CreateTeamResult struct {
-	ID string
+	ID          string
+	AdminToken  string
+	ViewerToken string
+}
+
+// UpdateTeamParams contains parameters for updating a team.
+// Nil fields are left unchanged.
+type UpdateTeamParams struct {
+	TeamID string
+	Name   *string
+	// ExpectedVersion must match the team's version unless 0
+	ExpectedVersion int64
+}
+
+// RotateTokenResult contains the secrets issued by a rotation
+type RotateTokenResult struct {
+	// Token is the new secret of the rotated role
+	Token string
+	// OtherTokens holds the secrets issued for the other roles on the
+	// first rotation of a legacy team, empty otherwise
+	OtherTokens map[domain.Role]string
 }
 
-// AddUserParams contains parameters for adding a user to a team
+// GetUserResult contains a member and the version of its team, which
+// writes of the member expect like all team writes
+type GetUserResult struct {
+	User        domain.User
+	TeamVersion int64
+}
+
+// AddUserParams contains parameters for adding a user to a team.
+// The user is normalized and validated, see domain.User.Validate.
 type AddUserParams struct {
 	TeamID string
 	User   domain.User
+	// ExpectedTeamVersion must match the team's version unless 0
+	ExpectedTeamVersion int64
+	// CreateOnly fails with ErrUserConflict when the member exists,
+	// instead of updating it
+	CreateOnly bool
+}
+
+// AddUserResult contains the stored member and the version of its team
+// after the change
+type AddUserResult struct {
+	User        domain.User
+	TeamVersion int64
+}
+
+// CreateInviteParams contains parameters for creating an invite
+type CreateInviteParams struct {
+	TeamID    string
+	Role      domain.Role   // defaults to viewer
+	MaxUses   int           // 0 means unlimited
+	ExpiresIn time.Duration // 0 means never
 }
 
+// JoinByInviteParams contains parameters for joining a team by invite
+type JoinByInviteParams struct {
+	Code string
+	User domain.User
+}
+
+// JoinByInviteResult contains the result of joining a team by invite
+type JoinByInviteResult struct {
+	TeamID string
+	Role   domain.Role
+	Token  string
+	User   domain.User
+}
+
+// CreateWebhookParams contains parameters for registering a webhook
+type CreateWebhookParams struct {
+	TeamID string
+	URL    string
+	Secret string             // generated when empty
+	Events []domain.EventType // empty means all
+}
+
+// ListAuditParams contains parameters for reading the audit log
+type ListAuditParams struct {
+	TeamID string
+	// From and To bound the time of the entries, From inclusive and To
+	// exclusive. Zero values leave the range open.
+	From time.Time
+	To   time.Time
+	// Cursor is the NextCursor of the previous page, 0 for the first one
+	Cursor int64
+	Limit  int
+}
+
+// AuditPage is one page of the audit log
+type AuditPage struct {
+	Entries []domain.AuditEntry
+	// NextCursor continues the listing, 0 when there are no more entries
+	NextCursor int64
+}
+
+// TeamWatch is a running subscription to the events of a team
+type TeamWatch struct {
+	// Cursor is the ID of the last event before the stream starts.
+	// A client that reconnects before receiving any event resumes from it.
+	Cursor int64
+	// Events is closed when the watch ends
+	Events <-chan domain.Event
+}
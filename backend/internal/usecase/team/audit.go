@@ -0,0 +1,148 @@
+package team
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+)
+
+// Audit target types
+const (
+	targetTeam    = "team"
+	targetMember  = "member"
+	targetToken   = "token"
+	targetInvite  = "invite"
+	targetWebhook = "webhook"
+)
+
+// teamSnapshot is what the audit log keeps of a team
+type teamSnapshot struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// audit appends an entry to the audit log of a team, attributed to the
+// actor and request of ctx. It runs in the transaction of the change, so
+// an entry exists if and only if its change is committed. before and
+// after are marshaled as snapshots of the target, nil leaves them out.
+func (u *Usecase) audit(ctx context.Context, teamID string, action domain.AuditAction, targetType, targetID string, before, after interface{}) error {
+	actor := usecase.ActorFrom(ctx)
+	request := usecase.RequestInfoFrom(ctx)
+
+	entry := &repository.Audit{
+		TeamID:     teamID,
+		Action:     string(action),
+		ActorType:  string(actor.Type),
+		ActorRole:  string(actor.Role),
+		ActorID:    actor.ID,
+		TargetType: targetType,
+		TargetID:   targetID,
+		RequestID:  request.RequestID,
+		IP:         request.IP,
+		UserAgent:  request.UserAgent,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	var err error
+	if entry.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if entry.After, err = snapshot(after); err != nil {
+		return err
+	}
+
+	if err := u.repo.AppendAudit(ctx, entry); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	if p, ok := ctx.Value(pendingKey{}).(*pending); ok {
+		p.actions = append(p.actions, action)
+	}
+
+	return nil
+}
+
+// ListAudit returns a page of the audit log of a team
+func (u *Usecase) ListAudit(ctx context.Context, params usecase.ListAuditParams) (*usecase.AuditPage, error) {
+	if !params.From.IsZero() && !params.To.IsZero() && !params.From.Before(params.To) {
+		return nil, domain.Validation("invalid_time_range", "from must be before to")
+	}
+
+	var result *usecase.AuditPage
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		if err := u.verifyTeam(ctx, params.TeamID); err != nil {
+			return err
+		}
+
+		// Read one more entry than asked to know whether a next page exists
+		entries, err := u.repo.GetAudit(ctx, repository.AuditQuery{
+			TeamID:   params.TeamID,
+			From:     params.From,
+			To:       params.To,
+			BeforeID: params.Cursor,
+			Limit:    params.Limit + 1,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get audit log: %w", err)
+		}
+
+		result = &usecase.AuditPage{}
+		if len(entries) > params.Limit {
+			entries = entries[:params.Limit]
+			result.NextCursor = entries[len(entries)-1].ID
+		}
+
+		result.Entries = make([]domain.AuditEntry, len(entries))
+		for i := range entries {
+			result.Entries[i] = toDomainAudit(&entries[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// snapshot marshals the state of an audit target, nil stays nil
+func snapshot(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit snapshot: %w", err)
+	}
+	return data, nil
+}
+
+// toDomainAudit converts a repository audit entry to a domain one
+func toDomainAudit(entry *repository.Audit) domain.AuditEntry {
+	return domain.AuditEntry{
+		ID:     entry.ID,
+		TeamID: entry.TeamID,
+		Action: domain.AuditAction(entry.Action),
+		Actor: domain.Actor{
+			Type: domain.ActorType(entry.ActorType),
+			Role: domain.Role(entry.ActorRole),
+			ID:   entry.ActorID,
+		},
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Before:     entry.Before,
+		After:      entry.After,
+		Request: domain.RequestInfo{
+			RequestID: entry.RequestID,
+			IP:        entry.IP,
+			UserAgent: entry.UserAgent,
+		},
+		CreatedAt: entry.CreatedAt,
+	}
+}
@@ -0,0 +1,214 @@
+package team
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase/eventbus"
+)
+
+// eventPage is how many events are read from the log at once
+const eventPage = 100
+
+// pendingKey is the context key holding what the running transaction
+// has recorded
+type pendingKey struct{}
+
+// pending holds the events and audited actions of a transaction, which
+// take effect only once it commits
+type pending struct {
+	events  []domain.Event
+	actions []domain.AuditAction
+}
+
+// withinTx runs fn in a transaction like repo.WithinTx. Once the
+// outermost transaction has committed it publishes the events recorded by
+// fn and counts its audited actions.
+func (u *Usecase) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(pendingKey{}).(*pending); ok {
+		return u.repo.WithinTx(ctx, fn)
+	}
+
+	var done pending
+	err := u.repo.WithinTx(ctx, func(ctx context.Context) error {
+		// A retried transaction records everything again
+		done = pending{events: done.events[:0], actions: done.actions[:0]}
+		return fn(context.WithValue(ctx, pendingKey{}, &done))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, event := range done.events {
+		u.bus.Publish(event)
+	}
+	for _, action := range done.actions {
+		u.operations.Inc(string(action))
+	}
+
+	return nil
+}
+
+// record appends an event to the log of its team and queues it for the
+// team's webhooks. It must run in withinTx after the team version was
+// bumped, which keeps the events of a team in order.
+func (u *Usecase) record(ctx context.Context, event domain.Event) error {
+	event.CreatedAt = time.Now().UTC()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	stored := &repository.Event{
+		TeamID:    event.TeamID,
+		Type:      string(event.Type),
+		Payload:   payload,
+		CreatedAt: event.CreatedAt,
+	}
+	if err := u.repo.AppendEvent(ctx, stored); err != nil {
+		return fmt.Errorf("failed to record event: %w", err)
+	}
+	event.ID = stored.ID
+
+	// Webhooks get the event as streamed, with its ID
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if err := u.enqueueWebhooks(ctx, event, body); err != nil {
+		return err
+	}
+
+	if p, ok := ctx.Value(pendingKey{}).(*pending); ok {
+		p.events = append(p.events, event)
+	}
+
+	return nil
+}
+
+// WatchTeam streams the events of a team
+func (u *Usecase) WatchTeam(ctx context.Context, teamID string, lastEventID int64) (*usecase.TeamWatch, error) {
+	// Subscribe before reading the log, so no event goes unnoticed
+	sub := u.bus.Subscribe(teamID)
+
+	cursor := lastEventID
+	err := u.repo.WithinTx(ctx, func(ctx context.Context) error {
+		if err := u.verifyTeam(ctx, teamID); err != nil {
+			return err
+		}
+
+		if lastEventID > 0 {
+			return nil
+		}
+
+		var err error
+		cursor, err = u.repo.GetLastEventID(ctx, teamID)
+		if err != nil {
+			return fmt.Errorf("failed to get last event: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	events := make(chan domain.Event)
+	w := &watcher{usecase: u, teamID: teamID, cursor: cursor, out: events}
+	go w.run(ctx, sub)
+
+	return &usecase.TeamWatch{Cursor: cursor, Events: events}, nil
+}
+
+// watcher feeds one TeamWatch
+type watcher struct {
+	usecase *Usecase
+	teamID  string
+	cursor  int64 // ID of the last event sent
+	out     chan<- domain.Event
+}
+
+// run replays the log after the cursor and then again whenever the bus
+// announces an event. The log, not the bus, is the source: events of
+// concurrent transactions may be published out of order, but they are
+// committed in order.
+func (w *watcher) run(ctx context.Context, sub *eventbus.Subscription) {
+	defer close(w.out)
+	defer func() { sub.Close() }()
+
+	for {
+		if !w.replay(ctx) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind, the next replay catches up
+				sub = w.usecase.bus.Subscribe(w.teamID)
+			}
+		}
+	}
+}
+
+// replay sends the logged events after the cursor, false when the watch ends
+func (w *watcher) replay(ctx context.Context) bool {
+	for {
+		stored, err := w.usecase.repo.GetEvents(ctx, w.teamID, w.cursor, eventPage)
+		if err != nil {
+			if ctx.Err() == nil {
+				logging.FromContext(ctx).Error("failed to replay events", "team_id", w.teamID, "error", err)
+			}
+			return false
+		}
+
+		for i := range stored {
+			event, err := toDomainEvent(&stored[i])
+			if err != nil {
+				logging.FromContext(ctx).Error("failed to replay events", "team_id", w.teamID, "error", err)
+				return false
+			}
+			if !w.send(ctx, event) {
+				return false
+			}
+		}
+
+		if len(stored) < eventPage {
+			return true
+		}
+	}
+}
+
+// send delivers an event and advances the cursor
+func (w *watcher) send(ctx context.Context, event domain.Event) bool {
+	select {
+	case w.out <- event:
+		w.cursor = event.ID
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// toDomainEvent converts a stored event to a domain event
+func toDomainEvent(stored *repository.Event) (domain.Event, error) {
+	var event domain.Event
+	if err := json.Unmarshal(stored.Payload, &event); err != nil {
+		return event, fmt.Errorf("failed to unmarshal event %d: %w", stored.ID, err)
+	}
+
+	event.ID = stored.ID
+	event.TeamID = stored.TeamID
+	event.Type = domain.EventType(stored.Type)
+	event.CreatedAt = stored.CreatedAt
+	return event, nil
+}
@@ -0,0 +1,239 @@
+package team
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+)
+
+const (
+	// inviteAlphabet leaves out characters that are easy to confuse (0/O, 1/I/L)
+	inviteAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	// inviteCodeLength is the number of characters in a code, without the dash
+	inviteCodeLength = 8
+	// maxInviteAttempts bounds retries on code collisions
+	maxInviteAttempts = 5
+)
+
+// CreateInvite mints a new invite code for a team
+func (u *Usecase) CreateInvite(ctx context.Context, params usecase.CreateInviteParams) (*domain.Invite, error) {
+	role := params.Role
+	if role == "" {
+		role = domain.RoleViewer
+	}
+	if !role.Valid() {
+		return nil, usecase.ErrInvalidRole
+	}
+	if params.MaxUses < 0 || params.ExpiresIn < 0 {
+		return nil, domain.Validation("invalid_invite_limits", "max uses and expiry must not be negative")
+	}
+
+	now := time.Now().UTC()
+	invite := &repository.Invite{
+		TeamID:    params.TeamID,
+		Role:      string(role),
+		MaxUses:   params.MaxUses,
+		CreatedAt: now,
+	}
+	if params.ExpiresIn > 0 {
+		expiresAt := now.Add(params.ExpiresIn)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		team, err := u.repo.GetTeam(ctx, params.TeamID)
+		if err != nil {
+			return fmt.Errorf("failed to verify team: %w", err)
+		}
+
+		if team == nil {
+			return usecase.ErrTeamNotFound
+		}
+
+		for attempt := 1; ; attempt++ {
+			if invite.Code, err = newInviteCode(); err != nil {
+				return err
+			}
+
+			err = u.repo.CreateInvite(ctx, invite)
+			if errors.Is(err, repository.ErrAlreadyExists) && attempt < maxInviteAttempts {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to create invite: %w", err)
+			}
+			return u.audit(ctx, params.TeamID, domain.AuditInviteCreated, targetInvite, invite.Code,
+				nil, toDomainInvite(invite))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := toDomainInvite(invite)
+	return &result, nil
+}
+
+// ListInvites returns all invite codes of a team, ErrTeamNotFound for
+// unknown or deleted teams
+func (u *Usecase) ListInvites(ctx context.Context, teamID string) ([]domain.Invite, error) {
+	var result []domain.Invite
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		if err := u.verifyTeam(ctx, teamID); err != nil {
+			return err
+		}
+
+		invites, err := u.repo.GetTeamInvites(ctx, teamID)
+		if err != nil {
+			return fmt.Errorf("failed to get invites: %w", err)
+		}
+
+		result = make([]domain.Invite, len(invites))
+		for i := range invites {
+			result[i] = toDomainInvite(&invites[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// RevokeInvite deletes an invite code of a team
+func (u *Usecase) RevokeInvite(ctx context.Context, teamID, code string) error {
+	code = normalizeInviteCode(code)
+
+	return u.withinTx(ctx, func(ctx context.Context) error {
+		invite, err := u.repo.GetInvite(ctx, code)
+		if err != nil {
+			return fmt.Errorf("failed to get invite: %w", err)
+		}
+
+		// Codes of other teams are reported as missing, not forbidden
+		if invite == nil || invite.TeamID != teamID {
+			return usecase.ErrInviteNotFound
+		}
+
+		if err := u.repo.DeleteInvite(ctx, code); err != nil {
+			return fmt.Errorf("failed to revoke invite: %w", err)
+		}
+
+		return u.audit(ctx, teamID, domain.AuditInviteRevoked, targetInvite, code,
+			toDomainInvite(invite), nil)
+	})
+}
+
+// JoinByInvite adds the user to the invite's team and issues a secret. An
+// existing member of the team is a conflict.
+func (u *Usecase) JoinByInvite(ctx context.Context, params usecase.JoinByInviteParams) (*usecase.JoinByInviteResult, error) {
+	code := normalizeInviteCode(params.Code)
+
+	var result *usecase.JoinByInviteResult
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		invite, err := u.repo.GetInvite(ctx, code)
+		if err != nil {
+			return fmt.Errorf("failed to get invite: %w", err)
+		}
+
+		if invite == nil {
+			return usecase.ErrInviteNotFound
+		}
+
+		if invite.ExpiresAt != nil && !time.Now().Before(*invite.ExpiresAt) {
+			return usecase.ErrInviteExpired
+		}
+
+		used, err := u.repo.UseInvite(ctx, code)
+		if err != nil {
+			return fmt.Errorf("failed to use invite: %w", err)
+		}
+		if !used {
+			return usecase.ErrInviteExpired
+		}
+
+		// The new member is added on the authority of the invite
+		ctx = usecase.WithActor(ctx, domain.Actor{
+			Type: domain.ActorInvite,
+			Role: domain.Role(invite.Role),
+			ID:   invite.Code,
+		})
+
+		// Joining never changes an existing member, the invite and its
+		// use are rolled back with the conflict
+		added, err := u.AddUser(ctx, usecase.AddUserParams{
+			TeamID:     invite.TeamID,
+			User:       params.User,
+			CreateOnly: true,
+		})
+		if err != nil {
+			return err
+		}
+
+		role := domain.Role(invite.Role)
+		token, err := u.issueToken(ctx, invite.TeamID, role)
+		if err != nil {
+			return err
+		}
+
+		result = &usecase.JoinByInviteResult{
+			TeamID: invite.TeamID,
+			Role:   role,
+			Token:  token,
+			User:   added.User,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// newInviteCode returns a random code formatted as XXXX-XXXX
+func newInviteCode() (string, error) {
+	alphabetSize := big.NewInt(int64(len(inviteAlphabet)))
+
+	code := make([]byte, inviteCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate invite code: %w", err)
+		}
+		code[i] = inviteAlphabet[n.Int64()]
+	}
+
+	return string(code[:inviteCodeLength/2]) + "-" + string(code[inviteCodeLength/2:]), nil
+}
+
+// normalizeInviteCode makes codes typed by people match the stored form
+func normalizeInviteCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) == inviteCodeLength && !strings.Contains(code, "-") {
+		code = code[:inviteCodeLength/2] + "-" + code[inviteCodeLength/2:]
+	}
+	return code
+}
+
+// toDomainInvite converts a repository invite to a domain invite
+func toDomainInvite(invite *repository.Invite) domain.Invite {
+	return domain.Invite{
+		Code:      invite.Code,
+		TeamID:    invite.TeamID,
+		Role:      domain.Role(invite.Role),
+		MaxUses:   invite.MaxUses,
+		Uses:      invite.Uses,
+		ExpiresAt: invite.ExpiresAt,
+		CreatedAt: invite.CreatedAt,
+	}
+}
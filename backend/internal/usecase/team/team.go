@@ -1,105 +1,331 @@
 package team
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/metrics"
 	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/webhook"
 	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase/eventbus"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase/idgen"
 )
 
+// maxIDAttempts bounds how often CreateTeam retries on an ID collision
+const maxIDAttempts = 5
+
 // Usecase handles team-related business logic
 type Usecase struct {
-	repo *repository.Repository
+	repo     repository.Repository
+	idgen    idgen.Generator
+	bus      *eventbus.Bus
+	webhooks WebhookSender
+	retry    WebhookRetry
+	// operations counts committed changes by audit action
+	operations *metrics.Counter
+}
+
+// Option configures optional dependencies of the Usecase
+type Option func(*Usecase)
+
+// WithIDGenerator replaces the generator of team IDs,
+// e.g. with a deterministic one in tests
+func WithIDGenerator(generator idgen.Generator) Option {
+	return func(u *Usecase) {
+		u.idgen = generator
+	}
+}
+
+// WithEventBus sets the bus committed events are published to, so
+// other components can subscribe to the same one
+func WithEventBus(bus *eventbus.Bus) Option {
+	return func(u *Usecase) {
+		u.bus = bus
+	}
+}
+
+// WithMetrics counts the committed operations in reg, by the action of
+// their audit entries like team.created or member.added
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(u *Usecase) {
+		u.operations = reg.NewCounter(metrics.Namespace+"team_operations_total",
+			"Committed team operations by audit action.", "action")
+	}
 }
 
 // NewUsecase creates a new team Usecase instance
-func NewUsecase(repo *repository.Repository) *Usecase {
-	return &Usecase{
-		repo: repo,
+func NewUsecase(repo repository.Repository, opts ...Option) *Usecase {
+	u := &Usecase{
+		repo:     repo,
+		idgen:    idgen.NewRandom("team_"),
+		bus:      eventbus.New(eventbus.DefaultBuffer),
+		webhooks: webhook.NewSender(DefaultWebhookTimeout, nil),
+		retry:    DefaultWebhookRetry,
 	}
+
+	for _, opt := range opts {
+		opt(u)
+	}
+
+	return u
 }
 
 // CreateTeam creates a new team
-func (u *Usecase) CreateTeam(params usecase.CreateTeamParams) (*usecase.CreateTeamResult, error) {
-	// Generate unique team ID
-	teamID := fmt.Sprintf("team_%d", time.Now().UnixNano())
+func (u *Usecase) CreateTeam(ctx context.Context, params usecase.CreateTeamParams) (*usecase.CreateTeamResult, error) {
+	name := strings.TrimSpace(params.Name)
+	if err := domain.ValidateTeamName(name); err != nil {
+		return nil, err
+	}
 
 	// Create team in database
 	team := &repository.Team{
-		ID:        teamID,
-		Name:      params.Name,
-		CreatedAt: time.Now(),
+		Name:      name,
+		CreatedAt: time.Now().UTC(),
 	}
 
-	if err := u.repo.CreateTeam(team); err != nil {
-		return nil, fmt.Errorf("failed to create team: %w", err)
+	result := &usecase.CreateTeamResult{}
+
+	// Team and its secrets are created together
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		// Generate unique team ID, retrying if it is already taken
+		for attempt := 1; ; attempt++ {
+			id, err := u.idgen.NewID()
+			if err != nil {
+				return fmt.Errorf("failed to generate team id: %w", err)
+			}
+			team.ID = id
+
+			err = u.repo.CreateTeam(ctx, team)
+			if errors.Is(err, repository.ErrAlreadyExists) && attempt < maxIDAttempts {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to create team: %w", err)
+			}
+			break
+		}
+		result.ID = team.ID
+
+		var err error
+		if result.AdminToken, err = u.issueToken(ctx, team.ID, domain.RoleAdmin); err != nil {
+			return err
+		}
+		if result.ViewerToken, err = u.issueToken(ctx, team.ID, domain.RoleViewer); err != nil {
+			return err
+		}
+
+		return u.audit(ctx, team.ID, domain.AuditTeamCreated, targetTeam, team.ID,
+			nil, teamSnapshot{ID: team.ID, Name: team.Name})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return &usecase.CreateTeamResult{
-		ID: teamID,
-	}, nil
+	return result, nil
 }
 
 // GetTeam retrieves a team with all its users
-func (u *Usecase) GetTeam(teamID string) (*domain.Team, error) {
-	// Get team
-	team, err := u.repo.GetTeam(teamID)
+func (u *Usecase) GetTeam(ctx context.Context, teamID string) (*domain.Team, error) {
+	var result *domain.Team
+
+	// Read team and users from the same snapshot
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		// Get team
+		team, err := u.repo.GetTeam(ctx, teamID)
+		if err != nil {
+			return fmt.Errorf("failed to get team: %w", err)
+		}
+
+		if team == nil {
+			return usecase.ErrTeamNotFound
+		}
+
+		// Get team users
+		users, err := u.repo.GetTeamUsers(ctx, teamID)
+		if err != nil {
+			return fmt.Errorf("failed to get team users: %w", err)
+		}
+
+		// Convert repository users to domain users
+		domainUsers := make([]domain.User, len(users))
+		for i, user := range users {
+			domainUsers[i] = toDomainUser(&user)
+		}
+
+		result = &domain.Team{
+			ID:      team.ID,
+			Name:    team.Name,
+			Users:   domainUsers,
+			Version: team.Version,
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get team: %w", err)
+		return nil, err
 	}
 
-	if team == nil {
-		return nil, fmt.Errorf("team not found")
+	return result, nil
+}
+
+// UpdateTeam changes the given fields of a team and returns the result
+func (u *Usecase) UpdateTeam(ctx context.Context, params usecase.UpdateTeamParams) (*domain.Team, error) {
+	var name string
+	if params.Name != nil {
+		name = strings.TrimSpace(*params.Name)
+		if err := domain.ValidateTeamName(name); err != nil {
+			return nil, err
+		}
 	}
 
-	// Get team users
-	users, err := u.repo.GetTeamUsers(teamID)
+	var result *domain.Team
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		version, err := u.bumpTeam(ctx, params.TeamID, params.ExpectedVersion)
+		if err != nil {
+			return err
+		}
+
+		if params.Name != nil {
+			before, err := u.repo.GetTeam(ctx, params.TeamID)
+			if err != nil {
+				return fmt.Errorf("failed to get team: %w", err)
+			}
+
+			team := &repository.Team{ID: params.TeamID, Name: name}
+			if err := u.repo.UpdateTeam(ctx, team); err != nil {
+				return fmt.Errorf("failed to update team: %w", err)
+			}
+
+			err = u.record(ctx, domain.Event{
+				TeamID:  params.TeamID,
+				Type:    domain.EventTeamRenamed,
+				Version: version,
+				Name:    name,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = u.audit(ctx, params.TeamID, domain.AuditTeamRenamed, targetTeam, params.TeamID,
+				teamSnapshot{ID: before.ID, Name: before.Name}, teamSnapshot{ID: team.ID, Name: team.Name})
+			if err != nil {
+				return err
+			}
+		}
+
+		// Return the team as GetTeam does
+		result, err = u.GetTeam(ctx, params.TeamID)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get team users: %w", err)
+		return nil, err
 	}
 
-	// Convert repository users to domain users
-	domainUsers := make([]domain.User, len(users))
-	for i, user := range users {
-		domainUsers[i] = domain.User{
-			ID:                user.ID,
-			FirstName:         user.FirstName,
-			Initials:          user.Initials,
-			ParentNames:       user.ParentNames,
-			GrandParentsNames: user.GrandParentsNames,
-			Country:           user.Country,
+	return result, nil
+}
+
+// DeleteTeam moves a team to the trash
+func (u *Usecase) DeleteTeam(ctx context.Context, teamID string, expectedVersion int64) error {
+	return u.withinTx(ctx, func(ctx context.Context) error {
+		version, err := u.bumpTeam(ctx, teamID, expectedVersion)
+		if err != nil {
+			return err
+		}
+
+		before, err := u.repo.GetTeam(ctx, teamID)
+		if err != nil {
+			return fmt.Errorf("failed to get team: %w", err)
+		}
+
+		if err := u.repo.DeleteTeam(ctx, teamID, time.Now().UTC()); err != nil {
+			return fmt.Errorf("failed to delete team: %w", err)
 		}
-	}
 
-	return &domain.Team{
-		ID:    team.ID,
-		Name:  team.Name,
-		Users: domainUsers,
-	}, nil
+		err = u.record(ctx, domain.Event{
+			TeamID:  teamID,
+			Type:    domain.EventTeamDeleted,
+			Version: version,
+		})
+		if err != nil {
+			return err
+		}
+
+		return u.audit(ctx, teamID, domain.AuditTeamDeleted, targetTeam, teamID,
+			teamSnapshot{ID: before.ID, Name: before.Name}, nil)
+	})
 }
 
-// AddUser adds or updates a user in a team
-func (u *Usecase) AddUser(params usecase.AddUserParams) (*domain.User, error) {
-	// Verify team exists
-	team, err := u.repo.GetTeam(params.TeamID)
+// GetUser retrieves a member of a team
+func (u *Usecase) GetUser(ctx context.Context, teamID, userID string) (*usecase.GetUserResult, error) {
+	var result *usecase.GetUserResult
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		team, err := u.repo.GetTeam(ctx, teamID)
+		if err != nil {
+			return fmt.Errorf("failed to get team: %w", err)
+		}
+		if team == nil {
+			return usecase.ErrTeamNotFound
+		}
+
+		user, err := u.repo.GetUser(ctx, teamID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+
+		if user == nil {
+			return usecase.ErrUserNotFound
+		}
+
+		result = &usecase.GetUserResult{User: toDomainUser(user), TeamVersion: team.Version}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify team: %w", err)
+		return nil, err
 	}
 
-	if team == nil {
-		return nil, fmt.Errorf("team not found")
-	}
+	return result, nil
+}
 
-	// Check if user already exists
-	existingUser, err := u.repo.GetUser(params.User.ID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check existing user: %w", err)
+// AddUser adds or updates a user in a team.
+// The lookup and the write run in one transaction, so concurrent adds of
+// the same user don't race into a primary key error. A non-zero
+// params.User.Version must match the stored user. The ID of a member in
+// the trash can't be reused until the member is restored or purged.
+func (u *Usecase) AddUser(ctx context.Context, params usecase.AddUserParams) (*usecase.AddUserResult, error) {
+	params.User.Normalize()
+	if err := params.User.Validate(); err != nil {
+		return nil, err
 	}
 
-	if existingUser != nil {
-		// Update existing user
+	var result *usecase.AddUserResult
+
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		// Verify team exists and count the change
+		version, err := u.bumpTeam(ctx, params.TeamID, params.ExpectedTeamVersion)
+		if err != nil {
+			return err
+		}
+
+		// Check if user is already a member of this team. Members of other
+		// teams with the same ID are independent and never touched.
+		existingUser, err := u.repo.GetUser(ctx, params.TeamID, params.User.ID)
+		if err != nil {
+			return fmt.Errorf("failed to check existing user: %w", err)
+		}
+
+		if existingUser != nil && params.CreateOnly {
+			return usecase.ErrUserConflict
+		}
+
+		// The client edited a copy of a user that no longer exists or changed since
+		if params.User.Version != 0 && (existingUser == nil || existingUser.Version != params.User.Version) {
+			return usecase.ErrVersionMismatch
+		}
+
 		user := &repository.User{
 			ID:                params.User.ID,
 			TeamID:            params.TeamID,
@@ -108,66 +334,162 @@ func (u *Usecase) AddUser(params usecase.AddUserParams) (*domain.User, error) {
 			ParentNames:       params.User.ParentNames,
 			GrandParentsNames: params.User.GrandParentsNames,
 			Country:           params.User.Country,
-			CreatedAt:         existingUser.CreatedAt, // Preserve original creation time
+			Version:           params.User.Version,
+			CreatedAt:         time.Now().UTC(),
+			UpdatedAt:         time.Now().UTC(),
 		}
 
-		if err := u.repo.UpdateUser(user); err != nil {
-			return nil, fmt.Errorf("failed to update user: %w", err)
+		if existingUser != nil {
+			// Update existing user
+			user.CreatedAt = existingUser.CreatedAt // Preserve original creation time
+
+			err := u.repo.UpdateUser(ctx, user)
+			if errors.Is(err, repository.ErrVersionMismatch) {
+				return usecase.ErrVersionMismatch
+			}
+			if err != nil {
+				return fmt.Errorf("failed to update user: %w", err)
+			}
+		} else {
+			// Create new user
+			err := u.repo.CreateUser(ctx, user)
+			if errors.Is(err, repository.ErrAlreadyExists) {
+				return u.userConflict(ctx, params.TeamID, params.User.ID)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to create user: %w", err)
+			}
 		}
 
-		return &domain.User{
-			ID:                user.ID,
-			FirstName:         user.FirstName,
-			Initials:          user.Initials,
-			ParentNames:       user.ParentNames,
-			GrandParentsNames: user.GrandParentsNames,
-			Country:           user.Country,
-		}, nil
-	}
+		// Read back the stored user with its new version
+		stored, err := u.repo.GetUser(ctx, params.TeamID, params.User.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
 
-	// Create new user
-	user := &repository.User{
-		ID:                params.User.ID,
-		TeamID:            params.TeamID,
-		FirstName:         params.User.FirstName,
-		Initials:          params.User.Initials,
-		ParentNames:       params.User.ParentNames,
-		GrandParentsNames: params.User.GrandParentsNames,
-		Country:           params.User.Country,
-		CreatedAt:         time.Now(),
-	}
+		domainUser := toDomainUser(stored)
+		result = &usecase.AddUserResult{User: domainUser, TeamVersion: version}
 
-	if err := u.repo.CreateUser(user); err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		eventType := domain.EventMemberAdded
+		if existingUser != nil {
+			eventType = domain.EventMemberUpdated
+		}
+		err = u.record(ctx, domain.Event{
+			TeamID:  params.TeamID,
+			Type:    eventType,
+			Version: version,
+			UserID:  domainUser.ID,
+			User:    &domainUser,
+		})
+		if err != nil {
+			return err
+		}
+
+		if existingUser == nil {
+			return u.audit(ctx, params.TeamID, domain.AuditMemberAdded, targetMember, domainUser.ID,
+				nil, domainUser)
+		}
+		return u.audit(ctx, params.TeamID, domain.AuditMemberUpdated, targetMember, domainUser.ID,
+			toDomainUser(existingUser), domainUser)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return &domain.User{
-		ID:                user.ID,
-		FirstName:         user.FirstName,
-		Initials:          user.Initials,
-		ParentNames:       user.ParentNames,
-		GrandParentsNames: user.GrandParentsNames,
-		Country:           user.Country,
-	}, nil
+	return result, nil
 }
 
-// RemoveUser removes a user from a team
-func (u *Usecase) RemoveUser(teamID, userID string) error {
-	// Verify team exists
-	team, err := u.repo.GetTeam(teamID)
+// userConflict tells a member created concurrently from one in the trash,
+// whose ID stays taken until it is restored or purged
+func (u *Usecase) userConflict(ctx context.Context, teamID, userID string) error {
+	user, err := u.repo.GetUser(ctx, teamID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to verify team: %w", err)
+		return fmt.Errorf("failed to check existing user: %w", err)
 	}
+	if user == nil {
+		return usecase.ErrUserInTrash
+	}
+	return usecase.ErrUserConflict
+}
+
+// RemoveUser moves a user of a team to the trash
+func (u *Usecase) RemoveUser(ctx context.Context, teamID, userID string, expectedVersion int64) (int64, error) {
+	var version int64
+
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		// Verify team exists and count the change
+		var err error
+		version, err = u.bumpTeam(ctx, teamID, expectedVersion)
+		if err != nil {
+			return err
+		}
+
+		user, err := u.repo.GetUser(ctx, teamID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+
+		if user == nil {
+			return usecase.ErrUserNotFound
+		}
+
+		// Move user to the trash
+		err = u.repo.DeleteUser(ctx, teamID, userID, time.Now().UTC())
+		if errors.Is(err, repository.ErrNotFound) {
+			return usecase.ErrUserNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to remove user: %w", err)
+		}
+
+		err = u.record(ctx, domain.Event{
+			TeamID:  teamID,
+			Type:    domain.EventMemberRemoved,
+			Version: version,
+			UserID:  userID,
+		})
+		if err != nil {
+			return err
+		}
 
-	if team == nil {
-		return fmt.Errorf("team not found")
+		return u.audit(ctx, teamID, domain.AuditMemberRemoved, targetMember, userID,
+			toDomainUser(user), nil)
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	// Delete user
-	if err := u.repo.DeleteUser(teamID, userID); err != nil {
-		return fmt.Errorf("failed to remove user: %w", err)
+	return version, nil
+}
+
+// bumpTeam increments the team version for a change of the team or its
+// members and returns the new one. A non-zero expected version must match
+// the current one. It also locks the team row until the transaction ends,
+// so changes of one team are serialized.
+func (u *Usecase) bumpTeam(ctx context.Context, teamID string, expected int64) (int64, error) {
+	version, err := u.repo.BumpTeamVersion(ctx, teamID, expected, time.Now().UTC())
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		return 0, usecase.ErrVersionMismatch
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, usecase.ErrTeamNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to update team version: %w", err)
 	}
 
-	return nil
+	return version, nil
 }
 
+// toDomainUser converts a repository user to a domain user
+func toDomainUser(user *repository.User) domain.User {
+	return domain.User{
+		ID:                user.ID,
+		FirstName:         user.FirstName,
+		Initials:          user.Initials,
+		ParentNames:       user.ParentNames,
+		GrandParentsNames: user.GrandParentsNames,
+		Country:           user.Country,
+		Version:           user.Version,
+	}
+}
@@ -0,0 +1,154 @@
+package team
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+)
+
+// tokenBytes is the amount of randomness in a team secret
+const tokenBytes = 20
+
+// Authorize resolves a team secret into the access it grants
+func (u *Usecase) Authorize(ctx context.Context, secret string) (*domain.Access, error) {
+	if secret == "" {
+		return nil, usecase.ErrUnauthorized
+	}
+
+	token, err := u.repo.GetToken(ctx, hashToken(secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	if token != nil {
+		return &domain.Access{TeamID: token.TeamID, Role: domain.Role(token.Role), TokenID: tokenID(secret)}, nil
+	}
+
+	// Teams created before secrets existed were authorized by their ID.
+	// Keep it working as an admin secret until the first rotation.
+	team, err := u.repo.GetTeam(ctx, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team: %w", err)
+	}
+	if team != nil {
+		hasTokens, err := u.repo.HasTeamTokens(ctx, team.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check team tokens: %w", err)
+		}
+		if !hasTokens {
+			return &domain.Access{TeamID: team.ID, Role: domain.RoleAdmin, TokenID: tokenID(secret)}, nil
+		}
+	}
+
+	return nil, usecase.ErrUnauthorized
+}
+
+// RotateToken revokes all secrets of a role and issues a new one
+func (u *Usecase) RotateToken(ctx context.Context, teamID string, role domain.Role) (*usecase.RotateTokenResult, error) {
+	if !role.Valid() {
+		return nil, usecase.ErrInvalidRole
+	}
+
+	result := &usecase.RotateTokenResult{}
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		team, err := u.repo.GetTeam(ctx, teamID)
+		if err != nil {
+			return fmt.Errorf("failed to verify team: %w", err)
+		}
+
+		if team == nil {
+			return usecase.ErrTeamNotFound
+		}
+
+		// A legacy team gets both secrets on its first rotation, so that
+		// its ID stops working as a secret. They are all returned, or the
+		// team would be left without anyone holding the other one.
+		hasTokens, err := u.repo.HasTeamTokens(ctx, teamID)
+		if err != nil {
+			return fmt.Errorf("failed to check team tokens: %w", err)
+		}
+		if !hasTokens {
+			result.OtherTokens = make(map[domain.Role]string)
+			for _, other := range []domain.Role{domain.RoleAdmin, domain.RoleViewer} {
+				if other == role {
+					continue
+				}
+				if result.OtherTokens[other], err = u.issueToken(ctx, teamID, other); err != nil {
+					return err
+				}
+				err = u.audit(ctx, teamID, domain.AuditTokenRotated, targetToken, string(other), nil, nil)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := u.repo.DeleteTeamTokens(ctx, teamID, string(role)); err != nil {
+			return fmt.Errorf("failed to revoke tokens: %w", err)
+		}
+
+		if result.Token, err = u.issueToken(ctx, teamID, role); err != nil {
+			return err
+		}
+
+		// Neither the old nor the new secret goes into the log
+		return u.audit(ctx, teamID, domain.AuditTokenRotated, targetToken, string(role), nil, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// issueToken generates a secret for a role and stores its hash
+func (u *Usecase) issueToken(ctx context.Context, teamID string, role domain.Role) (string, error) {
+	secret, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	token := &repository.Token{
+		Hash:      hashToken(secret),
+		TeamID:    teamID,
+		Role:      string(role),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := u.repo.CreateToken(ctx, token); err != nil {
+		return "", fmt.Errorf("failed to create %s token: %w", role, err)
+	}
+
+	return secret, nil
+}
+
+// newToken returns a random secret, safe to use in URLs and headers
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)), nil
+}
+
+// tokenID returns a fingerprint of a secret for the audit log. It tells
+// secrets apart but is too short to look one up by its hash.
+func tokenID(secret string) string {
+	return hashToken(secret)[:12]
+}
+
+// hashToken returns the stored form of a secret
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
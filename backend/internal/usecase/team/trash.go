@@ -0,0 +1,154 @@
+package team
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+)
+
+// RestoreTeam brings a team back from the trash with its members
+func (u *Usecase) RestoreTeam(ctx context.Context, teamID string) (*domain.Team, error) {
+	var result *domain.Team
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		err := u.repo.RestoreTeam(ctx, teamID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return usecase.ErrNotInTrash
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore team: %w", err)
+		}
+
+		version, err := u.bumpTeam(ctx, teamID, 0)
+		if err != nil {
+			return err
+		}
+
+		err = u.record(ctx, domain.Event{
+			TeamID:  teamID,
+			Type:    domain.EventTeamRestored,
+			Version: version,
+		})
+		if err != nil {
+			return err
+		}
+
+		result, err = u.GetTeam(ctx, teamID)
+		if err != nil {
+			return err
+		}
+
+		return u.audit(ctx, teamID, domain.AuditTeamRestored, targetTeam, teamID,
+			nil, teamSnapshot{ID: result.ID, Name: result.Name})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// RestoreUser brings a member back from the trash of a team
+func (u *Usecase) RestoreUser(ctx context.Context, teamID, userID string) (*domain.User, error) {
+	var result *domain.User
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		version, err := u.bumpTeam(ctx, teamID, 0)
+		if err != nil {
+			return err
+		}
+
+		err = u.repo.RestoreUser(ctx, teamID, userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return usecase.ErrNotInTrash
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore user: %w", err)
+		}
+
+		user, err := u.repo.GetUser(ctx, teamID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+
+		domainUser := toDomainUser(user)
+		result = &domainUser
+
+		// For everyone watching, the member is back
+		err = u.record(ctx, domain.Event{
+			TeamID:  teamID,
+			Type:    domain.EventMemberAdded,
+			Version: version,
+			UserID:  domainUser.ID,
+			User:    &domainUser,
+		})
+		if err != nil {
+			return err
+		}
+
+		return u.audit(ctx, teamID, domain.AuditMemberRestored, targetMember, domainUser.ID,
+			nil, domainUser)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ListTrash returns the deleted members of a team
+func (u *Usecase) ListTrash(ctx context.Context, teamID string) ([]domain.DeletedUser, error) {
+	var result []domain.DeletedUser
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		if err := u.verifyTeam(ctx, teamID); err != nil {
+			return err
+		}
+
+		users, err := u.repo.GetDeletedUsers(ctx, teamID)
+		if err != nil {
+			return fmt.Errorf("failed to get deleted users: %w", err)
+		}
+
+		result = make([]domain.DeletedUser, len(users))
+		for i := range users {
+			result[i] = domain.DeletedUser{
+				User:      toDomainUser(&users[i]),
+				DeletedAt: *users[i].DeletedAt,
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// PurgeDeleted permanently removes what has been in the trash for
+// longer than retention
+func (u *Usecase) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := u.repo.PurgeDeleted(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return purged, fmt.Errorf("failed to purge deleted: %w", err)
+	}
+
+	return purged, nil
+}
+
+// verifyTeam returns ErrTeamNotFound unless the team exists and is not deleted
+func (u *Usecase) verifyTeam(ctx context.Context, teamID string) error {
+	team, err := u.repo.GetTeam(ctx, teamID)
+	if err != nil {
+		return fmt.Errorf("failed to verify team: %w", err)
+	}
+
+	if team == nil {
+		return usecase.ErrTeamNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,423 @@
+package team
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/webhook"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase/idgen"
+)
+
+// deliveryBatch bounds how many deliveries one DeliverWebhooks run sends
+const deliveryBatch = 50
+
+const (
+	// DeliveryLease hides a claimed delivery from other workers while it
+	// is sent. The send timeout must be shorter.
+	DeliveryLease = time.Minute
+	// DefaultWebhookTimeout bounds a single delivery attempt
+	DefaultWebhookTimeout = 10 * time.Second
+)
+
+// WebhookSender sends one delivery attempt and returns the response
+// status, see webhook.Sender
+type WebhookSender interface {
+	// CheckURL rejects targets the sender refuses to call
+	CheckURL(rawURL string) error
+	Send(ctx context.Context, req webhook.Request) (int, error)
+}
+
+// WebhookRetry is the backoff of failed webhook deliveries
+type WebhookRetry struct {
+	// MaxAttempts before a delivery is given up as failed
+	MaxAttempts int
+	// BaseDelay is the wait after the first failure, doubled after every
+	// further one up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultWebhookRetry gives a receiver about an hour to come back
+var DefaultWebhookRetry = WebhookRetry{
+	MaxAttempts: 8,
+	BaseDelay:   30 * time.Second,
+	MaxDelay:    time.Hour,
+}
+
+// delay returns the wait after the given number of failed attempts
+func (r WebhookRetry) delay(attempts int) time.Duration {
+	delay := r.BaseDelay
+	for i := 1; i < attempts && delay < r.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > r.MaxDelay {
+		delay = r.MaxDelay
+	}
+	return delay
+}
+
+// WithWebhookSender replaces how deliveries are sent
+func WithWebhookSender(sender WebhookSender) Option {
+	return func(u *Usecase) {
+		u.webhooks = sender
+	}
+}
+
+// WithWebhookRetry replaces the backoff of failed deliveries
+func WithWebhookRetry(retry WebhookRetry) Option {
+	return func(u *Usecase) {
+		u.retry = retry
+	}
+}
+
+// CreateWebhook registers a webhook for a team
+func (u *Usecase) CreateWebhook(ctx context.Context, params usecase.CreateWebhookParams) (*domain.Webhook, error) {
+	hook := domain.Webhook{
+		TeamID:    params.TeamID,
+		URL:       strings.TrimSpace(params.URL),
+		Events:    params.Events,
+		Secret:    params.Secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := hook.Validate(); err != nil {
+		return nil, err
+	}
+	if err := u.webhooks.CheckURL(hook.URL); err != nil {
+		return nil, domain.ForbiddenWebhookURL()
+	}
+
+	if hook.Secret == "" {
+		secret, err := newToken()
+		if err != nil {
+			return nil, err
+		}
+		hook.Secret = secret
+	}
+
+	events := make([]string, len(hook.Events))
+	for i, event := range hook.Events {
+		events[i] = string(event)
+	}
+
+	stored := &repository.Webhook{
+		TeamID:    hook.TeamID,
+		URL:       hook.URL,
+		Secret:    hook.Secret,
+		Events:    events,
+		CreatedAt: hook.CreatedAt,
+	}
+
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		if err := u.verifyTeam(ctx, params.TeamID); err != nil {
+			return err
+		}
+
+		// Generate unique webhook ID, retrying if it is already taken
+		ids := idgen.NewRandom("wh_")
+		for attempt := 1; ; attempt++ {
+			id, err := ids.NewID()
+			if err != nil {
+				return fmt.Errorf("failed to generate webhook id: %w", err)
+			}
+			stored.ID = id
+
+			err = u.repo.CreateWebhook(ctx, stored)
+			if errors.Is(err, repository.ErrAlreadyExists) && attempt < maxIDAttempts {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to create webhook: %w", err)
+			}
+			return u.audit(ctx, params.TeamID, domain.AuditWebhookCreated, targetWebhook, stored.ID,
+				nil, toDomainWebhook(stored))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hook.ID = stored.ID
+	return &hook, nil
+}
+
+// ListWebhooks returns the webhooks of a team without their secrets
+func (u *Usecase) ListWebhooks(ctx context.Context, teamID string) ([]domain.Webhook, error) {
+	var result []domain.Webhook
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		if err := u.verifyTeam(ctx, teamID); err != nil {
+			return err
+		}
+
+		webhooks, err := u.repo.GetTeamWebhooks(ctx, teamID)
+		if err != nil {
+			return fmt.Errorf("failed to get webhooks: %w", err)
+		}
+
+		result = make([]domain.Webhook, len(webhooks))
+		for i := range webhooks {
+			result[i] = toDomainWebhook(&webhooks[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// DeleteWebhook removes a webhook of a team
+func (u *Usecase) DeleteWebhook(ctx context.Context, teamID, webhookID string) error {
+	return u.withinTx(ctx, func(ctx context.Context) error {
+		hook, err := u.teamWebhook(ctx, teamID, webhookID)
+		if err != nil {
+			return err
+		}
+
+		if err := u.repo.DeleteWebhook(ctx, webhookID); err != nil {
+			return fmt.Errorf("failed to delete webhook: %w", err)
+		}
+
+		return u.audit(ctx, teamID, domain.AuditWebhookDeleted, targetWebhook, webhookID,
+			toDomainWebhook(hook), nil)
+	})
+}
+
+// ListWebhookDeliveries returns the delivery log of a webhook
+func (u *Usecase) ListWebhookDeliveries(ctx context.Context, teamID, webhookID string, limit int) ([]domain.WebhookDelivery, error) {
+	var result []domain.WebhookDelivery
+	err := u.withinTx(ctx, func(ctx context.Context) error {
+		if _, err := u.teamWebhook(ctx, teamID, webhookID); err != nil {
+			return err
+		}
+
+		deliveries, err := u.repo.GetDeliveries(ctx, webhookID, limit)
+		if err != nil {
+			return fmt.Errorf("failed to get deliveries: %w", err)
+		}
+
+		result = make([]domain.WebhookDelivery, len(deliveries))
+		for i := range deliveries {
+			result[i] = toDomainDelivery(&deliveries[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// DeliverWebhooks sends up to deliveryBatch due deliveries one after
+// another. Each is claimed right before it is sent, so that its lease
+// only has to cover its own attempt, however slow the receivers before.
+// Attempts rescheduled during the run wait for the next one.
+func (u *Usecase) DeliverWebhooks(ctx context.Context) (int, error) {
+	start := time.Now()
+	delivered := 0
+	for i := 0; i < deliveryBatch; i++ {
+		delivery, err := u.claimDelivery(ctx, start)
+		if err != nil {
+			return delivered, err
+		}
+		if delivery == nil {
+			break
+		}
+
+		ok, err := u.attemptDelivery(ctx, delivery)
+		if err != nil {
+			return delivered, err
+		}
+		if ok {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
+
+// claimDelivery leases the next delivery due at due, nil when none is
+func (u *Usecase) claimDelivery(ctx context.Context, due time.Time) (*repository.Delivery, error) {
+	var claimed []repository.Delivery
+	err := u.repo.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		claimed, err = u.repo.ClaimDeliveries(ctx, due, time.Now().Add(DeliveryLease), 1)
+		if err != nil {
+			return fmt.Errorf("failed to claim deliveries: %w", err)
+		}
+		return nil
+	})
+	if err != nil || len(claimed) == 0 {
+		return nil, err
+	}
+
+	return &claimed[0], nil
+}
+
+// attemptDelivery sends a claimed delivery and saves the outcome,
+// reporting whether the receiver accepted it
+func (u *Usecase) attemptDelivery(ctx context.Context, delivery *repository.Delivery) (bool, error) {
+	hook, err := u.repo.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	if hook == nil {
+		return false, nil // deleted since, and the delivery with it
+	}
+
+	status, sendErr := u.webhooks.Send(ctx, webhook.Request{
+		URL:        hook.URL,
+		Secret:     hook.Secret,
+		DeliveryID: delivery.ID,
+		EventType:  delivery.EventType,
+		Payload:    delivery.Payload,
+	})
+	if ctx.Err() != nil {
+		// Shutting down, the lease runs out and the attempt is repeated
+		return false, ctx.Err()
+	}
+
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = status
+	delivery.LastError = ""
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = repository.DeliveryDelivered
+	case delivery.Attempts >= u.retry.MaxAttempts:
+		delivery.Status = repository.DeliveryFailed
+		delivery.LastError = sendErr.Error()
+	default:
+		delivery.NextAttemptAt = now.Add(u.retry.delay(delivery.Attempts))
+		delivery.LastError = sendErr.Error()
+	}
+
+	if sendErr != nil {
+		logging.FromContext(ctx).Warn("webhook delivery failed",
+			"delivery_id", delivery.ID,
+			"webhook_id", hook.ID,
+			"attempt", delivery.Attempts,
+			"status", status,
+			"error", sendErr)
+	}
+
+	err = u.repo.UpdateDelivery(ctx, delivery)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to save delivery: %w", err)
+	}
+
+	return sendErr == nil, nil
+}
+
+// enqueueWebhooks queues a delivery of the event for every webhook of its
+// team that wants it. It runs in the transaction recording the event, so
+// an event is delivered if and only if its change is committed.
+func (u *Usecase) enqueueWebhooks(ctx context.Context, event domain.Event, payload []byte) error {
+	webhooks, err := u.repo.GetTeamWebhooks(ctx, event.TeamID)
+	if err != nil {
+		return fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	for _, hook := range webhooks {
+		if !wantsEvent(hook.Events, event.Type) {
+			continue
+		}
+
+		delivery := &repository.Delivery{
+			WebhookID:     hook.ID,
+			EventID:       event.ID,
+			EventType:     string(event.Type),
+			Payload:       payload,
+			Status:        repository.DeliveryPending,
+			NextAttemptAt: event.CreatedAt,
+			CreatedAt:     event.CreatedAt,
+		}
+		if err := u.repo.CreateDelivery(ctx, delivery); err != nil {
+			return fmt.Errorf("failed to queue delivery: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// teamWebhook returns a webhook of the team, ErrWebhookNotFound when it
+// does not exist or belongs to another team
+func (u *Usecase) teamWebhook(ctx context.Context, teamID, webhookID string) (*repository.Webhook, error) {
+	hook, err := u.repo.GetWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	if hook == nil || hook.TeamID != teamID {
+		return nil, usecase.ErrWebhookNotFound
+	}
+
+	return hook, nil
+}
+
+// wantsEvent reports whether a webhook filter includes the event type
+func wantsEvent(filter []string, eventType domain.EventType) bool {
+	if len(filter) == 0 {
+		return true
+	}
+
+	for _, t := range filter {
+		if t == string(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// toDomainWebhook converts a repository webhook to a domain webhook,
+// leaving out the secret
+func toDomainWebhook(hook *repository.Webhook) domain.Webhook {
+	events := make([]domain.EventType, len(hook.Events))
+	for i, event := range hook.Events {
+		events[i] = domain.EventType(event)
+	}
+
+	return domain.Webhook{
+		ID:        hook.ID,
+		TeamID:    hook.TeamID,
+		URL:       hook.URL,
+		Events:    events,
+		CreatedAt: hook.CreatedAt,
+	}
+}
+
+// toDomainDelivery converts a repository delivery to a domain delivery
+func toDomainDelivery(delivery *repository.Delivery) domain.WebhookDelivery {
+	result := domain.WebhookDelivery{
+		ID:             delivery.ID,
+		WebhookID:      delivery.WebhookID,
+		EventID:        delivery.EventID,
+		EventType:      domain.EventType(delivery.EventType),
+		Status:         domain.DeliveryStatus(delivery.Status),
+		Attempts:       delivery.Attempts,
+		LastAttemptAt:  delivery.LastAttemptAt,
+		ResponseStatus: delivery.ResponseStatus,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+	}
+
+	if delivery.Status == repository.DeliveryPending {
+		nextAttemptAt := delivery.NextAttemptAt
+		result.NextAttemptAt = &nextAttemptAt
+	}
+
+	return result
+}
@@ -0,0 +1,222 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the spans of the usecases in traces
+const tracerName = "github.com/kvloginov/cup-of-team/backend/internal/usecase"
+
+// traced creates a span for every call of the usecase it wraps
+type traced struct {
+	next TeamUsecase
+}
+
+// NewTraced wraps a usecase so that every call is traced as a span named
+// like TeamUsecase.GetTeam, a child of the span in its context.
+// Spans go to the global tracer provider, see tracing.Setup.
+func NewTraced(u TeamUsecase) TeamUsecase {
+	return &traced{next: u}
+}
+
+// start starts a span for a usecase call
+func start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// teamAttr identifies the team a call is about
+func teamAttr(teamID string) attribute.KeyValue {
+	return attribute.String("team.id", teamID)
+}
+
+// recordError adds err to the span and returns it. Domain errors are the
+// caller's mistake, like an unknown team, so only their code is recorded;
+// anything else marks the span as failed.
+func recordError(span trace.Span, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		span.SetAttributes(attribute.String("error.code", domainErr.Code))
+		return err
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
+
+func (t *traced) CreateTeam(ctx context.Context, params CreateTeamParams) (*CreateTeamResult, error) {
+	ctx, span := start(ctx, "TeamUsecase.CreateTeam")
+	defer span.End()
+	result, err := t.next.CreateTeam(ctx, params)
+	return result, recordError(span, err)
+}
+
+func (t *traced) GetTeam(ctx context.Context, teamID string) (*domain.Team, error) {
+	ctx, span := start(ctx, "TeamUsecase.GetTeam", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.GetTeam(ctx, teamID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) UpdateTeam(ctx context.Context, params UpdateTeamParams) (*domain.Team, error) {
+	ctx, span := start(ctx, "TeamUsecase.UpdateTeam", teamAttr(params.TeamID))
+	defer span.End()
+	result, err := t.next.UpdateTeam(ctx, params)
+	return result, recordError(span, err)
+}
+
+func (t *traced) DeleteTeam(ctx context.Context, teamID string, expectedVersion int64) error {
+	ctx, span := start(ctx, "TeamUsecase.DeleteTeam", teamAttr(teamID))
+	defer span.End()
+	return recordError(span, t.next.DeleteTeam(ctx, teamID, expectedVersion))
+}
+
+func (t *traced) RestoreTeam(ctx context.Context, teamID string) (*domain.Team, error) {
+	ctx, span := start(ctx, "TeamUsecase.RestoreTeam", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.RestoreTeam(ctx, teamID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) GetUser(ctx context.Context, teamID, userID string) (*GetUserResult, error) {
+	ctx, span := start(ctx, "TeamUsecase.GetUser", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.GetUser(ctx, teamID, userID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) AddUser(ctx context.Context, params AddUserParams) (*AddUserResult, error) {
+	ctx, span := start(ctx, "TeamUsecase.AddUser", teamAttr(params.TeamID))
+	defer span.End()
+	result, err := t.next.AddUser(ctx, params)
+	return result, recordError(span, err)
+}
+
+func (t *traced) RemoveUser(ctx context.Context, teamID, userID string, expectedVersion int64) (int64, error) {
+	ctx, span := start(ctx, "TeamUsecase.RemoveUser", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.RemoveUser(ctx, teamID, userID, expectedVersion)
+	return result, recordError(span, err)
+}
+
+func (t *traced) RestoreUser(ctx context.Context, teamID, userID string) (*domain.User, error) {
+	ctx, span := start(ctx, "TeamUsecase.RestoreUser", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.RestoreUser(ctx, teamID, userID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) ListTrash(ctx context.Context, teamID string) ([]domain.DeletedUser, error) {
+	ctx, span := start(ctx, "TeamUsecase.ListTrash", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.ListTrash(ctx, teamID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) WatchTeam(ctx context.Context, teamID string, lastEventID int64) (*TeamWatch, error) {
+	ctx, span := start(ctx, "TeamUsecase.WatchTeam", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.WatchTeam(ctx, teamID, lastEventID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	ctx, span := start(ctx, "TeamUsecase.PurgeDeleted")
+	defer span.End()
+	result, err := t.next.PurgeDeleted(ctx, retention)
+	return result, recordError(span, err)
+}
+
+func (t *traced) Authorize(ctx context.Context, secret string) (*domain.Access, error) {
+	ctx, span := start(ctx, "TeamUsecase.Authorize")
+	defer span.End()
+	result, err := t.next.Authorize(ctx, secret)
+	return result, recordError(span, err)
+}
+
+func (t *traced) RotateToken(ctx context.Context, teamID string, role domain.Role) (*RotateTokenResult, error) {
+	ctx, span := start(ctx, "TeamUsecase.RotateToken", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.RotateToken(ctx, teamID, role)
+	return result, recordError(span, err)
+}
+
+func (t *traced) CreateInvite(ctx context.Context, params CreateInviteParams) (*domain.Invite, error) {
+	ctx, span := start(ctx, "TeamUsecase.CreateInvite", teamAttr(params.TeamID))
+	defer span.End()
+	result, err := t.next.CreateInvite(ctx, params)
+	return result, recordError(span, err)
+}
+
+func (t *traced) ListInvites(ctx context.Context, teamID string) ([]domain.Invite, error) {
+	ctx, span := start(ctx, "TeamUsecase.ListInvites", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.ListInvites(ctx, teamID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) RevokeInvite(ctx context.Context, teamID, code string) error {
+	ctx, span := start(ctx, "TeamUsecase.RevokeInvite", teamAttr(teamID))
+	defer span.End()
+	return recordError(span, t.next.RevokeInvite(ctx, teamID, code))
+}
+
+func (t *traced) JoinByInvite(ctx context.Context, params JoinByInviteParams) (*JoinByInviteResult, error) {
+	ctx, span := start(ctx, "TeamUsecase.JoinByInvite")
+	defer span.End()
+	result, err := t.next.JoinByInvite(ctx, params)
+	return result, recordError(span, err)
+}
+
+func (t *traced) CreateWebhook(ctx context.Context, params CreateWebhookParams) (*domain.Webhook, error) {
+	ctx, span := start(ctx, "TeamUsecase.CreateWebhook", teamAttr(params.TeamID))
+	defer span.End()
+	result, err := t.next.CreateWebhook(ctx, params)
+	return result, recordError(span, err)
+}
+
+func (t *traced) ListWebhooks(ctx context.Context, teamID string) ([]domain.Webhook, error) {
+	ctx, span := start(ctx, "TeamUsecase.ListWebhooks", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.ListWebhooks(ctx, teamID)
+	return result, recordError(span, err)
+}
+
+func (t *traced) DeleteWebhook(ctx context.Context, teamID, webhookID string) error {
+	ctx, span := start(ctx, "TeamUsecase.DeleteWebhook", teamAttr(teamID))
+	defer span.End()
+	return recordError(span, t.next.DeleteWebhook(ctx, teamID, webhookID))
+}
+
+func (t *traced) ListWebhookDeliveries(ctx context.Context, teamID, webhookID string, limit int) ([]domain.WebhookDelivery, error) {
+	ctx, span := start(ctx, "TeamUsecase.ListWebhookDeliveries", teamAttr(teamID))
+	defer span.End()
+	result, err := t.next.ListWebhookDeliveries(ctx, teamID, webhookID, limit)
+	return result, recordError(span, err)
+}
+
+func (t *traced) DeliverWebhooks(ctx context.Context) (int, error) {
+	ctx, span := start(ctx, "TeamUsecase.DeliverWebhooks")
+	defer span.End()
+	result, err := t.next.DeliverWebhooks(ctx)
+	return result, recordError(span, err)
+}
+
+func (t *traced) ListAudit(ctx context.Context, params ListAuditParams) (*AuditPage, error) {
+	ctx, span := start(ctx, "TeamUsecase.ListAudit", teamAttr(params.TeamID))
+	defer span.End()
+	result, err := t.next.ListAudit(ctx, params)
+	return result, recordError(span, err)
+}
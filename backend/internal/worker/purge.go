@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// Purger permanently removes what has been deleted for longer than retention
+type Purger interface {
+	PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// PurgeConfig holds the schedule of the purge job
+type PurgeConfig struct {
+	// Interval between runs
+	Interval time.Duration
+	// Retention is how long deleted teams and members stay restorable
+	Retention time.Duration
+}
+
+// PurgeWorker empties the trash in the background
+type PurgeWorker struct {
+	purger Purger
+	config PurgeConfig
+}
+
+// NewPurgeWorker creates a new purge worker
+func NewPurgeWorker(purger Purger, config PurgeConfig) *PurgeWorker {
+	return &PurgeWorker{
+		purger: purger,
+		config: config,
+	}
+}
+
+// Run purges once immediately and then every interval until ctx is done
+func (w *PurgeWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce purges a single time, logging the result
+func (w *PurgeWorker) RunOnce(ctx context.Context) {
+	purged, err := w.purger.PurgeDeleted(ctx, w.config.Retention)
+	if err != nil {
+		logging.FromContext(ctx).Error("purge failed", "error", err)
+		return
+	}
+
+	if purged > 0 {
+		logging.FromContext(ctx).Info("purged deleted teams and members", "count", purged)
+	}
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+)
+
+// Deliverer sends the webhook deliveries that are due
+type Deliverer interface {
+	DeliverWebhooks(ctx context.Context) (int, error)
+}
+
+// WebhookConfig holds the schedule of the delivery job
+type WebhookConfig struct {
+	// Interval between polls of the delivery queue
+	Interval time.Duration
+}
+
+// WebhookWorker sends queued webhook deliveries in the background
+type WebhookWorker struct {
+	deliverer Deliverer
+	config    WebhookConfig
+}
+
+// NewWebhookWorker creates a new webhook worker
+func NewWebhookWorker(deliverer Deliverer, config WebhookConfig) *WebhookWorker {
+	return &WebhookWorker{
+		deliverer: deliverer,
+		config:    config,
+	}
+}
+
+// Run delivers once immediately and then every interval until ctx is done
+func (w *WebhookWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce sends the due deliveries a single time, logging the result
+func (w *WebhookWorker) RunOnce(ctx context.Context) {
+	delivered, err := w.deliverer.DeliverWebhooks(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			logging.FromContext(ctx).Error("webhook delivery run failed", "error", err)
+		}
+		return
+	}
+
+	if delivered > 0 {
+		logging.FromContext(ctx).Info("delivered webhooks", "count", delivered)
+	}
+}
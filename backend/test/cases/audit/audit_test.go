@@ -0,0 +1,274 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuditTestSuite struct {
+	env.BaseSuite
+}
+
+func TestAuditSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &AuditTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+func (s *AuditTestSuite) audit(t model.CreateTeamResponse, query string) model.ListAuditResponse {
+	w := s.Do(http.MethodGet, "/api/v1/teams/"+t.ID+"/audit"+query, nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var resp model.ListAuditResponse
+	s.Decode(w, &resp)
+	return resp
+}
+
+func (s *AuditTestSuite) addUser(t model.CreateTeamResponse, user domain.User) domain.User {
+	w := s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{TeamID: t.ID, User: user}, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var resp model.AddToTeamResponse
+	s.Decode(w, &resp)
+	return resp.User
+}
+
+func actions(entries []domain.AuditEntry) []domain.AuditAction {
+	result := make([]domain.AuditAction, len(entries))
+	for i, entry := range entries {
+		result[i] = entry.Action
+	}
+	return result
+}
+
+func (s *AuditTestSuite) TestRecordsMutations() {
+	t := s.CreateTeam("Audited Team")
+
+	user := s.addUser(t, domain.User{ID: "user1", FirstName: "John", Initials: "JD"})
+	user.FirstName = "Johnny"
+	s.addUser(t, user)
+
+	name := "Renamed Team"
+	w := s.Do(http.MethodPatch, "/api/v1/teams/"+t.ID, model.UpdateTeamRequest{Name: &name}, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	w = s.Do(http.MethodDelete, "/api/team/user?team_id="+t.ID+"&user_id=user1", nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	resp := s.audit(t, "")
+	s.Equal([]domain.AuditAction{
+		domain.AuditMemberRemoved,
+		domain.AuditTeamRenamed,
+		domain.AuditMemberUpdated,
+		domain.AuditMemberAdded,
+		domain.AuditTeamCreated,
+	}, actions(resp.Entries))
+	s.Zero(resp.NextCursor)
+
+	// Team creation needs no secret
+	created := resp.Entries[4]
+	s.Equal(domain.ActorAnonymous, created.Actor.Type)
+	s.Equal("team", created.TargetType)
+	s.JSONEq(fmt.Sprintf(`{"id":%q,"name":"Audited Team"}`, t.ID), string(created.After))
+
+	// Updates keep both snapshots of the member
+	updated := resp.Entries[2]
+	s.Equal("member", updated.TargetType)
+	s.Equal("user1", updated.TargetID)
+
+	var before, after domain.User
+	s.Require().NoError(json.Unmarshal(updated.Before, &before))
+	s.Require().NoError(json.Unmarshal(updated.After, &after))
+	s.Equal("John", before.FirstName)
+	s.Equal("Johnny", after.FirstName)
+
+	// Writes are attributed to the secret by its fingerprint
+	s.Equal(domain.ActorToken, updated.Actor.Type)
+	s.Equal(domain.RoleAdmin, updated.Actor.Role)
+	s.NotEmpty(updated.Actor.ID)
+	s.NotContains(t.AdminToken, updated.Actor.ID)
+
+	removed := resp.Entries[0]
+	s.NotEmpty(removed.Before)
+	s.Empty(removed.After)
+
+	renamed := resp.Entries[1]
+	s.JSONEq(fmt.Sprintf(`{"id":%q,"name":"Audited Team"}`, t.ID), string(renamed.Before))
+	s.JSONEq(fmt.Sprintf(`{"id":%q,"name":"Renamed Team"}`, t.ID), string(renamed.After))
+}
+
+func (s *AuditTestSuite) TestRecordsRequestMetadata() {
+	t := s.CreateTeam("Audited Team")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/team/user",
+		strings.NewReader(`{"team_id":"`+t.ID+`","user":{"id":"user1","first_name":"John","initials":"JD"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.AdminToken)
+	req.Header.Set("User-Agent", "audit-test/1.0")
+	req.Header.Set("X-Request-ID", "req-42")
+	req.RemoteAddr = "203.0.113.7:5150"
+
+	w := httptest.NewRecorder()
+	s.Router.ServeHTTP(w, req)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	resp := s.audit(t, "?limit=1")
+	s.Require().Len(resp.Entries, 1)
+	s.Equal(domain.RequestInfo{
+		RequestID: "req-42",
+		IP:        "203.0.113.7",
+		UserAgent: "audit-test/1.0",
+	}, resp.Entries[0].Request)
+}
+
+func (s *AuditTestSuite) TestPagination() {
+	t := s.CreateTeam("Audited Team")
+	for i := 1; i <= 4; i++ {
+		s.addUser(t, domain.User{ID: fmt.Sprintf("user%d", i), FirstName: "John", Initials: "JD"})
+	}
+
+	var seen []string
+	query := "?limit=2"
+	for page := 0; page < 5; page++ {
+		resp := s.audit(t, query)
+		for _, entry := range resp.Entries {
+			seen = append(seen, entry.TargetID)
+		}
+		if resp.NextCursor == 0 {
+			break
+		}
+		query = fmt.Sprintf("?limit=2&cursor=%d", resp.NextCursor)
+	}
+
+	s.Equal([]string{"user4", "user3", "user2", "user1", t.ID}, seen)
+}
+
+func (s *AuditTestSuite) TestTimeRange() {
+	t := s.CreateTeam("Audited Team")
+	s.addUser(t, domain.User{ID: "user1", FirstName: "John", Initials: "JD"})
+
+	past := url.QueryEscape(time.Now().Add(-time.Hour).Format(time.RFC3339))
+	future := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+
+	s.Len(s.audit(t, "?from="+past+"&to="+future).Entries, 2)
+	s.Empty(s.audit(t, "?from="+future).Entries)
+	s.Empty(s.audit(t, "?to="+past).Entries)
+
+	w := s.Do(http.MethodGet, "/api/v1/teams/"+t.ID+"/audit?from="+future+"&to="+past, nil, t.AdminToken)
+	s.Equal(http.StatusBadRequest, w.Code)
+
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+t.ID+"/audit?from=yesterday", nil, t.AdminToken)
+	s.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (s *AuditTestSuite) TestSecretsNotRecorded() {
+	t := s.CreateTeam("Audited Team")
+
+	w := s.Do(http.MethodPost, "/api/v1/teams/"+t.ID+"/tokens/viewer", nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	var rotated model.RotateTokenResponse
+	s.Decode(w, &rotated)
+
+	w = s.Do(http.MethodPost, "/api/v1/teams/"+t.ID+"/webhooks", model.CreateWebhookRequest{
+		URL:    "https://example.com/hook",
+		Secret: "0123456789abcdef-audit-secret",
+	}, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+t.ID+"/audit", nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.NotContains(body, rotated.Token)
+	s.NotContains(body, t.AdminToken)
+	s.NotContains(body, t.ViewerToken)
+	s.NotContains(body, "0123456789abcdef-audit-secret")
+
+	var resp model.ListAuditResponse
+	s.Require().NoError(json.Unmarshal([]byte(body), &resp))
+	s.Equal([]domain.AuditAction{
+		domain.AuditWebhookCreated,
+		domain.AuditTokenRotated,
+		domain.AuditTeamCreated,
+	}, actions(resp.Entries))
+	s.Equal("viewer", resp.Entries[1].TargetID)
+}
+
+func (s *AuditTestSuite) TestJoinAttributedToInvite() {
+	t := s.CreateTeam("Audited Team")
+
+	w := s.Do(http.MethodPost, "/api/v1/teams/"+t.ID+"/invites", model.CreateInviteRequest{}, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	var invite model.CreateInviteResponse
+	s.Decode(w, &invite)
+
+	w = s.Do(http.MethodPost, "/api/v1/invites/"+invite.Invite.Code+"/join", model.JoinByInviteRequest{
+		User: domain.User{ID: "user1", FirstName: "John", Initials: "JD"},
+	}, "")
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	resp := s.audit(t, "")
+	s.Equal([]domain.AuditAction{
+		domain.AuditMemberAdded,
+		domain.AuditInviteCreated,
+		domain.AuditTeamCreated,
+	}, actions(resp.Entries))
+	s.Equal(domain.Actor{
+		Type: domain.ActorInvite,
+		Role: domain.RoleViewer,
+		ID:   invite.Invite.Code,
+	}, resp.Entries[0].Actor)
+}
+
+func (s *AuditTestSuite) TestFailedWritesNotRecorded() {
+	t := s.CreateTeam("Audited Team")
+
+	w := s.Do(http.MethodDelete, "/api/team/user?team_id="+t.ID+"&user_id=ghost", nil, t.AdminToken)
+	s.Require().Equal(http.StatusNotFound, w.Code)
+
+	s.Equal([]domain.AuditAction{domain.AuditTeamCreated}, actions(s.audit(t, "").Entries))
+}
+
+func (s *AuditTestSuite) TestPurgedTeamKeepsAuditLog() {
+	t := s.CreateTeam("Purged Team")
+	s.addUser(t, domain.User{ID: "user1", FirstName: "John"})
+	w := s.Do(http.MethodDelete, "/api/v1/teams/"+t.ID, nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	purged, err := s.Usecase.PurgeDeleted(context.Background(), 0)
+	s.Require().NoError(err)
+	s.Positive(purged)
+
+	// Who deleted the team is still on record
+	entries, err := s.Repo.GetAudit(context.Background(), repository.AuditQuery{TeamID: t.ID, Limit: 10})
+	s.Require().NoError(err)
+	s.Require().Len(entries, 3)
+	s.Equal(string(domain.AuditTeamDeleted), entries[0].Action)
+	s.Equal(string(domain.AuditMemberAdded), entries[1].Action)
+	s.Equal(string(domain.AuditTeamCreated), entries[2].Action)
+}
+
+func (s *AuditTestSuite) TestRequiresAdmin() {
+	t := s.CreateTeam("Audited Team")
+	other := s.CreateTeam("Other Team")
+
+	w := s.Do(http.MethodGet, "/api/v1/teams/"+t.ID+"/audit", nil, t.ViewerToken)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+t.ID+"/audit", nil, other.AdminToken)
+	s.Equal(http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuthTestSuite struct {
+	env.BaseSuite
+}
+
+func TestAuthSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &AuthTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+func (s *AuthTestSuite) getTeam(teamID, token string) int {
+	return s.Do(http.MethodGet, fmt.Sprintf("/api/team?team_id=%s", teamID), nil, token).Code
+}
+
+func (s *AuthTestSuite) addUser(teamID, token string) int {
+	return s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{
+		TeamID: teamID,
+		User:   domain.User{ID: "user1", FirstName: "John"},
+	}, token).Code
+}
+
+func (s *AuthTestSuite) TestMissingOrInvalidToken() {
+	team := s.CreateTeam("Auth Team")
+
+	s.Equal(http.StatusUnauthorized, s.getTeam(team.ID, ""))
+	s.Equal(http.StatusUnauthorized, s.getTeam(team.ID, "not-a-token"))
+	s.Equal(http.StatusUnauthorized, s.getTeam(team.ID, team.ID), "team ID must not work as a secret")
+	s.Equal(http.StatusUnauthorized, s.addUser(team.ID, ""))
+}
+
+func (s *AuthTestSuite) TestViewerIsReadOnly() {
+	team := s.CreateTeam("Viewer Team")
+
+	s.Equal(http.StatusOK, s.getTeam(team.ID, team.ViewerToken))
+	s.Equal(http.StatusForbidden, s.addUser(team.ID, team.ViewerToken))
+
+	w := s.Do(http.MethodDelete, fmt.Sprintf("/api/team/user?team_id=%s&user_id=user1", team.ID), nil, team.ViewerToken)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	w = s.Do(http.MethodPost, "/api/v1/teams/"+team.ID+"/tokens/admin", nil, team.ViewerToken)
+	s.Equal(http.StatusForbidden, w.Code)
+}
+
+func (s *AuthTestSuite) TestAdminCanReadAndWrite() {
+	team := s.CreateTeam("Admin Team")
+
+	s.Equal(http.StatusOK, s.addUser(team.ID, team.AdminToken))
+	s.Equal(http.StatusOK, s.getTeam(team.ID, team.AdminToken))
+}
+
+func (s *AuthTestSuite) TestTokenOfAnotherTeam() {
+	teamA := s.CreateTeam("Team A")
+	teamB := s.CreateTeam("Team B")
+
+	s.Equal(http.StatusForbidden, s.getTeam(teamB.ID, teamA.ViewerToken))
+	s.Equal(http.StatusForbidden, s.addUser(teamB.ID, teamA.AdminToken))
+}
+
+func (s *AuthTestSuite) TestRotateViewerToken() {
+	team := s.CreateTeam("Rotate Viewer")
+
+	w := s.Do(http.MethodPost, "/api/v1/teams/"+team.ID+"/tokens/viewer", nil, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	var resp model.RotateTokenResponse
+	s.Decode(w, &resp)
+	s.NotEmpty(resp.Token)
+	s.NotEqual(team.ViewerToken, resp.Token)
+
+	s.Equal(http.StatusUnauthorized, s.getTeam(team.ID, team.ViewerToken), "old viewer token is revoked")
+	s.Equal(http.StatusOK, s.getTeam(team.ID, resp.Token))
+	s.Equal(http.StatusOK, s.addUser(team.ID, team.AdminToken), "admin token is unchanged")
+}
+
+func (s *AuthTestSuite) TestRotateAdminToken() {
+	team := s.CreateTeam("Rotate Admin")
+
+	w := s.Do(http.MethodPost, "/api/v1/teams/"+team.ID+"/tokens/admin", nil, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	var resp model.RotateTokenResponse
+	s.Decode(w, &resp)
+
+	s.Equal(http.StatusUnauthorized, s.addUser(team.ID, team.AdminToken), "old admin token is revoked")
+	s.Equal(http.StatusOK, s.addUser(team.ID, resp.Token))
+	s.Equal(http.StatusOK, s.getTeam(team.ID, team.ViewerToken), "viewer token is unchanged")
+}
+
+func (s *AuthTestSuite) TestRotateRejectsUnknownRole() {
+	team := s.CreateTeam("Bad Role")
+
+	w := s.Do(http.MethodPost, "/api/v1/teams/"+team.ID+"/tokens/owner", nil, team.AdminToken)
+	s.Equal(http.StatusBadRequest, w.Code)
+}
+
+// TestLegacyTeamIDUntilRotation covers teams created before secrets existed
+func (s *AuthTestSuite) TestLegacyTeamIDUntilRotation() {
+	legacyID := "team_legacy"
+	err := s.Repo.CreateTeam(context.Background(), &repository.Team{ID: legacyID, Name: "Legacy", CreatedAt: time.Now()})
+	s.Require().NoError(err)
+
+	s.Equal(http.StatusOK, s.getTeam(legacyID, legacyID))
+	s.Equal(http.StatusOK, s.addUser(legacyID, legacyID))
+
+	w := s.Do(http.MethodPost, "/api/v1/teams/"+legacyID+"/tokens/admin", nil, legacyID)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	var resp model.RotateTokenResponse
+	s.Decode(w, &resp)
+
+	s.Equal(http.StatusUnauthorized, s.getTeam(legacyID, legacyID), "team ID stops working after rotation")
+	s.Equal(http.StatusOK, s.addUser(legacyID, resp.Token))
+}
+
+// TestLegacyViewerRotationKeepsAdmin checks that the admin secret issued
+// with the first viewer secret of a legacy team is handed out too
+func (s *AuthTestSuite) TestLegacyViewerRotationKeepsAdmin() {
+	legacyID := "team_legacy_viewer"
+	err := s.Repo.CreateTeam(context.Background(), &repository.Team{ID: legacyID, Name: "Legacy", CreatedAt: time.Now()})
+	s.Require().NoError(err)
+
+	w := s.Do(http.MethodPost, "/api/v1/teams/"+legacyID+"/tokens/viewer", nil, legacyID)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var resp model.RotateTokenResponse
+	s.Decode(w, &resp)
+	admin := resp.OtherTokens["admin"]
+	s.Require().NotEmpty(admin)
+	s.Len(resp.OtherTokens, 1)
+
+	s.Equal(http.StatusUnauthorized, s.getTeam(legacyID, legacyID), "team ID stops working after rotation")
+	s.Equal(http.StatusOK, s.getTeam(legacyID, resp.Token))
+	s.Equal(http.StatusForbidden, s.addUser(legacyID, resp.Token))
+	s.Equal(http.StatusOK, s.addUser(legacyID, admin), "admin access survives the rotation")
+
+	// Later rotations only return the rotated secret
+	w = s.Do(http.MethodPost, "/api/v1/teams/"+legacyID+"/tokens/viewer", nil, admin)
+	s.Require().Equal(http.StatusOK, w.Code)
+	resp = model.RotateTokenResponse{}
+	s.Decode(w, &resp)
+	s.Empty(resp.OtherTokens)
+}
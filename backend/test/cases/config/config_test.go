@@ -0,0 +1,207 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/config"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/db"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigTestSuite struct {
+	suite.Suite
+}
+
+func TestConfigSuite(t *testing.T) {
+	suite.Run(t, new(ConfigTestSuite))
+}
+
+// env returns a lookup of the given variables only
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	}
+}
+
+// writeFile writes a configuration file and returns its path
+func (s *ConfigTestSuite) writeFile(content string) string {
+	path := filepath.Join(s.T().TempDir(), "config.yaml")
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// load loads a configuration that is expected to be valid
+func (s *ConfigTestSuite) load(args []string, vars map[string]string) (config.Config, config.Options) {
+	cfg, options, err := config.Load(args, env(vars), io.Discard)
+	s.Require().NoError(err)
+	s.Require().NoError(cfg.Validate())
+	return cfg, options
+}
+
+func (s *ConfigTestSuite) TestDefaults() {
+	cfg, options := s.load(nil, nil)
+
+	s.Equal(config.Default(), cfg)
+	s.Equal(8080, cfg.Server.Port)
+	s.Equal(":8080", cfg.HTTPConfig().Port)
+	s.Equal(db.DriverSQLite, cfg.DBConfig().Driver)
+	s.Equal("db/cup-of-team.db", cfg.DBConfig().DSN)
+	s.Equal(db.DefaultQueryTimeout, cfg.DBConfig().QueryTimeout)
+	s.False(options.PrintConfig)
+	s.Empty(options.File)
+}
+
+func (s *ConfigTestSuite) TestPrecedence() {
+	file := s.writeFile(`
+server:
+  port: 7000
+  shutdown_timeout: 5s
+database:
+  driver: postgres
+  dsn: postgres://cup@db/cup
+log:
+  level: warn
+cors:
+  allowed_origins: [https://cup.example]
+`)
+	vars := map[string]string{
+		config.FileEnv:   file,
+		"PORT":           "7001",
+		"LOG_LEVEL":      "debug",
+		"LOG_FORMAT":     "", // empty counts as unset
+		"PURGE_INTERVAL": "10m",
+	}
+
+	cfg, options := s.load([]string{"--server.port", "7002", "--tracing.insecure"}, vars)
+	s.Equal(file, options.File)
+
+	// Flags over the environment over the file over the defaults
+	s.Equal(7002, cfg.Server.Port)
+	s.Equal("debug", cfg.Log.Level)
+	s.Equal(5*time.Second, cfg.Server.ShutdownTimeout)
+	s.Equal("json", cfg.Log.Format)
+	s.Equal(10*time.Minute, cfg.Workers.PurgeInterval)
+	s.True(cfg.Tracing.Insecure)
+
+	s.Equal(db.DriverPostgres, cfg.DBConfig().Driver)
+	s.Equal("postgres://cup@db/cup", cfg.DBConfig().DSN)
+	s.Equal([]string{"https://cup.example"}, cfg.HTTPConfig().CORS.AllowedOrigins)
+	s.Equal(config.Default().CORS.AllowedMethods, cfg.HTTPConfig().CORS.AllowedMethods)
+}
+
+func (s *ConfigTestSuite) TestFileFlagOverridesEnv() {
+	fromFlag := s.writeFile("server:\n  port: 7100\n")
+	cfg, options := s.load([]string{"--config", fromFlag}, map[string]string{config.FileEnv: "/no/such/file.yaml"})
+	s.Equal(fromFlag, options.File)
+	s.Equal(7100, cfg.Server.Port)
+}
+
+func (s *ConfigTestSuite) TestListsAndDurationsFromEnv() {
+	cfg, _ := s.load(nil, map[string]string{
+		"CORS_ALLOWED_ORIGINS": "capacitor://*, https://cup.example,",
+		"CORS_MAX_AGE":         "1h",
+		"WEBHOOK_MAX_ATTEMPTS": "3",
+		"RATE_LIMIT_ENABLED":   "false",
+		"RATE_LIMIT_BACKOFF":   "1m",
+	})
+
+	s.Equal([]string{"capacitor://*", "https://cup.example"}, cfg.CORS.AllowedOrigins)
+	s.Equal(time.Hour, cfg.HTTPConfig().CORS.MaxAge)
+	s.Equal(3, cfg.WebhookRetry().MaxAttempts)
+	s.False(cfg.HTTPConfig().RateLimit.Enabled)
+	s.Equal(time.Minute, cfg.HTTPConfig().RateLimit.Backoff)
+}
+
+func (s *ConfigTestSuite) TestLoadErrors() {
+	cases := map[string]struct {
+		args []string
+		vars map[string]string
+	}{
+		"bad duration in env":  {vars: map[string]string{"HTTP_READ_TIMEOUT": "soon"}},
+		"bad number in flag":   {args: []string{"--server.port", "eighty"}},
+		"bad bool in env":      {vars: map[string]string{"TRACING_INSECURE": "maybe"}},
+		"unknown flag":         {args: []string{"--server.nope", "1"}},
+		"positional argument":  {args: []string{"serve"}},
+		"missing file":         {args: []string{"--config", filepath.Join(s.T().TempDir(), "missing.yaml")}},
+		"unknown key in file":  {args: []string{"--config", s.writeFile("server:\n  prot: 80\n")}},
+		"bad duration in file": {args: []string{"--config", s.writeFile("server:\n  read_timeout: soon\n")}},
+	}
+
+	for name, c := range cases {
+		_, _, err := config.Load(c.args, env(c.vars), io.Discard)
+		s.Error(err, name)
+	}
+
+	_, _, err := config.Load([]string{"--help"}, env(nil), io.Discard)
+	s.True(errors.Is(err, flag.ErrHelp))
+}
+
+func (s *ConfigTestSuite) TestValidate() {
+	cases := map[string]func(c *config.Config){
+		"port":            func(c *config.Config) { c.Server.Port = 70000 },
+		"timeout":         func(c *config.Config) { c.Server.ReadTimeout = 0 },
+		"driver":          func(c *config.Config) { c.Database.Driver = "oracle" },
+		"postgres dsn":    func(c *config.Config) { c.Database.Driver = string(db.DriverPostgres) },
+		"migration mode":  func(c *config.Config) { c.Database.MigrationMode = "down" },
+		"log level":       func(c *config.Config) { c.Log.Level = "loud" },
+		"log format":      func(c *config.Config) { c.Log.Format = "xml" },
+		"exporter":        func(c *config.Config) { c.Tracing.Exporter = "zipkin" },
+		"cors":            func(c *config.Config) { c.CORS.AllowedOrigins = []string{"*"}; c.CORS.AllowCredentials = true },
+		"rate limit":      func(c *config.Config) { c.RateLimit.MaxBackoff = time.Second },
+		"webhook timeout": func(c *config.Config) { c.Webhooks.Timeout = 2 * time.Minute },
+		"webhook delays":  func(c *config.Config) { c.Webhooks.MaxDelay = time.Second },
+		"webhook network": func(c *config.Config) { c.Webhooks.AllowedNetworks = []string{"localnet"} },
+		"query timeout":   func(c *config.Config) { c.Database.QueryTimeout = 0 },
+	}
+
+	for name, change := range cases {
+		cfg := config.Default()
+		change(&cfg)
+		s.Error(cfg.Validate(), name)
+	}
+
+	// A negative query timeout turns the bound off
+	cfg := config.Default()
+	cfg.Database.QueryTimeout = -1
+	s.NoError(cfg.Validate())
+
+	// All problems are reported at once
+	cfg = config.Default()
+	cfg.Server.Port = 0
+	cfg.Log.Level = "loud"
+	err := cfg.Validate()
+	s.Require().Error(err)
+	s.Contains(err.Error(), "server.port")
+	s.Contains(err.Error(), "log.level")
+}
+
+func (s *ConfigTestSuite) TestPrintConfig() {
+	cfg, options := s.load([]string{"--print-config", "--database.dsn", "postgres://cup:secret@db/cup", "--database.driver", "postgres"}, nil)
+	s.True(options.PrintConfig)
+
+	var out bytes.Buffer
+	s.Require().NoError(cfg.Write(&out))
+	s.NotContains(out.String(), "secret")
+	s.Contains(out.String(), "postgres://cup:xxxxx@db/cup")
+	s.Contains(out.String(), "read_timeout: 15s")
+
+	// The printed configuration loads back to the same one, but the password
+	printed := s.writeFile(out.String())
+	reloaded, _ := s.load([]string{"--config", printed}, nil)
+	cfg.Database.DSN = "postgres://cup:xxxxx@db/cup"
+	s.Equal(cfg, reloaded)
+
+	// Passwords of key=value DSNs are masked too
+	cfg.Database.DSN = "host=db user=cup password=secret dbname=cup"
+	out.Reset()
+	s.Require().NoError(cfg.Write(&out))
+	s.NotContains(out.String(), "secret")
+}
@@ -0,0 +1,157 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/handlers"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+type CORSTestSuite struct {
+	env.BaseSuite
+}
+
+func TestCORSSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &CORSTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+// serve sends a request from origin through a server with the given policy
+func (s *CORSTestSuite) serve(config httpServer.CORSConfig, method, target, origin string, header http.Header) *httptest.ResponseRecorder {
+	server := httpServer.NewServer(httpServer.Config{CORS: config})
+	handlers.NewHandlers(s.Usecase).RegisterRoutes(server)
+
+	r := httptest.NewRequest(method, target, nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for name, values := range header {
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, r)
+	return w
+}
+
+// preflight asks whether a PUT with an Authorization header may be sent
+func (s *CORSTestSuite) preflight(config httpServer.CORSConfig, target, origin string) *httptest.ResponseRecorder {
+	return s.serve(config, http.MethodOptions, target, origin, http.Header{
+		"Access-Control-Request-Method":  {http.MethodPut},
+		"Access-Control-Request-Headers": {"authorization, content-type"},
+	})
+}
+
+func (s *CORSTestSuite) TestMobileAppsAreAllowedByDefault() {
+	for _, origin := range []string{"capacitor://localhost", "ionic://localhost", "https://localhost", "http://localhost"} {
+		w := s.serve(httpServer.CORSConfig{}, http.MethodGet, "/health", origin, nil)
+		s.Equal(http.StatusOK, w.Code, origin)
+		s.Equal(origin, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		s.Empty(w.Header().Get("Access-Control-Allow-Credentials"), origin)
+		s.Contains(w.Header().Get("Access-Control-Expose-Headers"), httpServer.RequestIDHeader)
+		s.Contains(w.Header().Values("Vary"), "Origin")
+	}
+}
+
+func (s *CORSTestSuite) TestOtherOriginsAreRejected() {
+	team := s.CreateTeam("CORS Team")
+
+	for _, origin := range []string{"https://evil.example", "null", "http://localhost.evil.example", "capacitor://localhost/x"} {
+		w := s.serve(httpServer.CORSConfig{}, http.MethodGet, "/api/v1/teams/"+team.ID, origin,
+			http.Header{"Authorization": {"Bearer " + team.ViewerToken}})
+		s.Equal(http.StatusForbidden, w.Code, origin)
+		s.Empty(w.Header().Get("Access-Control-Allow-Origin"), origin)
+		s.NotContains(w.Body.String(), team.ID, origin)
+
+		var resp model.ErrorResponse
+		s.Decode(w, &resp)
+		s.Equal(httpServer.CodeForbidden, resp.Code)
+
+		w = s.preflight(httpServer.CORSConfig{}, "/api/v1/teams/"+team.ID, origin)
+		s.Equal(http.StatusForbidden, w.Code, origin)
+		s.Empty(w.Header().Get("Access-Control-Allow-Origin"), origin)
+	}
+}
+
+func (s *CORSTestSuite) TestSameOriginIsAllowed() {
+	// Browsers send Origin on same-origin writes, like from the bundled frontend
+	w := s.serve(httpServer.CORSConfig{}, http.MethodGet, "http://cup.example/health", "https://cup.example", nil)
+	s.Equal(http.StatusOK, w.Code)
+	s.Empty(w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func (s *CORSTestSuite) TestPreflightForEveryRoute() {
+	team := s.CreateTeam("CORS Team")
+
+	for _, target := range []string{"/api/v1/teams/" + team.ID + "/members/user1", "/api/team", "/health"} {
+		w := s.preflight(httpServer.CORSConfig{}, target, "capacitor://localhost")
+		s.Equal(http.StatusNoContent, w.Code, target)
+		s.Equal("capacitor://localhost", w.Header().Get("Access-Control-Allow-Origin"), target)
+		s.Contains(w.Header().Get("Access-Control-Allow-Methods"), http.MethodPut, target)
+		s.Contains(w.Header().Get("Access-Control-Allow-Headers"), "Authorization", target)
+		s.Equal("86400", w.Header().Get("Access-Control-Max-Age"), target)
+		s.Empty(w.Body.String(), target)
+	}
+
+	// The preflight did not run the handler
+	w := s.Do(http.MethodGet, "/api/v1/teams/"+team.ID+"/members/user1", nil, team.ViewerToken)
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
+func (s *CORSTestSuite) TestConfiguredPolicy() {
+	config := httpServer.CORSConfig{
+		AllowedOrigins:   []string{"https://*.cup.example", "https://cup.example"},
+		AllowedMethods:   []string{http.MethodGet},
+		AllowedHeaders:   []string{"Authorization"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	s.Require().NoError(config.Validate())
+
+	w := s.serve(config, http.MethodGet, "/health", "https://app.cup.example", nil)
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("https://app.cup.example", w.Header().Get("Access-Control-Allow-Origin"))
+	s.Equal("true", w.Header().Get("Access-Control-Allow-Credentials"))
+
+	// The defaults are replaced, not extended
+	w = s.serve(config, http.MethodGet, "/health", "capacitor://localhost", nil)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// Preflights only allow the configured methods
+	w = s.preflight(config, "/health", "https://cup.example")
+	s.Equal(http.StatusForbidden, w.Code)
+	w = s.serve(config, http.MethodOptions, "/health", "https://cup.example",
+		http.Header{"Access-Control-Request-Method": {http.MethodGet}})
+	s.Equal(http.StatusNoContent, w.Code)
+	s.Equal("GET", w.Header().Get("Access-Control-Allow-Methods"))
+	s.Equal("Authorization", w.Header().Get("Access-Control-Allow-Headers"))
+	s.Equal("600", w.Header().Get("Access-Control-Max-Age"))
+}
+
+func (s *CORSTestSuite) TestAnyOrigin() {
+	config := httpServer.CORSConfig{AllowedOrigins: []string{"*"}}
+	s.Require().NoError(config.Validate())
+
+	w := s.serve(config, http.MethodGet, "/health", "https://anywhere.example", nil)
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("*", w.Header().Get("Access-Control-Allow-Origin"))
+	s.Empty(w.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func (s *CORSTestSuite) TestInvalidConfig() {
+	s.Error(httpServer.CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}.Validate())
+	s.Error(httpServer.CORSConfig{AllowedOrigins: []string{"https://[cup.example"}}.Validate())
+	s.Error(httpServer.CORSConfig{MaxAge: -time.Second}.Validate())
+	s.NoError(httpServer.CORSConfig{}.Validate())
+}
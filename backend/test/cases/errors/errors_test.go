@@ -0,0 +1,236 @@
+package errors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/handlers"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/db"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase/team"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorsTestSuite struct {
+	env.BaseSuite
+}
+
+func TestErrorsSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &ErrorsTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+// expectError checks the status and the machine-readable code of a response
+func (s *ErrorsTestSuite) expectError(method, target string, body interface{}, token string, status int, code string) {
+	w := s.Do(method, target, body, token)
+	s.Require().Equal(status, w.Code, "body: %s", w.Body.String())
+
+	var resp model.ErrorResponse
+	s.Decode(w, &resp)
+	s.False(resp.Success)
+	s.Equal(code, resp.Code)
+	s.NotEmpty(resp.Error)
+}
+
+func (s *ErrorsTestSuite) TestStatusCodes() {
+	teamA := s.CreateTeam("Errors A")
+	teamB := s.CreateTeam("Errors B")
+
+	s.Run("Unauthorized", func() {
+		target := fmt.Sprintf("/api/team?team_id=%s", teamA.ID)
+		s.expectError(http.MethodGet, target, nil, "", http.StatusUnauthorized, "token_required")
+		s.expectError(http.MethodGet, target, nil, "bogus", http.StatusUnauthorized, "invalid_token")
+	})
+
+	s.Run("Forbidden", func() {
+		target := fmt.Sprintf("/api/team?team_id=%s", teamB.ID)
+		s.expectError(http.MethodGet, target, nil, teamA.ViewerToken, http.StatusForbidden, "wrong_team")
+		s.expectError(http.MethodPost, "/api/team/user", model.AddToTeamRequest{
+			TeamID: teamA.ID,
+			User:   domain.User{ID: "user1", FirstName: "John"},
+		}, teamA.ViewerToken, http.StatusForbidden, "role_forbidden")
+	})
+
+	s.Run("UserNotFound", func() {
+		target := fmt.Sprintf("/api/team/user?team_id=%s&user_id=missing", teamA.ID)
+		s.expectError(http.MethodDelete, target, nil, teamA.AdminToken, http.StatusNotFound, "user_not_found")
+	})
+
+	s.Run("InviteNotFound", func() {
+		s.expectError(http.MethodPost, "/api/v1/invites/AAAA-AAAA/join", model.JoinByInviteRequest{
+			User: domain.User{ID: "user1", FirstName: "John"},
+		}, "", http.StatusNotFound, "invite_not_found")
+	})
+
+	s.Run("InvalidRequest", func() {
+		s.expectError(http.MethodPost, "/api/team", model.CreateTeamRequest{}, "", http.StatusBadRequest, "invalid_request")
+	})
+}
+
+func (s *ErrorsTestSuite) TestUsecaseErrorKinds() {
+	ctx := context.Background()
+
+	_, err := s.Usecase.GetTeam(ctx, "team_missing")
+	s.ErrorIs(err, usecase.ErrTeamNotFound)
+	s.ErrorIs(err, domain.ErrNotFound)
+
+	team := s.CreateTeam("Kinds")
+	_, err = s.Usecase.RotateToken(ctx, team.ID, domain.Role("owner"))
+	s.ErrorIs(err, domain.ErrValidation)
+
+	_, err = s.Usecase.RemoveUser(ctx, team.ID, "missing", 0)
+	s.ErrorIs(err, usecase.ErrUserNotFound)
+
+	err = s.Repo.DeleteUser(ctx, team.ID, "missing", time.Now())
+	s.ErrorIs(err, repository.ErrNotFound)
+}
+
+// TestStorageFailureIsInternal checks that a broken database is reported
+// as 500 without details instead of being mistaken for a missing team
+func (s *ErrorsTestSuite) TestStorageFailureIsInternal() {
+	if s.Driver != db.DriverSQLite {
+		s.T().Skip("needs a database that can be closed")
+	}
+
+	database, err := db.New(db.Config{
+		Driver: db.DriverSQLite,
+		DSN:    filepath.Join(s.T().TempDir(), "broken.db"),
+	})
+	s.Require().NoError(err)
+
+	h := handlers.NewHandlers(team.NewUsecase(repository.NewSQLite(database.DB)))
+	server := httpServer.NewServer(httpServer.Config{})
+	h.RegisterRoutes(server)
+
+	s.Require().NoError(database.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/team", strings.NewReader(`{"name":"Broken"}`))
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	s.Require().Equal(http.StatusInternalServerError, w.Code)
+
+	var resp model.ErrorResponse
+	s.Decode(w, &resp)
+	s.Equal("internal", resp.Code)
+	s.Equal("Failed to create team", resp.Error)
+}
+
+// TestQueryTimeoutIsUnavailable checks that queries running past the
+// query timeout are reported as 503, so that clients retry later
+func (s *ErrorsTestSuite) TestQueryTimeoutIsUnavailable() {
+	if s.Driver != db.DriverSQLite {
+		s.T().Skip("needs a database with query timeouts")
+	}
+
+	repo := repository.NewSQLite(s.DB.DB, repository.WithQueryTimeout(time.Nanosecond))
+	h := handlers.NewHandlers(team.NewUsecase(repo))
+	server := httpServer.NewServer(httpServer.Config{})
+	h.RegisterRoutes(server)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/team", strings.NewReader(`{"name":"Slow"}`))
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	s.Require().Equal(http.StatusServiceUnavailable, w.Code)
+
+	var resp model.ErrorResponse
+	s.Decode(w, &resp)
+	s.Equal("unavailable", resp.Code)
+	s.Equal("Failed to create team", resp.Error)
+}
+
+// waitForCancel is a route that gives up once its request is canceled,
+// like the usecases do. It closes entered when called.
+func waitForCancel(entered chan struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-r.Context().Done()
+		httpServer.SendDomainError(w, fmt.Errorf("failed to get team: %w", r.Context().Err()), "Failed to get team")
+	}
+}
+
+// TestClientGoneIsNotAnError checks that requests canceled by their
+// client are logged as 499 at the info level, not as internal failures
+func (s *ErrorsTestSuite) TestClientGoneIsNotAnError() {
+	var logs bytes.Buffer
+	server := httpServer.NewServer(httpServer.Config{Logger: slog.New(slog.NewJSONHandler(&logs, nil))})
+	server.Handle(http.MethodGet, "/slow", waitForCancel(make(chan struct{})))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/slow", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	s.Require().Equal(httpServer.StatusClientClosedRequest, w.Code)
+
+	var resp model.ErrorResponse
+	s.Decode(w, &resp)
+	s.Equal(httpServer.CodeClientClosed, resp.Code)
+
+	var entry struct {
+		Level  string `json:"level"`
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+	}
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	s.Require().NoError(json.Unmarshal([]byte(lines[len(lines)-1]), &entry), "logs: %s", logs.String())
+	s.Equal("INFO", entry.Level)
+	s.Equal(httpServer.StatusClientClosedRequest, entry.Status)
+	s.Empty(entry.Error)
+}
+
+// TestShutdownAbortIsUnavailable checks that requests aborted because
+// shutting down took too long are answered 503, so that clients retry
+func (s *ErrorsTestSuite) TestShutdownAbortIsUnavailable() {
+	server := httpServer.NewServer(httpServer.Config{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	entered := make(chan struct{})
+	server.Handle(http.MethodGet, "/slow", waitForCancel(entered))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	go server.Serve(listener)
+
+	responses := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/api/slow")
+		s.NoError(err)
+		responses <- resp
+	}()
+
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		s.FailNow("request never arrived")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s.Error(server.Shutdown(ctx))
+
+	select {
+	case resp := <-responses:
+		s.Require().NotNil(resp)
+		defer resp.Body.Close()
+		s.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	case <-time.After(5 * time.Second):
+		s.FailNow("request was not aborted")
+	}
+}
@@ -0,0 +1,343 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase/eventbus"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+// waitTimeout bounds how long a test waits for a streamed event
+const waitTimeout = 5 * time.Second
+
+type EventsTestSuite struct {
+	env.BaseSuite
+	server *httptest.Server
+}
+
+func TestEventsSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &EventsTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+func (s *EventsTestSuite) SetupTest() {
+	// Streams need a real connection
+	s.server = httptest.NewServer(s.Router)
+}
+
+func (s *EventsTestSuite) TearDownTest() {
+	s.server.CloseClientConnections()
+	s.server.Close()
+}
+
+// sseMessage is one parsed block of a text/event-stream
+type sseMessage struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// openSSE connects to the event stream of a team and returns its messages
+func (s *EventsTestSuite) openSSE(team model.CreateTeamResponse, lastEventID string) <-chan sseMessage {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.T().Cleanup(cancel)
+
+	target := s.server.URL + "/api/v1/teams/" + team.ID + "/events?access_token=" + team.ViewerToken
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	s.Require().NoError(err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	messages := make(chan sseMessage, 100)
+	go func() {
+		defer resp.Body.Close()
+		defer close(messages)
+
+		var msg sseMessage
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				messages <- msg
+				msg = sseMessage{}
+				continue
+			}
+
+			field, value, _ := strings.Cut(line, ": ")
+			switch field {
+			case "id":
+				msg.ID = value
+			case "event":
+				msg.Event = value
+			case "data":
+				msg.Data = value
+			}
+		}
+	}()
+
+	return messages
+}
+
+// next waits for the next SSE message carrying an event
+func (s *EventsTestSuite) next(messages <-chan sseMessage) (sseMessage, domain.Event) {
+	for {
+		select {
+		case msg, ok := <-messages:
+			s.Require().True(ok, "stream ended")
+			if msg.Data == "" {
+				continue // cursor or keep-alive
+			}
+
+			var event domain.Event
+			s.Require().NoError(json.Unmarshal([]byte(msg.Data), &event))
+			return msg, event
+
+		case <-time.After(waitTimeout):
+			s.FailNow("no event received")
+		}
+	}
+}
+
+// cursor reads the first message, which only sets the last event ID
+func (s *EventsTestSuite) cursor(messages <-chan sseMessage) string {
+	select {
+	case msg := <-messages:
+		s.Require().Empty(msg.Data)
+		s.Require().NotEmpty(msg.ID)
+		return msg.ID
+	case <-time.After(waitTimeout):
+		s.FailNow("no cursor received")
+		return ""
+	}
+}
+
+func (s *EventsTestSuite) addUser(team model.CreateTeamResponse, user domain.User) {
+	w := s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{TeamID: team.ID, User: user}, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+}
+
+func (s *EventsTestSuite) removeUser(team model.CreateTeamResponse, userID string) {
+	w := s.Do(http.MethodDelete, "/api/team/user?team_id="+team.ID+"&user_id="+userID, nil, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+}
+
+func (s *EventsTestSuite) TestSSEStreamsChanges() {
+	team := s.CreateTeam("Live Team")
+	messages := s.openSSE(team, "")
+	s.cursor(messages)
+
+	s.addUser(team, domain.User{ID: "user1", FirstName: "John"})
+	msg, event := s.next(messages)
+	s.Equal("member_added", msg.Event)
+	s.Equal(strconv.FormatInt(event.ID, 10), msg.ID)
+	s.Equal(team.ID, event.TeamID)
+	s.Equal(int64(2), event.Version)
+	s.Require().NotNil(event.User)
+	s.Equal("John", event.User.FirstName)
+
+	s.addUser(team, domain.User{ID: "user1", FirstName: "Johnny", Version: 1})
+	_, event = s.next(messages)
+	s.Equal(domain.EventMemberUpdated, event.Type)
+	s.Equal("Johnny", event.User.FirstName)
+
+	name := "Renamed Team"
+	w := s.Do(http.MethodPatch, "/api/v1/teams/"+team.ID, model.UpdateTeamRequest{Name: &name}, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+	_, event = s.next(messages)
+	s.Equal(domain.EventTeamRenamed, event.Type)
+	s.Equal(name, event.Name)
+
+	s.removeUser(team, "user1")
+	_, event = s.next(messages)
+	s.Equal(domain.EventMemberRemoved, event.Type)
+	s.Equal("user1", event.UserID)
+	s.Nil(event.User)
+
+	w = s.Do(http.MethodPost, "/api/v1/teams/"+team.ID+"/members/user1/restore", nil, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+	_, event = s.next(messages)
+	s.Equal(domain.EventMemberAdded, event.Type)
+	s.Equal("user1", event.UserID)
+}
+
+func (s *EventsTestSuite) TestSSEResumesFromLastEventID() {
+	team := s.CreateTeam("Resume Team")
+	s.addUser(team, domain.User{ID: "user1", FirstName: "John"})
+
+	// A new client only gets changes from now on
+	first := s.openSSE(team, "")
+	s.cursor(first)
+	s.addUser(team, domain.User{ID: "user2", FirstName: "Jane"})
+	msg, event := s.next(first)
+	s.Equal("user2", event.UserID)
+	lastSeen := msg.ID
+
+	// Changes while disconnected, including a rejected one
+	s.addUser(team, domain.User{ID: "user3", FirstName: "Jim"})
+	w := s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{
+		TeamID: team.ID,
+		User:   domain.User{ID: "user3", FirstName: "Stale", Version: 7},
+	}, team.AdminToken)
+	s.Require().Equal(http.StatusPreconditionFailed, w.Code)
+	s.removeUser(team, "user1")
+
+	// Reconnecting replays the missed events in order, then streams live
+	second := s.openSSE(team, lastSeen)
+	s.cursor(second)
+
+	_, event = s.next(second)
+	s.Equal(domain.EventMemberAdded, event.Type)
+	s.Equal("user3", event.UserID)
+	s.Equal("Jim", event.User.FirstName)
+
+	_, event = s.next(second)
+	s.Equal(domain.EventMemberRemoved, event.Type)
+	s.Equal("user1", event.UserID)
+
+	s.addUser(team, domain.User{ID: "user4", FirstName: "Joe"})
+	_, event = s.next(second)
+	s.Equal("user4", event.UserID)
+}
+
+func (s *EventsTestSuite) TestEventsAreScopedToTheTeam() {
+	team := s.CreateTeam("Watched Team")
+	other := s.CreateTeam("Other Team")
+
+	messages := s.openSSE(team, "")
+	s.cursor(messages)
+
+	s.addUser(other, domain.User{ID: "user1", FirstName: "John"})
+	s.addUser(team, domain.User{ID: "user2", FirstName: "Jane"})
+
+	_, event := s.next(messages)
+	s.Equal(team.ID, event.TeamID)
+	s.Equal("user2", event.UserID)
+}
+
+func (s *EventsTestSuite) TestStreamRequiresAccess() {
+	team := s.CreateTeam("Private Team")
+	other := s.CreateTeam("Other Team")
+
+	// No token at all
+	w := s.Do(http.MethodGet, "/api/v1/teams/"+team.ID+"/events", nil, "")
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	// A token of another team
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+team.ID+"/events?access_token="+other.ViewerToken, nil, "")
+	s.Equal(http.StatusForbidden, w.Code)
+
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+team.ID+"/ws", nil, "")
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	// Invalid resume position
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+team.ID+"/events?last_event_id=abc", nil, team.ViewerToken)
+	s.Equal(http.StatusBadRequest, w.Code)
+
+	var errResp model.ErrorResponse
+	s.Decode(w, &errResp)
+	s.Equal("invalid_last_event_id", errResp.Code)
+}
+
+func (s *EventsTestSuite) TestWebSocketStreamsChanges() {
+	team := s.CreateTeam("Socket Team")
+	s.addUser(team, domain.User{ID: "user1", FirstName: "John"})
+
+	// A new connection starts with the next change
+	target := "ws" + strings.TrimPrefix(s.server.URL, "http") +
+		"/api/v1/teams/" + team.ID + "/ws?access_token=" + team.ViewerToken + "&last_event_id="
+
+	conn, resp, err := websocket.DefaultDialer.Dial(target, nil)
+	s.Require().NoError(err)
+	defer conn.Close()
+	s.Equal(http.StatusSwitchingProtocols, resp.StatusCode)
+	conn.SetReadDeadline(time.Now().Add(waitTimeout))
+
+	var ready model.TeamSocketReady
+	s.Require().NoError(conn.ReadJSON(&ready))
+	s.Equal("ready", ready.Type)
+
+	name := "Renamed"
+	w := s.Do(http.MethodPatch, "/api/v1/teams/"+team.ID, model.UpdateTeamRequest{Name: &name}, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	var event domain.Event
+	s.Require().NoError(conn.ReadJSON(&event))
+	s.Equal(domain.EventTeamRenamed, event.Type)
+	s.Equal(name, event.Name)
+	s.Greater(event.ID, ready.LastEventID)
+
+	// Resume after the rename
+	resumed, _, err := websocket.DefaultDialer.Dial(target+strconv.FormatInt(event.ID, 10), nil)
+	s.Require().NoError(err)
+	defer resumed.Close()
+	resumed.SetReadDeadline(time.Now().Add(waitTimeout))
+
+	s.Require().NoError(resumed.ReadJSON(&ready))
+	s.Equal(event.ID, ready.LastEventID)
+
+	s.removeUser(team, "user1")
+	for _, c := range []*websocket.Conn{conn, resumed} {
+		var removed domain.Event
+		s.Require().NoError(c.ReadJSON(&removed))
+		s.Equal(domain.EventMemberRemoved, removed.Type)
+		s.Equal("user1", removed.UserID)
+	}
+}
+
+func (s *EventsTestSuite) TestSlowWatcherCatchesUpFromLog() {
+	team := s.CreateTeam("Busy Team")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watch, err := s.Usecase.WatchTeam(ctx, team.ID, 0)
+	s.Require().NoError(err)
+
+	// More changes than the bus buffers while nobody reads
+	changes := eventbus.DefaultBuffer + 10
+	for i := 0; i < changes; i++ {
+		s.addUser(team, domain.User{ID: "user" + strconv.Itoa(i), FirstName: "John"})
+	}
+
+	lastID := watch.Cursor
+	for i := 0; i < changes; i++ {
+		select {
+		case event := <-watch.Events:
+			s.Greater(event.ID, lastID)
+			s.Equal("user"+strconv.Itoa(i), event.UserID)
+			lastID = event.ID
+		case <-time.After(waitTimeout):
+			s.FailNow("missing event", "after %d of %d", i, changes)
+		}
+	}
+
+	// The watch ends with its context
+	cancel()
+	select {
+	case _, ok := <-watch.Events:
+		s.False(ok)
+	case <-time.After(waitTimeout):
+		s.FailNow("watch did not end")
+	}
+}
@@ -0,0 +1,214 @@
+package invite
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+type InviteTestSuite struct {
+	env.BaseSuite
+}
+
+func TestInviteSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &InviteTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+func (s *InviteTestSuite) createInvite(team model.CreateTeamResponse, req model.CreateInviteRequest) domain.Invite {
+	w := s.Do(http.MethodPost, "/api/v1/teams/"+team.ID+"/invites", req, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var resp model.CreateInviteResponse
+	s.Decode(w, &resp)
+	return resp.Invite
+}
+
+func (s *InviteTestSuite) join(code, userID string) (int, model.JoinByInviteResponse) {
+	w := s.Do(http.MethodPost, fmt.Sprintf("/api/v1/invites/%s/join", code), model.JoinByInviteRequest{
+		User: domain.User{ID: userID, FirstName: "Joiner"},
+	}, "")
+
+	var resp model.JoinByInviteResponse
+	if w.Code == http.StatusOK {
+		s.Decode(w, &resp)
+	}
+	return w.Code, resp
+}
+
+func (s *InviteTestSuite) TestJoinWithViewerInvite() {
+	team := s.CreateTeam("Invite Team")
+	invite := s.createInvite(team, model.CreateInviteRequest{})
+
+	s.Regexp(`^[A-Z2-9]{4}-[A-Z2-9]{4}$`, invite.Code)
+	s.Equal(domain.RoleViewer, invite.Role, "viewer is the default role")
+
+	// Codes are accepted in lower case and without the dash
+	typed := strings.ToLower(strings.ReplaceAll(invite.Code, "-", ""))
+	code, resp := s.join(typed, "joiner1")
+	s.Require().Equal(http.StatusOK, code)
+	s.Equal(team.ID, resp.TeamID)
+	s.Equal("viewer", resp.Role)
+	s.NotEmpty(resp.Token)
+
+	// The new token reads the team and sees the new member
+	w := s.Do(http.MethodGet, "/api/team?team_id="+team.ID, nil, resp.Token)
+	s.Require().Equal(http.StatusOK, w.Code)
+	var teamResp model.GetTeamResponse
+	s.Decode(w, &teamResp)
+	s.Require().Len(teamResp.Team.Users, 1)
+	s.Equal("joiner1", teamResp.Team.Users[0].ID)
+
+	// But cannot write
+	w = s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{
+		TeamID: team.ID,
+		User:   domain.User{ID: "x", FirstName: "X"},
+	}, resp.Token)
+	s.Equal(http.StatusForbidden, w.Code)
+}
+
+func (s *InviteTestSuite) TestAdminInviteGrantsAdmin() {
+	team := s.CreateTeam("Admin Invite Team")
+	invite := s.createInvite(team, model.CreateInviteRequest{Role: "admin"})
+
+	code, resp := s.join(invite.Code, "coadmin")
+	s.Require().Equal(http.StatusOK, code)
+	s.Equal("admin", resp.Role)
+
+	w := s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{
+		TeamID: team.ID,
+		User:   domain.User{ID: "friend", FirstName: "Friend"},
+	}, resp.Token)
+	s.Equal(http.StatusOK, w.Code)
+}
+
+func (s *InviteTestSuite) TestMaxUses() {
+	team := s.CreateTeam("Limited Team")
+	invite := s.createInvite(team, model.CreateInviteRequest{MaxUses: 1})
+
+	code, _ := s.join(invite.Code, "first")
+	s.Equal(http.StatusOK, code)
+
+	code, _ = s.join(invite.Code, "second")
+	s.Equal(http.StatusGone, code)
+}
+
+func (s *InviteTestSuite) TestJoinDoesNotOverwriteMembers() {
+	team := s.CreateTeam("Joined Team")
+	w := s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{
+		TeamID: team.ID,
+		User:   domain.User{ID: "member", FirstName: "Original", Country: "NL"},
+	}, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+	invite := s.createInvite(team, model.CreateInviteRequest{MaxUses: 1})
+
+	code, _ := s.join(invite.Code, "member")
+	s.Equal(http.StatusConflict, code)
+
+	member, err := s.Usecase.GetUser(context.Background(), team.ID, "member")
+	s.Require().NoError(err)
+	s.Equal("Original", member.User.FirstName)
+	s.Equal("NL", member.User.Country)
+
+	// The failed join did not use up the invite
+	code, _ = s.join(invite.Code, "newcomer")
+	s.Equal(http.StatusOK, code)
+}
+
+func (s *InviteTestSuite) TestExpiredInvite() {
+	team := s.CreateTeam("Expired Team")
+
+	expiredAt := time.Now().Add(-time.Minute)
+	err := s.Repo.CreateInvite(context.Background(), &repository.Invite{
+		Code:      "EXPD-2345",
+		TeamID:    team.ID,
+		Role:      "viewer",
+		ExpiresAt: &expiredAt,
+		CreatedAt: time.Now().Add(-time.Hour),
+	})
+	s.Require().NoError(err)
+
+	code, _ := s.join("EXPD-2345", "late")
+	s.Equal(http.StatusGone, code)
+
+	invite := s.createInvite(team, model.CreateInviteRequest{ExpiresInSeconds: 3600})
+	s.Require().NotNil(invite.ExpiresAt)
+	s.WithinDuration(time.Now().Add(time.Hour), *invite.ExpiresAt, time.Minute)
+
+	code, _ = s.join(invite.Code, "on_time")
+	s.Equal(http.StatusOK, code)
+}
+
+func (s *InviteTestSuite) TestListAndRevoke() {
+	team := s.CreateTeam("Revoke Team")
+	first := s.createInvite(team, model.CreateInviteRequest{})
+	second := s.createInvite(team, model.CreateInviteRequest{MaxUses: 3})
+
+	w := s.Do(http.MethodGet, "/api/v1/teams/"+team.ID+"/invites", nil, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+	var list model.ListInvitesResponse
+	s.Decode(w, &list)
+	s.Len(list.Invites, 2)
+
+	w = s.Do(http.MethodDelete, fmt.Sprintf("/api/v1/teams/%s/invites/%s", team.ID, first.Code), nil, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	code, _ := s.join(first.Code, "too_late")
+	s.Equal(http.StatusNotFound, code)
+
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+team.ID+"/invites", nil, team.AdminToken)
+	s.Decode(w, &list)
+	s.Require().Len(list.Invites, 1)
+	s.Equal(second.Code, list.Invites[0].Code)
+
+	// Revoking twice, or a code of another team, reports not found
+	w = s.Do(http.MethodDelete, fmt.Sprintf("/api/v1/teams/%s/invites/%s", team.ID, first.Code), nil, team.AdminToken)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	other := s.CreateTeam("Other Team")
+	w = s.Do(http.MethodDelete, fmt.Sprintf("/api/v1/teams/%s/invites/%s", other.ID, second.Code), nil, other.AdminToken)
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
+func (s *InviteTestSuite) TestViewerCannotManageInvites() {
+	team := s.CreateTeam("Viewer Team")
+
+	w := s.Do(http.MethodPost, "/api/v1/teams/"+team.ID+"/invites", model.CreateInviteRequest{}, team.ViewerToken)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+team.ID+"/invites", nil, team.ViewerToken)
+	s.Equal(http.StatusForbidden, w.Code)
+}
+
+func (s *InviteTestSuite) TestUnknownCode() {
+	code, _ := s.join("NOPE-NOPE", "nobody")
+	s.Equal(http.StatusNotFound, code)
+}
+
+func (s *InviteTestSuite) TestListInvitesOfDeletedTeam() {
+	ctx := context.Background()
+	team := s.CreateTeam("Deleted Team")
+	s.createInvite(team, model.CreateInviteRequest{})
+
+	s.Require().NoError(s.Usecase.DeleteTeam(ctx, team.ID, 0))
+
+	// The admin secret keeps working, but the team is gone
+	w := s.Do(http.MethodGet, "/api/v1/teams/"+team.ID+"/invites", nil, team.AdminToken)
+	s.Equal(http.StatusNotFound, w.Code, "body: %s", w.Body.String())
+
+	_, err := s.Usecase.ListInvites(ctx, "no_such_team")
+	s.ErrorIs(err, usecase.ErrTeamNotFound)
+}
@@ -0,0 +1,208 @@
+package lifecycle
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/handlers"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+// waitTimeout bounds how long a test waits for the server
+const waitTimeout = 5 * time.Second
+
+// shortTimeout is the read and write timeout of the servers under test
+const shortTimeout = 200 * time.Millisecond
+
+// LifecycleTestSuite runs real servers with short timeouts and shuts
+// them down while streams are open
+type LifecycleTestSuite struct {
+	env.BaseSuite
+	handlers *handlers.Handlers
+	server   *httpServer.Server
+	router   http.Handler
+	url      string
+	served   chan error
+}
+
+func TestLifecycleSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &LifecycleTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+func (s *LifecycleTestSuite) SetupTest() {
+	// Handlers and server of their own, every test shuts them down
+	s.handlers = handlers.NewHandlers(s.Usecase)
+	s.server = httpServer.NewServer(httpServer.Config{
+		ReadTimeout:  shortTimeout,
+		WriteTimeout: shortTimeout,
+	})
+	s.handlers.RegisterRoutes(s.server)
+	s.router = s.server.Handler()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	s.url = "http://" + listener.Addr().String()
+
+	server, served := s.server, make(chan error, 1)
+	s.served = served
+	go func() {
+		served <- server.Serve(listener)
+	}()
+}
+
+func (s *LifecycleTestSuite) TearDownTest() {
+	s.shutdown()
+}
+
+// shutdown stops the streams and the server in the order main does
+func (s *LifecycleTestSuite) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+
+	s.Require().NoError(s.handlers.Shutdown(ctx))
+	s.Require().NoError(s.server.Shutdown(ctx))
+}
+
+// openSSE connects to the event stream of a team and returns its lines
+func (s *LifecycleTestSuite) openSSE(team model.CreateTeamResponse) <-chan string {
+	target := s.url + "/api/v1/teams/" + team.ID + "/events?access_token=" + team.ViewerToken
+	resp, err := http.Get(target)
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
+
+// openSocket connects to the WebSocket of a team and reads the ready message
+func (s *LifecycleTestSuite) openSocket(team model.CreateTeamResponse) *websocket.Conn {
+	target := "ws" + strings.TrimPrefix(s.url, "http") + "/api/v1/teams/" + team.ID + "/ws?access_token=" + team.ViewerToken
+	conn, _, err := websocket.DefaultDialer.Dial(target, nil)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { conn.Close() })
+
+	var ready model.TeamSocketReady
+	s.Require().NoError(conn.ReadJSON(&ready))
+	s.Equal("ready", ready.Type)
+	return conn
+}
+
+// addMember adds a member through the API, publishing an event
+func (s *LifecycleTestSuite) addMember(team model.CreateTeamResponse, id string) {
+	w := s.Do(http.MethodPut, "/api/v1/teams/"+team.ID+"/members/"+id,
+		domain.User{FirstName: "John", Initials: "JD"}, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+}
+
+// waitFor reads lines until one starts with prefix
+func (s *LifecycleTestSuite) waitFor(lines <-chan string, prefix string) {
+	deadline := time.After(waitTimeout)
+	for {
+		select {
+		case line, ok := <-lines:
+			s.Require().True(ok, "stream ended before %q", prefix)
+			if strings.HasPrefix(line, prefix) {
+				return
+			}
+		case <-deadline:
+			s.FailNow("timed out waiting for " + prefix)
+		}
+	}
+}
+
+func (s *LifecycleTestSuite) TestStreamsOutliveTimeouts() {
+	team := s.CreateTeam("Lifecycle Team")
+	lines := s.openSSE(team)
+	conn := s.openSocket(team)
+
+	time.Sleep(3 * shortTimeout)
+	s.addMember(team, "user1")
+
+	s.waitFor(lines, "event: "+string(domain.EventMemberAdded))
+
+	var event domain.Event
+	conn.SetReadDeadline(time.Now().Add(waitTimeout))
+	s.Require().NoError(conn.ReadJSON(&event))
+	s.Equal(domain.EventMemberAdded, event.Type)
+}
+
+func (s *LifecycleTestSuite) TestShutdownEndsStreams() {
+	team := s.CreateTeam("Lifecycle Team")
+	lines := s.openSSE(team)
+	conn := s.openSocket(team)
+
+	s.shutdown()
+
+	// The event stream ends
+	select {
+	case _, ok := <-lines:
+		for ok {
+			_, ok = <-lines
+		}
+	case <-time.After(waitTimeout):
+		s.FailNow("event stream still open")
+	}
+
+	// The socket is closed as going away
+	conn.SetReadDeadline(time.Now().Add(waitTimeout))
+	_, _, err := conn.ReadMessage()
+	s.True(websocket.IsCloseError(err, websocket.CloseGoingAway), "err: %v", err)
+
+	// Serving ended without an error and nothing is accepted anymore
+	select {
+	case err := <-s.served:
+		s.NoError(err)
+	case <-time.After(waitTimeout):
+		s.FailNow("server still serving")
+	}
+	_, err = http.Get(s.url + "/health")
+	s.Error(err)
+}
+
+func (s *LifecycleTestSuite) TestShutdownRefusesNewStreams() {
+	team := s.CreateTeam("Lifecycle Team")
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	s.Require().NoError(s.handlers.Shutdown(ctx))
+
+	// Straight to the handlers, the server still accepts requests
+	for _, path := range []string{"/events", "/ws"} {
+		r, err := http.NewRequest(http.MethodGet, "/api/v1/teams/"+team.ID+path, nil)
+		s.Require().NoError(err)
+		r.Header.Set("Authorization", "Bearer "+team.ViewerToken)
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, r)
+		s.Equal(http.StatusServiceUnavailable, w.Code, path)
+	}
+
+	// Other requests are still served
+	resp, err := http.Get(s.url + "/health")
+	s.Require().NoError(err)
+	resp.Body.Close()
+	s.Equal(http.StatusOK, resp.StatusCode)
+}
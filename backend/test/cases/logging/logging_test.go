@@ -0,0 +1,199 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/handlers"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/logging"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+// generatedID is the format of request IDs the server assigns
+var generatedID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// LoggingTestSuite serves requests through a server logging JSON to a
+// buffer at the debug level
+type LoggingTestSuite struct {
+	env.BaseSuite
+	logs   *syncBuffer
+	router http.Handler
+}
+
+func TestLoggingSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &LoggingTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+// syncBuffer is a buffer safe for the concurrent writes of streams
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (s *LoggingTestSuite) SetupTest() {
+	s.logs = &syncBuffer{}
+	logger, err := logging.New(s.logs, logging.Config{Level: "debug", Format: logging.FormatJSON})
+	s.Require().NoError(err)
+
+	server := httpServer.NewServer(httpServer.Config{Logger: logger})
+	handlers.NewHandlers(s.Usecase).RegisterRoutes(server)
+	s.router = server.Handler()
+}
+
+// do sends a request through the logging server
+func (s *LoggingTestSuite) do(method, target string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+	return w
+}
+
+// records returns the logged records with the given message
+func (s *LoggingTestSuite) records(msg string) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(s.logs.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		s.Require().NoError(json.Unmarshal([]byte(line), &record), "line: %s", line)
+		if record["msg"] == msg {
+			result = append(result, record)
+		}
+	}
+	return result
+}
+
+func (s *LoggingTestSuite) TestRequestIsLogged() {
+	team := s.CreateTeam("Logging Team")
+
+	header := http.Header{"Authorization": {"Bearer " + team.ViewerToken}}
+	w := s.do(http.MethodGet, "/api/v1/teams/"+team.ID+"?access_token=secret", header)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	requests := s.records("request")
+	s.Require().Len(requests, 1)
+	record := requests[0]
+
+	s.Equal("INFO", record["level"])
+	s.Equal(http.MethodGet, record["method"])
+	s.Equal("/api/v1/teams/"+team.ID, record["path"])
+	s.Equal("/api/v1/teams/{teamId}", record["route"])
+	s.EqualValues(http.StatusOK, record["status"])
+	s.EqualValues(w.Body.Len(), record["bytes"])
+	s.Contains(record, "duration_ms")
+	s.Equal(w.Header().Get(httpServer.RequestIDHeader), record["request_id"])
+
+	// Neither query strings nor tokens are logged
+	s.NotContains(s.logs.String(), "secret")
+	s.NotContains(s.logs.String(), team.ViewerToken)
+}
+
+func (s *LoggingTestSuite) TestFailuresAreWarnings() {
+	w := s.do(http.MethodGet, "/api/v1/teams/team_missing", nil)
+	s.Require().Equal(http.StatusUnauthorized, w.Code)
+
+	requests := s.records("request")
+	s.Require().Len(requests, 1)
+	s.Equal("WARN", requests[0]["level"])
+	s.EqualValues(http.StatusUnauthorized, requests[0]["status"])
+}
+
+func (s *LoggingTestSuite) TestUnknownPathIsLogged() {
+	s.do(http.MethodGet, "/api/nowhere", nil)
+
+	// The frontend serves everything the API does not
+	requests := s.records("request")
+	s.Require().Len(requests, 1)
+	s.Equal("/", requests[0]["route"])
+	s.Equal("/api/nowhere", requests[0]["path"])
+	s.EqualValues(http.StatusNotFound, requests[0]["status"])
+}
+
+func (s *LoggingTestSuite) TestRequestIDIsAcceptedAndEchoed() {
+	w := s.do(http.MethodGet, "/health", http.Header{httpServer.RequestIDHeader: {"req-42"}})
+
+	s.Equal("req-42", w.Header().Get(httpServer.RequestIDHeader))
+	s.Equal("req-42", s.records("request")[0]["request_id"])
+}
+
+func (s *LoggingTestSuite) TestRequestIDIsGenerated() {
+	for _, id := range []string{"", "not valid", strings.Repeat("a", 129), "evil\nline"} {
+		w := s.do(http.MethodGet, "/health", http.Header{httpServer.RequestIDHeader: {id}})
+		s.Regexp(generatedID, w.Header().Get(httpServer.RequestIDHeader), "sent %q", id)
+	}
+
+	a := s.do(http.MethodGet, "/health", nil).Header().Get(httpServer.RequestIDHeader)
+	b := s.do(http.MethodGet, "/health", nil).Header().Get(httpServer.RequestIDHeader)
+	s.NotEqual(a, b)
+}
+
+// TestLoggerIsPropagated checks that what handlers log for a request
+// carries its ID, as does its audit entry
+func (s *LoggingTestSuite) TestLoggerIsPropagated() {
+	team := s.CreateTeam("Logging Team")
+
+	header := http.Header{
+		"Authorization":            {"Bearer " + team.AdminToken},
+		httpServer.RequestIDHeader: {"req-propagated"},
+	}
+	w := s.do(http.MethodPost, "/api/v1/teams/"+team.ID+"/tokens/viewer", header)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	rotations := s.records("rotate token")
+	s.Require().Len(rotations, 1)
+	s.Equal("DEBUG", rotations[0]["level"])
+	s.Equal("req-propagated", rotations[0]["request_id"])
+	s.Equal(team.ID, rotations[0]["team_id"])
+
+	w = s.do(http.MethodGet, "/api/v1/teams/"+team.ID+"/audit",
+		http.Header{"Authorization": {"Bearer " + team.AdminToken}})
+	s.Require().Equal(http.StatusOK, w.Code)
+	var audit model.ListAuditResponse
+	s.Decode(w, &audit)
+	s.Require().NotEmpty(audit.Entries)
+	s.Equal("req-propagated", audit.Entries[0].Request.RequestID)
+}
+
+func (s *LoggingTestSuite) TestConfig() {
+	_, err := logging.New(&bytes.Buffer{}, logging.Config{})
+	s.NoError(err)
+	_, err = logging.New(&bytes.Buffer{}, logging.Config{Level: "WARN", Format: logging.FormatText})
+	s.NoError(err)
+
+	_, err = logging.New(&bytes.Buffer{}, logging.Config{Level: "loud"})
+	s.Error(err)
+	_, err = logging.New(&bytes.Buffer{}, logging.Config{Format: "xml"})
+	s.Error(err)
+}
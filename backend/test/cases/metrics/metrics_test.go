@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/handlers"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/db"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/metrics"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase/team"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+// MetricsTestSuite serves requests through a server wired with metrics
+// the way main does it
+type MetricsTestSuite struct {
+	env.BaseSuite
+	router http.Handler
+}
+
+func TestMetricsSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &MetricsTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+func (s *MetricsTestSuite) SetupTest() {
+	registry := metrics.NewRegistry()
+
+	repo := s.Repo
+	switch s.Driver {
+	case db.DriverSQLite:
+		s.DB.RegisterMetrics(registry)
+		repo = repository.NewSQLite(s.DB.DB, repository.WithQueryMetrics(registry))
+	case db.DriverPostgres:
+		s.DB.RegisterMetrics(registry)
+		repo = repository.NewPostgres(s.DB.DB, repository.WithQueryMetrics(registry))
+	}
+
+	server := httpServer.NewServer(httpServer.Config{Metrics: registry})
+	handlers.NewHandlers(team.NewUsecase(repo, team.WithMetrics(registry))).RegisterRoutes(server)
+	s.router = server.Handler()
+}
+
+// do sends a request through the server with metrics
+func (s *MetricsTestSuite) do(method, target string, body interface{}, token string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		s.Require().NoError(err)
+		reader = bytes.NewReader(data)
+	}
+
+	r := httptest.NewRequest(method, target, reader)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+	return w
+}
+
+// scrape returns the samples of /metrics by series, like
+// cupofteam_http_requests_total{method="GET",route="/health",status="200"}
+func (s *MetricsTestSuite) scrape() map[string]string {
+	w := s.do(http.MethodGet, "/metrics", nil, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Equal("text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+
+	samples := make(map[string]string)
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		s.Require().Positive(i, "line: %s", line)
+		samples[line[:i]] = line[i+1:]
+	}
+	return samples
+}
+
+func (s *MetricsTestSuite) TestRequestsAreMeasured() {
+	s.do(http.MethodGet, "/health", nil, "")
+	s.do(http.MethodGet, "/health", nil, "")
+	s.do(http.MethodGet, "/api/v1/teams/team_missing", nil, "")
+
+	samples := s.scrape()
+	s.Equal("2", samples[`cupofteam_http_requests_total{method="GET",route="/health",status="200"}`])
+	s.Equal("1", samples[`cupofteam_http_requests_total{method="GET",route="/api/v1/teams/{teamId}",status="401"}`])
+	s.Equal("2", samples[`cupofteam_http_request_duration_seconds_count{method="GET",route="/health"}`])
+	s.Equal("2", samples[`cupofteam_http_request_duration_seconds_bucket{method="GET",route="/health",le="+Inf"}`])
+	s.Contains(samples, `cupofteam_http_request_duration_seconds_sum{method="GET",route="/health"}`)
+}
+
+func (s *MetricsTestSuite) TestOperationsAreCounted() {
+	w := s.do(http.MethodPost, "/api/v1/teams", model.CreateTeamRequest{Name: "Metrics Team"}, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var created model.CreateTeamResponse
+	s.Decode(w, &created)
+	members := "/api/v1/teams/" + created.ID + "/members/"
+
+	for _, id := range []string{"user1", "user2"} {
+		w = s.do(http.MethodPut, members+id, domain.User{FirstName: "John", Initials: "JD"}, created.AdminToken)
+		s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	}
+	w = s.do(http.MethodDelete, members+"user2", nil, created.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	// Failed operations are not counted
+	w = s.do(http.MethodPut, members+"user3", domain.User{}, created.AdminToken)
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+
+	samples := s.scrape()
+	s.Equal("1", samples[`cupofteam_team_operations_total{action="team.created"}`])
+	s.Equal("2", samples[`cupofteam_team_operations_total{action="member.added"}`])
+	s.Equal("1", samples[`cupofteam_team_operations_total{action="member.removed"}`])
+}
+
+func (s *MetricsTestSuite) TestDatabaseIsMeasured() {
+	if s.Driver == db.DriverMemory || s.Driver == "" {
+		s.T().Skip("the in-memory storage runs no queries")
+	}
+
+	w := s.do(http.MethodPost, "/api/v1/teams", model.CreateTeamRequest{Name: "Metrics Team"}, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var created model.CreateTeamResponse
+	s.Decode(w, &created)
+	w = s.do(http.MethodGet, "/api/v1/teams/"+created.ID, nil, created.ViewerToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	samples := s.scrape()
+	s.NotEmpty(samples[`cupofteam_db_query_duration_seconds_count{statement="insert"}`])
+	s.NotEmpty(samples[`cupofteam_db_query_duration_seconds_count{statement="select"}`])
+	s.NotEmpty(samples[`cupofteam_db_open_connections`])
+	s.NotEmpty(samples[`cupofteam_db_in_use_connections`])
+	s.NotEmpty(samples[`cupofteam_db_wait_duration_seconds_total`])
+}
+
+// TestFormat checks the text format of each kind of metric
+func (s *MetricsTestSuite) TestFormat() {
+	registry := metrics.NewRegistry()
+	counter := registry.NewCounter("test_total", "A counter.", "label")
+	histogram := registry.NewHistogram("test_seconds", "A histogram.", []float64{1, 0.5})
+	registry.NewGaugeFunc("test_gauge", "A gauge.", func() float64 { return 2.5 })
+
+	counter.Inc(`quote " backslash \ newline` + "\n")
+	counter.Add(2, "b")
+	histogram.Observe(0.2)
+	histogram.Observe(0.7)
+	histogram.Observe(3)
+
+	var out bytes.Buffer
+	s.Require().NoError(registry.Write(&out))
+	s.Equal(`# HELP test_gauge A gauge.
+# TYPE test_gauge gauge
+test_gauge 2.5
+# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.5"} 1
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 3.9
+test_seconds_count 3
+# HELP test_total A counter.
+# TYPE test_total counter
+test_total{label="b"} 2
+test_total{label="quote \" backslash \\ newline\n"} 1
+`, out.String())
+
+	// Metrics are optional, nil ones ignore everything
+	var none *metrics.Counter
+	s.NotPanics(func() { none.Inc("any") })
+	s.Panics(func() { registry.NewCounter("test_total", "Again.") })
+}
@@ -0,0 +1,237 @@
+package migrations
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/db"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrationsTestSuite struct {
+	suite.Suite
+	sqlDB *sql.DB
+}
+
+func TestMigrationsSuite(t *testing.T) {
+	suite.Run(t, new(MigrationsTestSuite))
+}
+
+func (s *MigrationsTestSuite) SetupTest() {
+	database, err := sql.Open("sqlite3", filepath.Join(s.T().TempDir(), "migrations.db"))
+	s.Require().NoError(err)
+	s.sqlDB = database
+}
+
+func (s *MigrationsTestSuite) TearDownTest() {
+	s.sqlDB.Close()
+}
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_things.up.sql":     {Data: []byte(`CREATE TABLE things (id TEXT PRIMARY KEY);`)},
+		"0001_things.down.sql":   {Data: []byte(`DROP TABLE things;`)},
+		"0002_add_name.up.sql":   {Data: []byte(`ALTER TABLE things ADD COLUMN name TEXT;`)},
+		"0002_add_name.down.sql": {Data: []byte(`ALTER TABLE things DROP COLUMN name;`)},
+	}
+}
+
+func (s *MigrationsTestSuite) load(fsys fstest.MapFS) []db.Migration {
+	migrations, err := db.LoadMigrations(fsys)
+	s.Require().NoError(err)
+	return migrations
+}
+
+func (s *MigrationsTestSuite) TestEmbeddedMigrationsApply() {
+	migrations, err := db.Migrations(db.DriverSQLite)
+	s.Require().NoError(err)
+	s.Require().NotEmpty(migrations)
+
+	migrator := db.NewMigrator(s.sqlDB, migrations)
+	s.Require().NoError(migrator.Up())
+
+	status, err := migrator.Verify()
+	s.Require().NoError(err)
+	s.Equal(status.Latest, status.Version)
+	s.Empty(status.Pending)
+
+	// Running again is a no-op
+	s.Require().NoError(migrator.Up())
+
+	// And the whole history can be reverted
+	reverted, err := migrator.Down(len(migrations))
+	s.Require().NoError(err)
+	s.Equal(len(migrations), reverted)
+	status, err = migrator.Status()
+	s.Require().NoError(err)
+	s.Equal(0, status.Version)
+}
+
+func (s *MigrationsTestSuite) TestUpAndDown() {
+	migrator := db.NewMigrator(s.sqlDB, s.load(testMigrations()))
+
+	s.Require().NoError(migrator.Up())
+	_, err := s.sqlDB.Exec(`INSERT INTO things (id, name) VALUES ('a', 'A')`)
+	s.Require().NoError(err)
+
+	reverted, err := migrator.Down(1)
+	s.Require().NoError(err)
+	s.Equal(1, reverted)
+	status, err := migrator.Status()
+	s.Require().NoError(err)
+	s.Equal(1, status.Version)
+	s.Len(status.Pending, 1)
+
+	_, err = s.sqlDB.Exec(`INSERT INTO things (id, name) VALUES ('b', 'B')`)
+	s.Error(err, "name column should be gone after rollback")
+}
+
+func (s *MigrationsTestSuite) TestDownCountsRevertedMigrations() {
+	migrator := db.NewMigrator(s.sqlDB, s.load(testMigrations()))
+	s.Require().NoError(migrator.Up())
+
+	// Asking for more than applied reverts what there is
+	reverted, err := migrator.Down(5)
+	s.Require().NoError(err)
+	s.Equal(2, reverted)
+
+	reverted, err = migrator.Down(1)
+	s.Require().NoError(err)
+	s.Zero(reverted)
+}
+
+func (s *MigrationsTestSuite) TestStatusDoesNotWrite() {
+	migrator := db.NewMigrator(s.sqlDB, s.load(testMigrations()))
+
+	status, err := migrator.Verify()
+	s.Require().NoError(err)
+	s.Equal(0, status.Version)
+	s.Len(status.Pending, 2)
+
+	// Checking an empty database leaves it empty
+	var tables int
+	s.Require().NoError(s.sqlDB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'`).Scan(&tables))
+	s.Zero(tables)
+
+	s.Require().NoError(migrator.Up())
+	status, err = migrator.Status()
+	s.Require().NoError(err)
+	s.Equal(2, status.Version)
+}
+
+func (s *MigrationsTestSuite) TestChecksumMismatch() {
+	s.Require().NoError(db.NewMigrator(s.sqlDB, s.load(testMigrations())).Up())
+
+	edited := testMigrations()
+	edited["0002_add_name.up.sql"] = &fstest.MapFile{Data: []byte(`ALTER TABLE things ADD COLUMN title TEXT;`)}
+
+	err := db.NewMigrator(s.sqlDB, s.load(edited)).Up()
+	s.ErrorIs(err, db.ErrChecksumMismatch)
+}
+
+func (s *MigrationsTestSuite) TestSchemaNewerThanBinary() {
+	s.Require().NoError(db.NewMigrator(s.sqlDB, s.load(testMigrations())).Up())
+
+	older := testMigrations()
+	delete(older, "0002_add_name.up.sql")
+	delete(older, "0002_add_name.down.sql")
+
+	err := db.NewMigrator(s.sqlDB, s.load(older)).Up()
+	s.ErrorIs(err, db.ErrSchemaTooNew)
+}
+
+func (s *MigrationsTestSuite) TestFailedMigrationIsNotRecorded() {
+	broken := testMigrations()
+	broken["0002_add_name.up.sql"] = &fstest.MapFile{Data: []byte(`ALTER TABLE missing ADD COLUMN name TEXT;`)}
+
+	migrator := db.NewMigrator(s.sqlDB, s.load(broken))
+	s.Error(migrator.Up())
+
+	status, err := migrator.Verify()
+	s.Require().NoError(err)
+	s.Equal(1, status.Version)
+	s.False(status.Dirty)
+}
+
+func (s *MigrationsTestSuite) TestDirtySchemaRefusesToStart() {
+	migrator := db.NewMigrator(s.sqlDB, s.load(testMigrations()))
+	s.Require().NoError(migrator.Up())
+
+	_, err := s.sqlDB.Exec(`UPDATE schema_migrations SET dirty = TRUE WHERE version = 2`)
+	s.Require().NoError(err)
+
+	s.ErrorIs(migrator.Up(), db.ErrDirtySchema)
+
+	// Forcing the version clears the dirty flag
+	s.Require().NoError(migrator.Force(2))
+	s.NoError(migrator.Up())
+}
+
+func (s *MigrationsTestSuite) TestCheckModeRefusesPending() {
+	path := filepath.Join(s.T().TempDir(), "check.db")
+
+	_, err := db.New(db.Config{DSN: path, MigrationMode: db.MigrateCheck})
+	s.ErrorIs(err, db.ErrPendingMigrations)
+
+	database, err := db.New(db.Config{DSN: path, MigrationMode: db.MigrateUp})
+	s.Require().NoError(err)
+	database.Close()
+
+	database, err = db.New(db.Config{DSN: path, MigrationMode: db.MigrateCheck})
+	s.Require().NoError(err)
+	database.Close()
+}
+
+func (s *MigrationsTestSuite) TestOpenEnforcesForeignKeys() {
+	path := filepath.Join(s.T().TempDir(), "open.db")
+
+	database, err := db.Open(db.Config{DSN: path})
+	s.Require().NoError(err)
+	var enabled bool
+	s.Require().NoError(database.QueryRow(`PRAGMA foreign_keys`).Scan(&enabled))
+	s.True(enabled)
+	database.Close()
+
+	_, err = db.Open(db.Config{DSN: path + "?_foreign_keys=off"})
+	s.Error(err)
+}
+
+func (s *MigrationsTestSuite) TestInvalidMigrationSets() {
+	_, err := db.LoadMigrations(fstest.MapFS{
+		"0002_gap.up.sql": {Data: []byte(`SELECT 1;`)},
+	})
+	s.Error(err, "versions must start at 1")
+
+	_, err = db.LoadMigrations(fstest.MapFS{
+		"0001_only_down.down.sql": {Data: []byte(`SELECT 1;`)},
+	})
+	s.Error(err, "up script is required")
+
+	_, err = db.LoadMigrations(fstest.MapFS{
+		"first.up.sql": {Data: []byte(`SELECT 1;`)},
+	})
+	s.Error(err, "file names must be versioned")
+}
+
+func (s *MigrationsTestSuite) TestUsersMoveToTeamMembers() {
+	migrations, err := db.Migrations(db.DriverSQLite)
+	s.Require().NoError(err)
+
+	// A database created before team-scoped membership
+	s.Require().NoError(db.NewMigrator(s.sqlDB, migrations[:1]).Up())
+	_, err = s.sqlDB.Exec(`INSERT INTO teams (id, name) VALUES ('t1', 'Team')`)
+	s.Require().NoError(err)
+	_, err = s.sqlDB.Exec(`INSERT INTO users (id, team_id, first_name, initials, parent_names, grandparent_names, country)
+		VALUES ('u1', 't1', 'John', 'J', '[]', '[]', 'US')`)
+	s.Require().NoError(err)
+
+	s.Require().NoError(db.NewMigrator(s.sqlDB, migrations[:2]).Up())
+
+	var firstName string
+	err = s.sqlDB.QueryRow(`SELECT first_name FROM team_members WHERE team_id = 't1' AND user_id = 'u1'`).Scan(&firstName)
+	s.Require().NoError(err)
+	s.Equal("John", firstName)
+}
@@ -0,0 +1,384 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+// OpenAPITestSuite checks that GET /api/openapi.json describes the routes
+// the server actually serves: every route and no other, the tokens they
+// require and the shape of their responses
+type OpenAPITestSuite struct {
+	env.BaseSuite
+	spec spec
+}
+
+func TestOpenAPISuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &OpenAPITestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+// spec is the served document, decoded generically so the test sees what
+// clients see
+type spec struct {
+	OpenAPI    string                          `json:"openapi"`
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components struct {
+		Schemas map[string]schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string `json:"operationId"`
+	Deprecated  bool   `json:"deprecated"`
+	Role        string `json:"x-role"`
+	Parameters  []struct {
+		Name     string `json:"name"`
+		In       string `json:"in"`
+		Required bool   `json:"required"`
+	} `json:"parameters"`
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema schema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+type schema struct {
+	Ref                  string            `json:"$ref"`
+	Type                 string            `json:"type"`
+	Nullable             bool              `json:"nullable"`
+	Items                *schema           `json:"items"`
+	Properties           map[string]schema `json:"properties"`
+	Required             []string          `json:"required"`
+	AdditionalProperties *schema           `json:"additionalProperties"`
+}
+
+func (s *OpenAPITestSuite) SetupTest() {
+	w := s.Do(http.MethodGet, "/api/openapi.json", nil, "")
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	s.spec = spec{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &s.spec))
+}
+
+// operation returns the documented operation, failing the test without one
+func (s *OpenAPITestSuite) operation(key string) operation {
+	method, path, _ := strings.Cut(key, " ")
+	op, ok := s.spec.Paths[path][strings.ToLower(method)]
+	s.Require().True(ok, "%s is not documented", key)
+	return op
+}
+
+func (s *OpenAPITestSuite) TestDocument() {
+	s.Equal("3.0.3", s.spec.OpenAPI)
+
+	ids := make(map[string]string)
+	for path, item := range s.spec.Paths {
+		for method, op := range item {
+			key := strings.ToUpper(method) + " " + path
+			s.NotEmpty(op.OperationID, key)
+			if other, taken := ids[op.OperationID]; taken {
+				s.Failf("duplicate operationId", "%s and %s are both %s", key, other, op.OperationID)
+			}
+			ids[op.OperationID] = key
+
+			// Every path parameter is declared
+			for _, name := range pathParams(path) {
+				found := false
+				for _, p := range op.Parameters {
+					found = found || (p.In == "path" && p.Name == name && p.Required)
+				}
+				s.True(found, "%s does not declare path parameter %s", key, name)
+			}
+		}
+	}
+
+	// References resolve
+	body, err := json.Marshal(s.spec)
+	s.Require().NoError(err)
+	for _, ref := range strings.Split(string(body), `"$ref":"#/components/schemas/`)[1:] {
+		name := ref[:strings.Index(ref, `"`)]
+		s.Contains(s.spec.Components.Schemas, name)
+	}
+}
+
+// TestCoversRoutes compares the document with the routes of the router
+func (s *OpenAPITestSuite) TestCoversRoutes() {
+	var served []string
+	err := s.Server.Router().Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil // the frontend's prefix routes have no template
+		}
+		methods, err := route.GetMethods()
+		if err != nil || path == "/" {
+			return nil
+		}
+		for _, method := range methods {
+			served = append(served, method+" "+path)
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+
+	var documented []string
+	for path, item := range s.spec.Paths {
+		for method := range item {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(served)
+	sort.Strings(documented)
+	s.Equal(served, documented)
+
+	_, err = s.Handlers.OpenAPI()
+	s.NoError(err)
+}
+
+// TestAuthorizationMatchesSpec calls every operation without a token and
+// with a viewer token, expecting the roles the document states
+func (s *OpenAPITestSuite) TestAuthorizationMatchesSpec() {
+	t := s.CreateTeam("Contract Team")
+
+	for path, item := range s.spec.Paths {
+		for method, op := range item {
+			key := strings.ToUpper(method) + " " + path
+			target := s.fill(path, op, t.ID)
+
+			w := s.Do(strings.ToUpper(method), target, nil, "")
+			if op.Role != "" {
+				s.Equal(http.StatusUnauthorized, w.Code, "%s without a token", key)
+			} else {
+				s.NotEqual(http.StatusUnauthorized, w.Code, "%s without a token", key)
+			}
+			s.Equal(op.Deprecated, w.Header().Get("Deprecation") != "", "%s Deprecation header", key)
+
+			if op.Role == string(domain.RoleAdmin) {
+				w = s.Do(strings.ToUpper(method), target, nil, t.ViewerToken)
+				s.Equal(http.StatusForbidden, w.Code, "%s with a viewer token", key)
+			}
+		}
+	}
+}
+
+// fill substitutes path parameters and required query parameters
+func (s *OpenAPITestSuite) fill(path string, op operation, teamID string) string {
+	values := map[string]string{
+		"teamId": teamID, "team_id": teamID,
+		"userId": "user1", "user_id": "user1",
+		"webhookId": "wh_missing", "webhook_id": "wh_missing",
+		"code": "AAAA-AAAA", "role": "viewer",
+	}
+
+	for _, name := range pathParams(path) {
+		path = strings.ReplaceAll(path, "{"+name+"}", values[name])
+	}
+
+	var query []string
+	for _, p := range op.Parameters {
+		if p.In == "query" && p.Required {
+			query = append(query, p.Name+"="+values[p.Name])
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + strings.Join(query, "&")
+	}
+
+	return path
+}
+
+// TestResponsesMatchSchemas checks responses of real calls against the
+// schemas of their operations
+func (s *OpenAPITestSuite) TestResponsesMatchSchemas() {
+	w := s.Do(http.MethodPost, "/api/v1/teams", model.CreateTeamRequest{Name: "Contract Team"}, "")
+	s.conforms("POST /api/v1/teams", w)
+	var t model.CreateTeamResponse
+	s.Decode(w, &t)
+	team := "/api/v1/teams/" + t.ID
+
+	user := domain.User{
+		FirstName:         "John",
+		Initials:          "JD",
+		ParentNames:       []string{"Michael"},
+		GrandParentsNames: []string{"Robert"},
+		Country:           "US",
+	}
+	s.conforms("PUT /api/v1/teams/{teamId}/members/{userId}",
+		s.Do(http.MethodPut, team+"/members/user1", user, t.AdminToken))
+	s.conforms("PUT /api/v1/teams/{teamId}/members/{userId}",
+		s.Do(http.MethodPut, team+"/members/user2", domain.User{FirstName: "Jane", Initials: "JA"}, t.AdminToken))
+	s.conforms("GET /api/v1/teams/{teamId}/members/{userId}",
+		s.Do(http.MethodGet, team+"/members/user1", nil, t.ViewerToken))
+	s.conforms("GET /api/v1/teams/{teamId}",
+		s.Do(http.MethodGet, team, nil, t.ViewerToken))
+	s.conforms("PATCH /api/v1/teams/{teamId}",
+		s.Do(http.MethodPatch, team, model.UpdateTeamRequest{}, t.AdminToken))
+	s.conforms("DELETE /api/v1/teams/{teamId}/members/{userId}",
+		s.Do(http.MethodDelete, team+"/members/user2", nil, t.AdminToken))
+	s.conforms("GET /api/v1/teams/{teamId}/trash",
+		s.Do(http.MethodGet, team+"/trash", nil, t.ViewerToken))
+	s.conforms("POST /api/v1/teams/{teamId}/members/{userId}/restore",
+		s.Do(http.MethodPost, team+"/members/user2/restore", nil, t.AdminToken))
+
+	w = s.Do(http.MethodPost, team+"/invites", model.CreateInviteRequest{MaxUses: 2, ExpiresInSeconds: 3600}, t.AdminToken)
+	s.conforms("POST /api/v1/teams/{teamId}/invites", w)
+	var invite model.CreateInviteResponse
+	s.Decode(w, &invite)
+	s.conforms("GET /api/v1/teams/{teamId}/invites",
+		s.Do(http.MethodGet, team+"/invites", nil, t.AdminToken))
+	s.conforms("POST /api/v1/invites/{code}/join",
+		s.Do(http.MethodPost, "/api/v1/invites/"+invite.Invite.Code+"/join",
+			model.JoinByInviteRequest{User: domain.User{ID: "user3", FirstName: "Jim", Initials: "JI"}}, ""))
+	s.conforms("DELETE /api/v1/teams/{teamId}/invites/{code}",
+		s.Do(http.MethodDelete, team+"/invites/"+invite.Invite.Code, nil, t.AdminToken))
+
+	w = s.Do(http.MethodPost, team+"/webhooks", model.CreateWebhookRequest{URL: "https://example.com/hook"}, t.AdminToken)
+	s.conforms("POST /api/v1/teams/{teamId}/webhooks", w)
+	var hook model.CreateWebhookResponse
+	s.Decode(w, &hook)
+	s.conforms("GET /api/v1/teams/{teamId}/webhooks",
+		s.Do(http.MethodGet, team+"/webhooks", nil, t.AdminToken))
+	s.conforms("GET /api/v1/teams/{teamId}/webhooks/{webhookId}/deliveries",
+		s.Do(http.MethodGet, team+"/webhooks/"+hook.Webhook.ID+"/deliveries", nil, t.AdminToken))
+	s.conforms("DELETE /api/v1/teams/{teamId}/webhooks/{webhookId}",
+		s.Do(http.MethodDelete, team+"/webhooks/"+hook.Webhook.ID, nil, t.AdminToken))
+
+	s.conforms("GET /api/v1/teams/{teamId}/audit",
+		s.Do(http.MethodGet, team+"/audit?limit=5", nil, t.AdminToken))
+	s.conforms("POST /api/v1/teams/{teamId}/tokens/{role}",
+		s.Do(http.MethodPost, team+"/tokens/viewer", nil, t.AdminToken))
+	s.conforms("GET /api/team",
+		s.Do(http.MethodGet, "/api/team?team_id="+t.ID, nil, t.AdminToken))
+	s.conforms("DELETE /api/v1/teams/{teamId}",
+		s.Do(http.MethodDelete, team, nil, t.AdminToken))
+	s.conforms("POST /api/v1/teams/{teamId}/restore",
+		s.Do(http.MethodPost, team+"/restore", nil, t.AdminToken))
+	s.conforms("GET /health",
+		s.Do(http.MethodGet, "/health", nil, ""))
+
+	// Errors, validation errors included, have the documented shape
+	s.conforms("GET /api/v1/teams/{teamId}/members/{userId}",
+		s.Do(http.MethodGet, team+"/members/ghost", nil, t.ViewerToken))
+	s.conforms("PUT /api/v1/teams/{teamId}/members/{userId}",
+		s.Do(http.MethodPut, team+"/members/user4", domain.User{}, t.AdminToken))
+}
+
+// conforms validates a JSON response against the schema its operation
+// documents for the status, the default one for errors
+func (s *OpenAPITestSuite) conforms(key string, w *httptest.ResponseRecorder) {
+	op := s.operation(key)
+
+	response, ok := op.Responses[fmt.Sprint(w.Code)]
+	if !ok {
+		s.GreaterOrEqual(w.Code, 400, "%s answered with undocumented status %d", key, w.Code)
+		response = op.Responses["default"]
+	}
+
+	media, ok := response.Content["application/json"]
+	s.Require().True(ok, "%s documents no JSON for status %d", key, w.Code)
+
+	var body interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &body), "%s body: %s", key, w.Body.String())
+
+	for _, problem := range s.validate(media.Schema, body, "$") {
+		s.Failf("response does not match the schema", "%s (status %d): %s\nbody: %s", key, w.Code, problem, w.Body.String())
+	}
+}
+
+// validate lists where value does not match sch
+func (s *OpenAPITestSuite) validate(sch schema, value interface{}, at string) []string {
+	if sch.Ref != "" {
+		name := strings.TrimPrefix(sch.Ref, "#/components/schemas/")
+		resolved, ok := s.spec.Components.Schemas[name]
+		if !ok {
+			return []string{at + ": unknown schema " + sch.Ref}
+		}
+		return s.validate(resolved, value, at)
+	}
+
+	if value == nil {
+		if sch.Type == "" || sch.Nullable {
+			return nil
+		}
+		return []string{at + ": null is not nullable"}
+	}
+
+	var problems []string
+	switch sch.Type {
+	case "":
+		// any value
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object, got %T", at, value)}
+		}
+		for _, name := range sch.Required {
+			if _, ok := object[name]; !ok {
+				problems = append(problems, at+": missing required "+name)
+			}
+		}
+		for name, field := range object {
+			if property, ok := sch.Properties[name]; ok {
+				problems = append(problems, s.validate(property, field, at+"."+name)...)
+			} else if sch.AdditionalProperties != nil {
+				problems = append(problems, s.validate(*sch.AdditionalProperties, field, at+"."+name)...)
+			} else {
+				problems = append(problems, at+": undocumented property "+name)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an array, got %T", at, value)}
+		}
+		for i, item := range items {
+			problems = append(problems, s.validate(*sch.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected a string, got %T", at, value))
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			problems = append(problems, fmt.Sprintf("%s: expected an integer, got %v", at, value))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected a number, got %T", at, value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected a boolean, got %T", at, value))
+		}
+	default:
+		problems = append(problems, at+": unknown type "+sch.Type)
+	}
+
+	return problems
+}
+
+// pathParams lists the names of the path parameters of a path template
+func pathParams(path string) []string {
+	var names []string
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			names = append(names, part[1:len(part)-1])
+		}
+	}
+	return names
+}
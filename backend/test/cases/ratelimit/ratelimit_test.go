@@ -0,0 +1,203 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/handlers"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+type RateLimitTestSuite struct {
+	env.BaseSuite
+}
+
+func TestRateLimitSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &RateLimitTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+// limited returns a handler limited by config, which is enabled
+func (s *RateLimitTestSuite) limited(config httpServer.RateLimitConfig) http.Handler {
+	config.Enabled = true
+	s.Require().NoError(config.Validate())
+
+	server := httpServer.NewServer(httpServer.Config{RateLimit: config})
+	handlers.NewHandlers(s.Usecase).RegisterRoutes(server)
+	return server.Handler()
+}
+
+// get sends a GET from the client IP with the token
+func (s *RateLimitTestSuite) get(handler http.Handler, target, ip, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r.RemoteAddr = ip + ":5150"
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+// requireLimited checks a 429 response and returns its Retry-After
+func (s *RateLimitTestSuite) requireLimited(w *httptest.ResponseRecorder) int {
+	s.Require().Equal(http.StatusTooManyRequests, w.Code)
+
+	var resp model.ErrorResponse
+	s.Decode(w, &resp)
+	s.Equal(httpServer.CodeRateLimited, resp.Code)
+
+	retryAfter, err := strconv.Atoi(w.Header().Get(httpServer.RetryAfterHeader))
+	s.Require().NoError(err)
+	s.Positive(retryAfter)
+	return retryAfter
+}
+
+func (s *RateLimitTestSuite) TestPerClientIP() {
+	team := s.CreateTeam("Limited Team")
+	handler := s.limited(httpServer.RateLimitConfig{IPPerMinute: 1, IPBurst: 2})
+	target := "/api/v1/teams/" + team.ID
+
+	w := s.get(handler, target, "203.0.113.1", team.ViewerToken)
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("2", w.Header().Get(httpServer.RateLimitLimitHeader))
+	s.Equal("1", w.Header().Get(httpServer.RateLimitRemainingHeader))
+	s.Equal("60", w.Header().Get(httpServer.RateLimitResetHeader))
+
+	w = s.get(handler, target, "203.0.113.1", team.ViewerToken)
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("0", w.Header().Get(httpServer.RateLimitRemainingHeader))
+
+	w = s.get(handler, target, "203.0.113.1", team.ViewerToken)
+	s.InDelta(60, s.requireLimited(w), 1)
+	s.Equal("0", w.Header().Get(httpServer.RateLimitRemainingHeader))
+	s.NotContains(w.Body.String(), "Limited Team")
+
+	// Other clients and routes outside the API are not limited
+	w = s.get(handler, target, "203.0.113.2", team.ViewerToken)
+	s.Equal(http.StatusOK, w.Code)
+	w = s.get(handler, "/health", "203.0.113.1", "")
+	s.Equal(http.StatusOK, w.Code)
+	s.Empty(w.Header().Get(httpServer.RateLimitLimitHeader))
+}
+
+func (s *RateLimitTestSuite) TestIPv6ClientsByPrefix() {
+	handler := s.limited(httpServer.RateLimitConfig{IPPerMinute: 1, IPBurst: 1})
+
+	s.Equal(http.StatusOK, s.get(handler, "/api/openapi.json", "[2001:db8:1:2::1]", "").Code)
+	s.requireLimited(s.get(handler, "/api/openapi.json", "[2001:db8:1:2::ffff]", ""))
+	s.Equal(http.StatusOK, s.get(handler, "/api/openapi.json", "[2001:db8:1:3::1]", "").Code)
+}
+
+func (s *RateLimitTestSuite) TestPerTeam() {
+	team := s.CreateTeam("Busy Team")
+	other := s.CreateTeam("Quiet Team")
+	handler := s.limited(httpServer.RateLimitConfig{TeamPerMinute: 1, TeamBurst: 3})
+
+	// Requests without a token of the team don't count against it
+	s.Equal(http.StatusUnauthorized, s.get(handler, "/api/v1/teams/"+team.ID, "198.51.100.1", "").Code)
+	s.Equal(http.StatusUnauthorized, s.get(handler, "/api/team?team_id="+team.ID, "198.51.100.2", "wrong").Code)
+	s.Equal(http.StatusForbidden, s.get(handler, "/api/v1/teams/"+team.ID, "198.51.100.3", other.ViewerToken).Code)
+
+	// The team limit holds across clients, for both route styles
+	s.Equal(http.StatusOK, s.get(handler, "/api/v1/teams/"+team.ID, "203.0.113.1", team.ViewerToken).Code)
+	s.Equal(http.StatusOK, s.get(handler, "/api/v1/teams/"+team.ID, "203.0.113.2", team.ViewerToken).Code)
+	w := s.get(handler, "/api/team?team_id="+team.ID, "203.0.113.3", team.ViewerToken)
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("3", w.Header().Get(httpServer.RateLimitLimitHeader))
+	s.Equal("0", w.Header().Get(httpServer.RateLimitRemainingHeader))
+
+	s.requireLimited(s.get(handler, "/api/v1/teams/"+team.ID, "203.0.113.4", team.ViewerToken))
+
+	// The legacy routes taking the team ID in the body count too, as the
+	// team is the one of the token
+	body, err := json.Marshal(model.AddToTeamRequest{TeamID: team.ID, User: domain.User{ID: "user1", FirstName: "John"}})
+	s.Require().NoError(err)
+	r := httptest.NewRequest(http.MethodPost, "/api/team/user", bytes.NewReader(body))
+	r.RemoteAddr = "203.0.113.5:5150"
+	r.Header.Set("Authorization", "Bearer "+team.AdminToken)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	s.requireLimited(w)
+
+	s.Equal(http.StatusOK, s.get(handler, "/api/v1/teams/"+other.ID, "203.0.113.1", other.ViewerToken).Code)
+}
+
+func (s *RateLimitTestSuite) TestBackoffOnGuessing() {
+	team := s.CreateTeam("Guessed Team")
+	handler := s.limited(httpServer.RateLimitConfig{
+		FailuresPerHour: 1,
+		FailureBurst:    2,
+		Backoff:         100 * time.Millisecond,
+		MaxBackoff:      time.Second,
+	})
+	guess := func(n int) *httptest.ResponseRecorder {
+		return s.get(handler, "/api/v1/teams/no-such-team-"+strconv.Itoa(n), "203.0.113.1", team.ViewerToken)
+	}
+
+	// Successes do not count
+	s.Equal(http.StatusOK, s.get(handler, "/api/v1/teams/"+team.ID, "203.0.113.1", team.ViewerToken).Code)
+
+	// Free failures, of other teams and of wrong tokens
+	s.Equal(http.StatusForbidden, guess(1).Code)
+	s.Equal(http.StatusUnauthorized, s.get(handler, "/api/v1/teams/"+team.ID, "203.0.113.1", "wrong").Code)
+
+	// The next failure blocks the client, for all requests
+	s.Equal(http.StatusNotFound, s.get(handler, "/api/v1/teams/"+team.ID+"/members/nobody", "203.0.113.1", team.ViewerToken).Code)
+	s.requireLimited(guess(3))
+	s.requireLimited(s.get(handler, "/api/v1/teams/"+team.ID, "203.0.113.1", team.ViewerToken))
+	s.Equal(http.StatusOK, s.get(handler, "/api/v1/teams/"+team.ID, "203.0.113.2", team.ViewerToken).Code)
+
+	// Once unblocked, the next failure blocks twice as long
+	time.Sleep(150 * time.Millisecond)
+	s.Equal(http.StatusForbidden, guess(4).Code)
+	time.Sleep(150 * time.Millisecond)
+	s.requireLimited(guess(5))
+	time.Sleep(100 * time.Millisecond)
+	s.Equal(http.StatusOK, s.get(handler, "/api/v1/teams/"+team.ID, "203.0.113.1", team.ViewerToken).Code)
+}
+
+func (s *RateLimitTestSuite) TestTrustedProxy() {
+	handler := s.limited(httpServer.RateLimitConfig{IPPerMinute: 1, IPBurst: 1, TrustProxy: true})
+	forwarded := func(chain string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+		r.RemoteAddr = "10.0.0.1:5150"
+		r.Header.Set("X-Forwarded-For", chain)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// The entry added by the proxy counts, not those sent by the client
+	s.Equal(http.StatusOK, forwarded("198.51.100.1, 203.0.113.1"))
+	s.Equal(http.StatusTooManyRequests, forwarded("198.51.100.2, 203.0.113.1"))
+	s.Equal(http.StatusOK, forwarded("203.0.113.2"))
+}
+
+func (s *RateLimitTestSuite) TestDisabledByDefault() {
+	for i := 0; i < 30; i++ {
+		w := s.Do(http.MethodGet, "/api/v1/teams/no-such-team", nil, "")
+		s.NotEqual(http.StatusTooManyRequests, w.Code)
+		s.Empty(w.Header().Get(httpServer.RateLimitLimitHeader))
+	}
+}
+
+func (s *RateLimitTestSuite) TestInvalidConfig() {
+	s.Error(httpServer.RateLimitConfig{IPBurst: -1}.Validate())
+	s.Error(httpServer.RateLimitConfig{Backoff: -time.Second}.Validate())
+	s.Error(httpServer.RateLimitConfig{Backoff: time.Hour}.Validate())
+	s.NoError(httpServer.RateLimitConfig{}.Validate())
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/db"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+type RepositoryTestSuite struct {
+	env.BaseSuite
+}
+
+func TestRepositorySuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &RepositoryTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+func (s *RepositoryTestSuite) TestWithinTxCommits() {
+	ctx := context.Background()
+
+	err := s.Repo.WithinTx(ctx, func(ctx context.Context) error {
+		return s.Repo.CreateTeam(ctx, &repository.Team{ID: "tx_commit", Name: "Commit", CreatedAt: time.Now()})
+	})
+	s.Require().NoError(err)
+
+	team, err := s.Repo.GetTeam(ctx, "tx_commit")
+	s.Require().NoError(err)
+	s.Require().NotNil(team)
+	s.Equal("Commit", team.Name)
+}
+
+func (s *RepositoryTestSuite) TestWithinTxRollsBackOnError() {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	err := s.Repo.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.Repo.CreateTeam(ctx, &repository.Team{ID: "tx_rollback", Name: "Rollback", CreatedAt: time.Now()}); err != nil {
+			return err
+		}
+
+		// The write is visible inside the transaction
+		team, err := s.Repo.GetTeam(ctx, "tx_rollback")
+		s.Require().NoError(err)
+		s.Require().NotNil(team)
+
+		return errBoom
+	})
+	s.ErrorIs(err, errBoom)
+
+	team, err := s.Repo.GetTeam(ctx, "tx_rollback")
+	s.Require().NoError(err)
+	s.Nil(team, "team should be rolled back")
+}
+
+func (s *RepositoryTestSuite) TestNestedTxJoinsOuter() {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	err := s.Repo.WithinTx(ctx, func(ctx context.Context) error {
+		err := s.Repo.WithinTx(ctx, func(ctx context.Context) error {
+			return s.Repo.CreateTeam(ctx, &repository.Team{ID: "tx_nested", Name: "Nested", CreatedAt: time.Now()})
+		})
+		s.Require().NoError(err)
+		return errBoom
+	})
+	s.ErrorIs(err, errBoom)
+
+	team, err := s.Repo.GetTeam(ctx, "tx_nested")
+	s.Require().NoError(err)
+	s.Nil(team, "inner work should be rolled back with the outer transaction")
+}
+
+func (s *RepositoryTestSuite) TestCreateDuplicates() {
+	ctx := context.Background()
+
+	team := &repository.Team{ID: "dup_team", Name: "Dup", CreatedAt: time.Now()}
+	s.Require().NoError(s.Repo.CreateTeam(ctx, team))
+	s.ErrorIs(s.Repo.CreateTeam(ctx, team), repository.ErrAlreadyExists)
+
+	user := &repository.User{ID: "dup_user", TeamID: team.ID, FirstName: "Dup", CreatedAt: time.Now()}
+	s.Require().NoError(s.Repo.CreateUser(ctx, user))
+	s.ErrorIs(s.Repo.CreateUser(ctx, user), repository.ErrAlreadyExists)
+}
+
+// TestForeignKeysEnforced checks that members of unknown teams are rejected
+func (s *RepositoryTestSuite) TestForeignKeysEnforced() {
+	if s.DB == nil {
+		s.T().Skip("needs a SQL database")
+	}
+
+	_, err := s.DB.Exec(`INSERT INTO team_members (team_id, user_id, first_name) VALUES ('no_such_team', 'user1', 'John')`)
+	s.Error(err)
+}
+
+func (s *RepositoryTestSuite) TestUpdateAndDeleteMissingTeam() {
+	ctx := context.Background()
+
+	err := s.Repo.UpdateTeam(ctx, &repository.Team{ID: "missing_team", Name: "Missing"})
+	s.ErrorIs(err, repository.ErrNotFound)
+
+	err = s.Repo.DeleteTeam(ctx, "missing_team", time.Now())
+	s.ErrorIs(err, repository.ErrNotFound)
+}
+
+// TestCanceledContextAbortsQueries checks that work for a client that
+// has gone away is not carried out
+func (s *RepositoryTestSuite) TestCanceledContextAbortsQueries() {
+	if s.DB == nil {
+		s.T().Skip("needs a SQL database")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.Repo.CreateTeam(ctx, &repository.Team{ID: "canceled", Name: "Canceled", CreatedAt: time.Now()})
+	s.ErrorIs(err, context.Canceled)
+	_, err = s.Repo.GetTeamUsers(ctx, "canceled")
+	s.ErrorIs(err, context.Canceled)
+	err = s.Repo.WithinTx(ctx, func(ctx context.Context) error { return nil })
+	s.ErrorIs(err, context.Canceled)
+
+	team, err := s.Repo.GetTeam(context.Background(), "canceled")
+	s.Require().NoError(err)
+	s.Nil(team)
+}
+
+func (s *RepositoryTestSuite) TestQueryTimeout() {
+	if s.DB == nil {
+		s.T().Skip("needs a SQL database")
+	}
+	s.Equal(db.DefaultQueryTimeout, s.DB.QueryTimeout)
+
+	var repo repository.Repository
+	switch s.Driver {
+	case db.DriverSQLite:
+		repo = repository.NewSQLite(s.DB.DB, repository.WithQueryTimeout(time.Nanosecond))
+	case db.DriverPostgres:
+		repo = repository.NewPostgres(s.DB.DB, repository.WithQueryTimeout(time.Nanosecond))
+	}
+
+	_, err := repo.GetTeam(context.Background(), "any")
+	s.ErrorIs(err, context.DeadlineExceeded)
+	_, err = repo.GetTeamUsers(context.Background(), "any")
+	s.ErrorIs(err, context.DeadlineExceeded)
+	err = repo.CreateTeam(context.Background(), &repository.Team{ID: "timed_out", Name: "Timed Out", CreatedAt: time.Now()})
+	s.ErrorIs(err, context.DeadlineExceeded)
+
+	// Rows are read within the timeout of the suite's repository
+	ctx := context.Background()
+	s.Require().NoError(s.Repo.CreateTeam(ctx, &repository.Team{ID: "in_time", Name: "In Time", CreatedAt: time.Now()}))
+	for _, id := range []string{"user1", "user2"} {
+		s.Require().NoError(s.Repo.CreateUser(ctx, &repository.User{TeamID: "in_time", ID: id, FirstName: "John"}))
+	}
+	users, err := s.Repo.GetTeamUsers(ctx, "in_time")
+	s.Require().NoError(err)
+	s.Len(users, 2)
+}
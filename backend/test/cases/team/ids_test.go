@@ -0,0 +1,79 @@
+package team
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase/idgen"
+	teamUsecase "github.com/kvloginov/cup-of-team/backend/internal/usecase/team"
+)
+
+// TestRandomTeamIDs checks that default team IDs are long and unguessable
+func (s *TeamTestSuite) TestRandomTeamIDs() {
+	idPattern := regexp.MustCompile(`^team_[a-z2-7]{26}$`)
+
+	first := s.CreateTeam("Random A")
+	second := s.CreateTeam("Random B")
+
+	s.Regexp(idPattern, first.ID)
+	s.Regexp(idPattern, second.ID)
+	s.NotEqual(first.ID, second.ID)
+}
+
+// TestDeterministicTeamIDs checks that an injected generator is used
+func (s *TeamTestSuite) TestDeterministicTeamIDs() {
+	ctx := context.Background()
+	u := teamUsecase.NewUsecase(s.Repo, teamUsecase.WithIDGenerator(idgen.NewSequence("seq_team_")))
+
+	first, err := u.CreateTeam(ctx, usecase.CreateTeamParams{Name: "Seq A"})
+	s.Require().NoError(err)
+	second, err := u.CreateTeam(ctx, usecase.CreateTeamParams{Name: "Seq B"})
+	s.Require().NoError(err)
+
+	s.Equal("seq_team_1", first.ID)
+	s.Equal("seq_team_2", second.ID)
+}
+
+// TestTeamIDCollisionRetry checks that a taken ID is skipped
+func (s *TeamTestSuite) TestTeamIDCollisionRetry() {
+	ctx := context.Background()
+
+	ids := []string{"collision_team", "collision_team", "collision_team_2"}
+	u := teamUsecase.NewUsecase(s.Repo, teamUsecase.WithIDGenerator(idgen.Func(func() (string, error) {
+		id := ids[0]
+		ids = ids[1:]
+		return id, nil
+	})))
+
+	first, err := u.CreateTeam(ctx, usecase.CreateTeamParams{Name: "Original"})
+	s.Require().NoError(err)
+	s.Equal("collision_team", first.ID)
+
+	second, err := u.CreateTeam(ctx, usecase.CreateTeamParams{Name: "Retried"})
+	s.Require().NoError(err)
+	s.Equal("collision_team_2", second.ID)
+
+	// The retried team got its own secrets and the original is intact
+	original, err := u.GetTeam(ctx, first.ID)
+	s.Require().NoError(err)
+	s.Equal("Original", original.Name)
+
+	access, err := u.Authorize(ctx, second.AdminToken)
+	s.Require().NoError(err)
+	s.Equal("collision_team_2", access.TeamID)
+}
+
+// TestTeamIDCollisionGivesUp checks that retries are bounded
+func (s *TeamTestSuite) TestTeamIDCollisionGivesUp() {
+	ctx := context.Background()
+	u := teamUsecase.NewUsecase(s.Repo, teamUsecase.WithIDGenerator(idgen.Func(func() (string, error) {
+		return "always_same_team", nil
+	})))
+
+	_, err := u.CreateTeam(ctx, usecase.CreateTeamParams{Name: "First"})
+	s.Require().NoError(err)
+
+	_, err = u.CreateTeam(ctx, usecase.CreateTeamParams{Name: "Second"})
+	s.Error(err)
+}
@@ -2,14 +2,18 @@ package team
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
 	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
 	"github.com/kvloginov/cup-of-team/backend/test/env"
 	"github.com/stretchr/testify/suite"
 )
@@ -19,12 +23,16 @@ type TeamTestSuite struct {
 }
 
 func TestTeamSuite(t *testing.T) {
-	suite.Run(t, new(TeamTestSuite))
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &TeamTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
 }
 
 // TestTeamFullFlow tests the full flow: create team -> add users -> get team
 func (s *TeamTestSuite) TestTeamFullFlow() {
-	var teamID string
+	var teamID, adminToken, viewerToken string
 
 	// Step 1: Create Team
 	s.Run("CreateTeam", func() {
@@ -38,7 +46,7 @@ func (s *TeamTestSuite) TestTeamFullFlow() {
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 
-		s.Handlers.HandleCreateTeam(w, req)
+		s.Router.ServeHTTP(w, req)
 
 		s.Equal(http.StatusOK, w.Code, "Expected status 200 OK")
 
@@ -47,7 +55,12 @@ func (s *TeamTestSuite) TestTeamFullFlow() {
 		s.Require().NoError(err)
 
 		s.NotEmpty(resp.ID, "Team ID should not be empty")
+		s.NotEmpty(resp.AdminToken, "Admin token should not be empty")
+		s.NotEmpty(resp.ViewerToken, "Viewer token should not be empty")
+		s.NotEqual(resp.ID, resp.AdminToken)
 		teamID = resp.ID
+		adminToken = resp.AdminToken
+		viewerToken = resp.ViewerToken
 	})
 
 	// Step 2: Add First User
@@ -60,7 +73,7 @@ func (s *TeamTestSuite) TestTeamFullFlow() {
 				Initials:          "JD",
 				ParentNames:       []string{"Michael", "Sarah"},
 				GrandParentsNames: []string{"Robert", "Mary", "James", "Patricia"},
-				Country:           "USA",
+				Country:           "US",
 			},
 		}
 		body, err := json.Marshal(reqBody)
@@ -68,9 +81,10 @@ func (s *TeamTestSuite) TestTeamFullFlow() {
 
 		req := httptest.NewRequest(http.MethodPost, "/api/team/user", bytes.NewReader(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+adminToken)
 		w := httptest.NewRecorder()
 
-		s.Handlers.HandleAddToTeam(w, req)
+		s.Router.ServeHTTP(w, req)
 
 		s.Equal(http.StatusOK, w.Code, "Expected status 200 OK")
 
@@ -92,7 +106,7 @@ func (s *TeamTestSuite) TestTeamFullFlow() {
 				Initials:          "JS",
 				ParentNames:       []string{"William", "Linda"},
 				GrandParentsNames: []string{"George", "Barbara", "Richard", "Susan"},
-				Country:           "UK",
+				Country:           "GB",
 			},
 		}
 		body, err := json.Marshal(reqBody)
@@ -100,9 +114,10 @@ func (s *TeamTestSuite) TestTeamFullFlow() {
 
 		req := httptest.NewRequest(http.MethodPost, "/api/team/user", bytes.NewReader(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+adminToken)
 		w := httptest.NewRecorder()
 
-		s.Handlers.HandleAddToTeam(w, req)
+		s.Router.ServeHTTP(w, req)
 
 		s.Equal(http.StatusOK, w.Code, "Expected status 200 OK")
 	})
@@ -117,7 +132,7 @@ func (s *TeamTestSuite) TestTeamFullFlow() {
 				Initials:          "JD",
 				ParentNames:       []string{"Michael", "Sarah"},
 				GrandParentsNames: []string{"Robert", "Mary", "James", "Patricia"},
-				Country:           "Canada",
+				Country:           "CA",
 			},
 		}
 		body, err := json.Marshal(reqBody)
@@ -125,9 +140,10 @@ func (s *TeamTestSuite) TestTeamFullFlow() {
 
 		req := httptest.NewRequest(http.MethodPost, "/api/team/user", bytes.NewReader(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+adminToken)
 		w := httptest.NewRecorder()
 
-		s.Handlers.HandleAddToTeam(w, req)
+		s.Router.ServeHTTP(w, req)
 
 		s.Equal(http.StatusOK, w.Code, "Expected status 200 OK")
 
@@ -137,16 +153,17 @@ func (s *TeamTestSuite) TestTeamFullFlow() {
 
 		s.Equal("user1", resp.User.ID)
 		s.Equal("John Updated", resp.User.FirstName)
-		s.Equal("Canada", resp.User.Country)
+		s.Equal("CA", resp.User.Country)
 	})
 
 	// Step 5: Get Team and Verify All Data
 	s.Run("GetTeam", func() {
 		url := fmt.Sprintf("/api/team?team_id=%s", teamID)
 		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", "Bearer "+viewerToken)
 		w := httptest.NewRecorder()
 
-		s.Handlers.HandleGetTeam(w, req)
+		s.Router.ServeHTTP(w, req)
 
 		s.Equal(http.StatusOK, w.Code, "Expected status 200 OK")
 
@@ -176,7 +193,7 @@ func (s *TeamTestSuite) TestTeamFullFlow() {
 		s.Equal("JD", user1.Initials)
 		s.Equal([]string{"Michael", "Sarah"}, user1.ParentNames)
 		s.Equal([]string{"Robert", "Mary", "James", "Patricia"}, user1.GrandParentsNames)
-		s.Equal("Canada", user1.Country)
+		s.Equal("CA", user1.Country)
 
 		// Verify second user
 		s.Equal("user2", user2.ID)
@@ -184,16 +201,17 @@ func (s *TeamTestSuite) TestTeamFullFlow() {
 		s.Equal("JS", user2.Initials)
 		s.Equal([]string{"William", "Linda"}, user2.ParentNames)
 		s.Equal([]string{"George", "Barbara", "Richard", "Susan"}, user2.GrandParentsNames)
-		s.Equal("UK", user2.Country)
+		s.Equal("GB", user2.Country)
 	})
 
 	// Step 6: Remove User
 	s.Run("RemoveUser", func() {
 		url := fmt.Sprintf("/api/team/user?team_id=%s&user_id=%s", teamID, "user2")
 		req := httptest.NewRequest(http.MethodDelete, url, nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
 		w := httptest.NewRecorder()
 
-		s.Handlers.HandleRemoveFromTeam(w, req)
+		s.Router.ServeHTTP(w, req)
 
 		s.Equal(http.StatusOK, w.Code, "Expected status 200 OK")
 	})
@@ -202,9 +220,10 @@ func (s *TeamTestSuite) TestTeamFullFlow() {
 	s.Run("VerifyUserRemoved", func() {
 		url := fmt.Sprintf("/api/team?team_id=%s", teamID)
 		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", "Bearer "+viewerToken)
 		w := httptest.NewRecorder()
 
-		s.Handlers.HandleGetTeam(w, req)
+		s.Router.ServeHTTP(w, req)
 
 		s.Equal(http.StatusOK, w.Code, "Expected status 200 OK")
 
@@ -217,3 +236,179 @@ func (s *TeamTestSuite) TestTeamFullFlow() {
 		s.Equal("user1", resp.Team.Users[0].ID, "Remaining user should be user1")
 	})
 }
+
+// TestConcurrentAddSameUser checks that parallel adds of one user don't race
+func (s *TeamTestSuite) TestConcurrentAddSameUser() {
+	ctx := context.Background()
+
+	team, err := s.Usecase.CreateTeam(ctx, usecase.CreateTeamParams{Name: "Concurrent Team"})
+	s.Require().NoError(err)
+
+	const workers = 8
+	errs := make(chan error, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.Usecase.AddUser(ctx, usecase.AddUserParams{
+				TeamID: team.ID,
+				User: domain.User{
+					ID:        "concurrent_user",
+					FirstName: fmt.Sprintf("Name %d", i),
+				},
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		s.NoError(err)
+	}
+
+	result, err := s.Usecase.GetTeam(ctx, team.ID)
+	s.Require().NoError(err)
+	s.Len(result.Users, 1)
+}
+
+// TestSameUserIDInTwoTeams checks that members are scoped to their team
+func (s *TeamTestSuite) TestSameUserIDInTwoTeams() {
+	ctx := context.Background()
+
+	teamA := s.CreateTeam("Team A")
+	teamB := s.CreateTeam("Team B")
+
+	for _, add := range []struct {
+		team model.CreateTeamResponse
+		name string
+	}{
+		{teamA, "Alice in A"},
+		{teamB, "Alice in B"},
+	} {
+		w := s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{
+			TeamID: add.team.ID,
+			User:   domain.User{ID: "shared_user", FirstName: add.name},
+		}, add.team.AdminToken)
+		s.Require().Equal(http.StatusOK, w.Code)
+	}
+
+	resultA, err := s.Usecase.GetTeam(ctx, teamA.ID)
+	s.Require().NoError(err)
+	s.Require().Len(resultA.Users, 1, "user must stay in team A")
+	s.Equal("Alice in A", resultA.Users[0].FirstName)
+
+	resultB, err := s.Usecase.GetTeam(ctx, teamB.ID)
+	s.Require().NoError(err)
+	s.Require().Len(resultB.Users, 1)
+	s.Equal("Alice in B", resultB.Users[0].FirstName)
+
+	// Removing from one team leaves the other membership alone
+	_, err = s.Usecase.RemoveUser(ctx, teamB.ID, "shared_user", 0)
+	s.Require().NoError(err)
+	resultA, err = s.Usecase.GetTeam(ctx, teamA.ID)
+	s.Require().NoError(err)
+	s.Len(resultA.Users, 1)
+}
+
+// TestUpdateTeam checks renaming a team
+func (s *TeamTestSuite) TestUpdateTeam() {
+	team := s.CreateTeam("Old Name")
+
+	name := "  New Name  "
+	w := s.Do(http.MethodPatch, "/api/v1/teams/"+team.ID, model.UpdateTeamRequest{Name: &name}, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var resp model.UpdateTeamResponse
+	s.Decode(w, &resp)
+	s.Equal(team.ID, resp.Team.ID)
+	s.Equal("New Name", resp.Team.Name)
+
+	// Omitted fields stay unchanged
+	w = s.Do(http.MethodPatch, "/api/v1/teams/"+team.ID, model.UpdateTeamRequest{}, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Decode(w, &resp)
+	s.Equal("New Name", resp.Team.Name)
+
+	empty := " "
+	w = s.Do(http.MethodPatch, "/api/v1/teams/"+team.ID, model.UpdateTeamRequest{Name: &empty}, team.AdminToken)
+	s.Equal(http.StatusBadRequest, w.Code)
+
+	w = s.Do(http.MethodPatch, "/api/v1/teams/"+team.ID, model.UpdateTeamRequest{Name: &name}, team.ViewerToken)
+	s.Equal(http.StatusForbidden, w.Code)
+}
+
+// TestDeleteTeamCascades checks that members, secrets and invites go with
+// the team once it is purged from the trash
+func (s *TeamTestSuite) TestDeleteTeamCascades() {
+	ctx := context.Background()
+
+	team := s.CreateTeam("Doomed Team")
+	other := s.CreateTeam("Surviving Team")
+
+	for _, t := range []model.CreateTeamResponse{team, other} {
+		_, err := s.Usecase.AddUser(ctx, usecase.AddUserParams{
+			TeamID: t.ID,
+			User:   domain.User{ID: "user1", FirstName: "John"},
+		})
+		s.Require().NoError(err)
+	}
+	invite, err := s.Usecase.CreateInvite(ctx, usecase.CreateInviteParams{TeamID: team.ID})
+	s.Require().NoError(err)
+
+	w := s.Do(http.MethodDelete, "/api/v1/teams/"+team.ID, nil, team.ViewerToken)
+	s.Require().Equal(http.StatusForbidden, w.Code)
+
+	w = s.Do(http.MethodDelete, "/api/v1/teams/"+team.ID, nil, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	_, err = s.Usecase.GetTeam(ctx, team.ID)
+	s.ErrorIs(err, usecase.ErrTeamNotFound)
+	s.ErrorIs(s.Usecase.DeleteTeam(ctx, team.ID, 0), usecase.ErrTeamNotFound)
+
+	// The deleted team is gone for good once purged
+	_, err = s.Usecase.PurgeDeleted(ctx, 0)
+	s.Require().NoError(err)
+
+	users, err := s.Repo.GetDeletedUsers(ctx, team.ID)
+	s.Require().NoError(err)
+	s.Empty(users)
+
+	stored, err := s.Repo.GetInvite(ctx, invite.Code)
+	s.Require().NoError(err)
+	s.Nil(stored)
+
+	hasTokens, err := s.Repo.HasTeamTokens(ctx, team.ID)
+	s.Require().NoError(err)
+	s.False(hasTokens)
+
+	// The purged team's secrets no longer work
+	w = s.Do(http.MethodGet, "/api/team?team_id="+team.ID, nil, team.AdminToken)
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	// Other teams are untouched
+	result, err := s.Usecase.GetTeam(ctx, other.ID)
+	s.Require().NoError(err)
+	s.Len(result.Users, 1)
+}
+
+func (s *TeamTestSuite) TestTimestampsAreUTC() {
+	ctx := context.Background()
+	team := s.CreateTeam("UTC Team")
+	_, err := s.Usecase.AddUser(ctx, usecase.AddUserParams{
+		TeamID: team.ID,
+		User:   domain.User{ID: "user1", FirstName: "John"},
+	})
+	s.Require().NoError(err)
+
+	stored, err := s.Repo.GetTeam(ctx, team.ID)
+	s.Require().NoError(err)
+	s.Same(time.UTC, stored.CreatedAt.Location())
+
+	user, err := s.Repo.GetUser(ctx, team.ID, "user1")
+	s.Require().NoError(err)
+	s.Same(time.UTC, user.CreatedAt.Location())
+	s.Same(time.UTC, user.UpdatedAt.Location())
+}
@@ -0,0 +1,247 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/handlers"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/tracing"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase/team"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+// TracingTestSuite serves requests through a server traced the way main
+// does it, exporting the spans to a buffer
+type TracingTestSuite struct {
+	env.BaseSuite
+	spans    *bytes.Buffer
+	shutdown func(context.Context) error
+	router   http.Handler
+}
+
+func TestTracingSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &TracingTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+// span is what the stdout exporter writes of a span
+type span struct {
+	Name        string
+	SpanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	Parent struct {
+		TraceID string
+		SpanID  string
+		Remote  bool
+	}
+	SpanKind   int
+	Attributes []struct {
+		Key   string
+		Value struct {
+			Value interface{}
+		}
+	}
+	Status struct {
+		Code string
+	}
+}
+
+// attr returns the value of an attribute, nil when the span has none
+func (sp span) attr(key string) interface{} {
+	for _, a := range sp.Attributes {
+		if a.Key == key {
+			return a.Value.Value
+		}
+	}
+	return nil
+}
+
+func (s *TracingTestSuite) SetupTest() {
+	s.spans = &bytes.Buffer{}
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter: tracing.ExporterStdout,
+		Writer:   s.spans,
+	})
+	s.Require().NoError(err)
+	s.shutdown = shutdown
+
+	repo := repository.NewTraced(s.Repo)
+	server := httpServer.NewServer(httpServer.Config{})
+	handlers.NewHandlers(usecase.NewTraced(team.NewUsecase(repo))).RegisterRoutes(server)
+	s.router = server.Handler()
+}
+
+func (s *TracingTestSuite) TearDownTest() {
+	if s.shutdown != nil {
+		s.NoError(s.shutdown(context.Background()))
+	}
+}
+
+// do sends a request through the traced server
+func (s *TracingTestSuite) do(method, target string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+	return w
+}
+
+// exported flushes the spans and returns them by name. Spans of the same
+// name are kept in the order they ended.
+func (s *TracingTestSuite) exported() map[string][]span {
+	s.Require().NoError(s.shutdown(context.Background()))
+	s.shutdown = nil
+
+	result := make(map[string][]span)
+	decoder := json.NewDecoder(s.spans)
+	for {
+		var sp span
+		err := decoder.Decode(&sp)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		s.Require().NoError(err)
+		result[sp.Name] = append(result[sp.Name], sp)
+	}
+	return result
+}
+
+// one returns the only span with the given name
+func (s *TracingTestSuite) one(spans map[string][]span, name string) span {
+	s.Require().Len(spans[name], 1, "spans named %s", name)
+	return spans[name][0]
+}
+
+// descends reports whether child is below parent in the trace
+func descends(spans map[string][]span, child, parent span) bool {
+	byID := make(map[string]span)
+	for _, named := range spans {
+		for _, sp := range named {
+			byID[sp.SpanContext.SpanID] = sp
+		}
+	}
+
+	for current, ok := child, true; ok; current, ok = byID[current.Parent.SpanID] {
+		if current.Parent.SpanID == parent.SpanContext.SpanID {
+			return true
+		}
+	}
+	return false
+}
+
+// createTeam creates a team through the traced server
+func (s *TracingTestSuite) createTeam() model.CreateTeamResponse {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/teams", bytes.NewBufferString(`{"name":"Tracing Team"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var created model.CreateTeamResponse
+	s.Decode(w, &created)
+	return created
+}
+
+func (s *TracingTestSuite) TestRequestIsTracedThroughLayers() {
+	created := s.createTeam()
+
+	w := s.do(http.MethodGet, "/api/v1/teams/"+created.ID,
+		http.Header{"Authorization": {"Bearer " + created.ViewerToken}})
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	spans := s.exported()
+	server := s.one(spans, "GET /api/v1/teams/{teamId}")
+	s.Equal(2, server.SpanKind, "server kind")
+	s.Equal("GET", server.attr("http.request.method"))
+	s.Equal("/api/v1/teams/{teamId}", server.attr("http.route"))
+	s.Equal("/api/v1/teams/"+created.ID, server.attr("url.path"))
+	s.EqualValues(http.StatusOK, server.attr("http.response.status_code"))
+	s.Equal("Unset", server.Status.Code)
+
+	getTeam := s.one(spans, "TeamUsecase.GetTeam")
+	s.Equal(created.ID, getTeam.attr("team.id"))
+	s.True(descends(spans, getTeam, server))
+
+	// Both lookups of the team show up below the usecase
+	var teamLookups, userLookups []span
+	for _, sp := range spans["Repository.GetTeam"] {
+		if descends(spans, sp, getTeam) {
+			teamLookups = append(teamLookups, sp)
+		}
+	}
+	for _, sp := range spans["Repository.GetTeamUsers"] {
+		if descends(spans, sp, getTeam) {
+			userLookups = append(userLookups, sp)
+		}
+	}
+	s.Require().Len(teamLookups, 1)
+	s.Require().Len(userLookups, 1)
+	s.Equal(created.ID, userLookups[0].attr("team.id"))
+	s.Equal(server.SpanContext.TraceID, userLookups[0].SpanContext.TraceID)
+
+	// Authorization is part of the same request
+	authorize := spans["TeamUsecase.Authorize"]
+	s.Require().NotEmpty(authorize)
+	s.True(descends(spans, authorize[len(authorize)-1], server))
+}
+
+func (s *TracingTestSuite) TestIncomingTraceIsContinued() {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentID = "00f067aa0ba902b7"
+
+	w := s.do(http.MethodGet, "/health",
+		http.Header{"Traceparent": {"00-" + traceID + "-" + parentID + "-01"}})
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	server := s.one(s.exported(), "GET /health")
+	s.Equal(traceID, server.SpanContext.TraceID)
+	s.Equal(parentID, server.Parent.SpanID)
+	s.True(server.Parent.Remote)
+}
+
+func (s *TracingTestSuite) TestClientErrorsDoNotFailSpans() {
+	created := s.createTeam()
+
+	w := s.do(http.MethodGet, "/api/v1/teams/"+created.ID+"/members/nobody",
+		http.Header{"Authorization": {"Bearer " + created.ViewerToken}})
+	s.Require().Equal(http.StatusNotFound, w.Code)
+
+	spans := s.exported()
+	getUser := s.one(spans, "TeamUsecase.GetUser")
+	s.Equal("Unset", getUser.Status.Code)
+	s.Equal("user_not_found", getUser.attr("error.code"))
+
+	server := s.one(spans, "GET /api/v1/teams/{teamId}/members/{userId}")
+	s.Equal("Unset", server.Status.Code)
+	s.EqualValues(http.StatusNotFound, server.attr("http.response.status_code"))
+}
+
+func (s *TracingTestSuite) TestConfig() {
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{})
+	s.Require().NoError(err)
+	s.NoError(shutdown(context.Background()))
+
+	_, err = tracing.Setup(context.Background(), tracing.Config{Exporter: "zipkin"})
+	s.Error(err)
+}
@@ -0,0 +1,210 @@
+package trash
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+	"github.com/kvloginov/cup-of-team/backend/internal/worker"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+type TrashTestSuite struct {
+	env.BaseSuite
+}
+
+func TestTrashSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &TrashTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+func (s *TrashTestSuite) addUser(teamID, userID, firstName string) {
+	_, err := s.Usecase.AddUser(context.Background(), usecase.AddUserParams{
+		TeamID: teamID,
+		User: domain.User{
+			ID:          userID,
+			FirstName:   firstName,
+			ParentNames: []string{"Michael", "Sarah"},
+		},
+	})
+	s.Require().NoError(err)
+}
+
+func (s *TrashTestSuite) listTrash(team model.CreateTeamResponse) []domain.DeletedUser {
+	w := s.Do(http.MethodGet, "/api/v1/teams/"+team.ID+"/trash", nil, team.ViewerToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var resp model.ListTrashResponse
+	s.Decode(w, &resp)
+	return resp.Users
+}
+
+func (s *TrashTestSuite) TestRemoveAndRestoreUser() {
+	ctx := context.Background()
+	team := s.CreateTeam("Trash Team")
+	s.addUser(team.ID, "user1", "John")
+	s.addUser(team.ID, "user2", "Jane")
+
+	w := s.Do(http.MethodDelete, "/api/team/user?team_id="+team.ID+"&user_id=user1", nil, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	// Removed members leave the team but keep their data in the trash
+	result, err := s.Usecase.GetTeam(ctx, team.ID)
+	s.Require().NoError(err)
+	s.Require().Len(result.Users, 1)
+	s.Equal("user2", result.Users[0].ID)
+
+	trash := s.listTrash(team)
+	s.Require().Len(trash, 1)
+	s.Equal("user1", trash[0].User.ID)
+	s.Equal([]string{"Michael", "Sarah"}, trash[0].User.ParentNames)
+	s.WithinDuration(time.Now(), trash[0].DeletedAt, time.Minute)
+
+	// Removing twice finds nothing to remove
+	w = s.Do(http.MethodDelete, "/api/team/user?team_id="+team.ID+"&user_id=user1", nil, team.AdminToken)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	// Restoring needs the admin role
+	restore := "/api/v1/teams/" + team.ID + "/members/user1/restore"
+	w = s.Do(http.MethodPost, restore, nil, team.ViewerToken)
+	s.Require().Equal(http.StatusForbidden, w.Code)
+
+	w = s.Do(http.MethodPost, restore, nil, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var resp model.RestoreUserResponse
+	s.Decode(w, &resp)
+	s.Equal("John", resp.User.FirstName)
+	s.Equal([]string{"Michael", "Sarah"}, resp.User.ParentNames)
+
+	result, err = s.Usecase.GetTeam(ctx, team.ID)
+	s.Require().NoError(err)
+	s.Len(result.Users, 2)
+	s.Empty(s.listTrash(team))
+
+	// A member that is not deleted can't be restored
+	w = s.Do(http.MethodPost, restore, nil, team.AdminToken)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	var errResp model.ErrorResponse
+	s.Decode(w, &errResp)
+	s.Equal("not_in_trash", errResp.Code)
+}
+
+func (s *TrashTestSuite) TestReAddingDeletedUserKeepsTrash() {
+	ctx := context.Background()
+	team := s.CreateTeam("Re-add Team")
+	s.addUser(team.ID, "user1", "John")
+
+	_, err := s.Usecase.RemoveUser(ctx, team.ID, "user1", 0)
+	s.Require().NoError(err)
+
+	// The ID stays taken by the member in the trash
+	user := domain.User{ID: "user1", FirstName: "Johnny", ParentNames: []string{"Michael"}}
+	w := s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{TeamID: team.ID, User: user}, team.AdminToken)
+	s.Require().Equal(http.StatusConflict, w.Code, "body: %s", w.Body.String())
+
+	var errResp model.ErrorResponse
+	s.Decode(w, &errResp)
+	s.Equal("user_in_trash", errResp.Code)
+
+	trash := s.listTrash(team)
+	s.Require().Len(trash, 1)
+	s.Equal("John", trash[0].User.FirstName)
+
+	// The pending restore brings back the original member
+	restored, err := s.Usecase.RestoreUser(ctx, team.ID, "user1")
+	s.Require().NoError(err)
+	s.Equal("John", restored.FirstName)
+
+	result, err := s.Usecase.GetTeam(ctx, team.ID)
+	s.Require().NoError(err)
+	s.Require().Len(result.Users, 1)
+	s.Equal("John", result.Users[0].FirstName)
+	s.Empty(s.listTrash(team))
+}
+
+func (s *TrashTestSuite) TestDeleteAndRestoreTeam() {
+	ctx := context.Background()
+	team := s.CreateTeam("Deleted Team")
+	s.addUser(team.ID, "user1", "John")
+
+	w := s.Do(http.MethodDelete, "/api/v1/teams/"+team.ID, nil, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	// The team is hidden, but its admin token may still restore it
+	w = s.Do(http.MethodGet, "/api/team?team_id="+team.ID, nil, team.ViewerToken)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	w = s.Do(http.MethodPost, "/api/v1/teams/"+team.ID+"/restore", nil, team.ViewerToken)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	w = s.Do(http.MethodPost, "/api/v1/teams/"+team.ID+"/restore", nil, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var resp model.RestoreTeamResponse
+	s.Decode(w, &resp)
+	s.Equal("Deleted Team", resp.Team.Name)
+	s.Len(resp.Team.Users, 1)
+
+	result, err := s.Usecase.GetTeam(ctx, team.ID)
+	s.Require().NoError(err)
+	s.Len(result.Users, 1)
+
+	_, err = s.Usecase.RestoreTeam(ctx, team.ID)
+	s.ErrorIs(err, usecase.ErrNotInTrash)
+}
+
+func (s *TrashTestSuite) TestPurgeRespectsRetention() {
+	ctx := context.Background()
+	team := s.CreateTeam("Purge Team")
+	s.addUser(team.ID, "user1", "John")
+	_, err := s.Usecase.RemoveUser(ctx, team.ID, "user1", 0)
+	s.Require().NoError(err)
+
+	purgeWorker := worker.NewPurgeWorker(s.Usecase, worker.PurgeConfig{
+		Interval:  time.Hour,
+		Retention: time.Hour,
+	})
+
+	// Recently deleted members are kept
+	purgeWorker.RunOnce(ctx)
+	s.Len(s.listTrash(team), 1)
+
+	// And removed for good once the retention has passed
+	purged, err := s.Usecase.PurgeDeleted(ctx, 0)
+	s.Require().NoError(err)
+	s.EqualValues(1, purged)
+	s.Empty(s.listTrash(team))
+
+	w := s.Do(http.MethodPost, "/api/v1/teams/"+team.ID+"/members/user1/restore", nil, team.AdminToken)
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
+func (s *TrashTestSuite) TestPurgeWorkerStops() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		worker.NewPurgeWorker(s.Usecase, worker.PurgeConfig{
+			Interval:  time.Millisecond,
+			Retention: time.Hour,
+		}).Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		s.Fail("purge worker did not stop")
+	}
+}
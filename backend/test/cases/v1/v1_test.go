@@ -0,0 +1,183 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+type V1TestSuite struct {
+	env.BaseSuite
+}
+
+func TestV1Suite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &V1TestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+func (s *V1TestSuite) createTeam(name string) model.CreateTeamResponse {
+	w := s.Do(http.MethodPost, "/api/v1/teams", model.CreateTeamRequest{Name: name}, "")
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	s.Empty(w.Header().Get("Deprecation"))
+
+	var resp model.CreateTeamResponse
+	s.Decode(w, &resp)
+	return resp
+}
+
+func (s *V1TestSuite) TestTeamResource() {
+	t := s.createTeam("Resource Team")
+	path := "/api/v1/teams/" + t.ID
+
+	w := s.Do(http.MethodGet, path, nil, t.ViewerToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	var team model.GetTeamResponse
+	s.Decode(w, &team)
+	s.Equal("Resource Team", team.Team.Name)
+	s.Equal(`"1"`, w.Header().Get("ETag"))
+
+	// The team ID comes from the path, the body only holds the changes
+	w = s.Do(http.MethodPatch, path, map[string]string{"name": "Renamed"}, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	var updated model.UpdateTeamResponse
+	s.Decode(w, &updated)
+	s.Equal("Renamed", updated.Team.Name)
+
+	w = s.Do(http.MethodDelete, path, nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	w = s.Do(http.MethodGet, path, nil, t.ViewerToken)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	// Restoring needs no body at all
+	w = s.Do(http.MethodPost, path+"/restore", nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	w = s.Do(http.MethodGet, path, nil, t.ViewerToken)
+	s.Equal(http.StatusOK, w.Code)
+}
+
+func (s *V1TestSuite) TestMemberResource() {
+	t := s.createTeam("Resource Team")
+	path := "/api/v1/teams/" + t.ID + "/members/user1"
+
+	w := s.Do(http.MethodGet, path, nil, t.ViewerToken)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	w = s.Do(http.MethodPut, path, domain.User{FirstName: "John", Initials: "JD"}, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	var put model.PutMemberResponse
+	s.Decode(w, &put)
+	s.Equal("user1", put.User.ID)
+
+	put.User.FirstName = "Johnny"
+	w = s.Do(http.MethodPut, path, put.User, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	w = s.Do(http.MethodGet, path, nil, t.ViewerToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	var got model.GetMemberResponse
+	s.Decode(w, &got)
+	s.Equal("Johnny", got.User.FirstName)
+
+	w = s.Do(http.MethodDelete, path, nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	w = s.Do(http.MethodGet, path, nil, t.ViewerToken)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	w = s.Do(http.MethodPost, path+"/restore", nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	w = s.Do(http.MethodGet, path, nil, t.ViewerToken)
+	s.Equal(http.StatusOK, w.Code)
+}
+
+func (s *V1TestSuite) TestPutMemberIDMismatch() {
+	t := s.createTeam("Resource Team")
+
+	w := s.Do(http.MethodPut, "/api/v1/teams/"+t.ID+"/members/user1",
+		domain.User{ID: "user2", FirstName: "John", Initials: "JD"}, t.AdminToken)
+	s.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (s *V1TestSuite) TestAuthorization() {
+	t := s.createTeam("Resource Team")
+	other := s.createTeam("Other Team")
+
+	w := s.Do(http.MethodGet, "/api/v1/teams/"+t.ID, nil, "")
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+t.ID, nil, other.ViewerToken)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	w = s.Do(http.MethodPut, "/api/v1/teams/"+t.ID+"/members/user1",
+		domain.User{FirstName: "John", Initials: "JD"}, t.ViewerToken)
+	s.Equal(http.StatusForbidden, w.Code)
+}
+
+func (s *V1TestSuite) TestNestedResources() {
+	t := s.createTeam("Resource Team")
+	path := "/api/v1/teams/" + t.ID
+
+	w := s.Do(http.MethodPost, path+"/invites", map[string]string{"role": "viewer"}, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	var invite model.CreateInviteResponse
+	s.Decode(w, &invite)
+
+	w = s.Do(http.MethodPost, "/api/v1/invites/"+invite.Invite.Code+"/join",
+		model.JoinByInviteRequest{User: domain.User{ID: "user1", FirstName: "John", Initials: "JD"}}, "")
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	w = s.Do(http.MethodDelete, path+"/invites/"+invite.Invite.Code, nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	w = s.Do(http.MethodPost, path+"/tokens/viewer", nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	var rotated model.RotateTokenResponse
+	s.Decode(w, &rotated)
+
+	w = s.Do(http.MethodGet, path+"/trash", nil, rotated.Token)
+	s.Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	w = s.Do(http.MethodPost, path+"/webhooks", map[string]string{"url": "https://example.com/hook"}, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	var hook model.CreateWebhookResponse
+	s.Decode(w, &hook)
+
+	w = s.Do(http.MethodGet, path+"/webhooks/"+hook.Webhook.ID+"/deliveries", nil, t.AdminToken)
+	s.Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	w = s.Do(http.MethodDelete, path+"/webhooks/"+hook.Webhook.ID, nil, t.AdminToken)
+	s.Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	w = s.Do(http.MethodGet, path+"/audit?limit=1", nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	var audit model.ListAuditResponse
+	s.Decode(w, &audit)
+	s.Equal(domain.AuditWebhookDeleted, audit.Entries[0].Action)
+}
+
+func (s *V1TestSuite) TestLegacyRoutesDeprecated() {
+	t := s.CreateTeam("Legacy Team")
+
+	w := s.Do(http.MethodGet, "/api/team?team_id="+t.ID, nil, t.ViewerToken)
+	s.Equal(http.StatusOK, w.Code)
+	s.NotEmpty(w.Header().Get("Deprecation"))
+
+	// Errors of legacy routes are marked as well
+	w = s.Do(http.MethodGet, "/api/team?team_id="+t.ID, nil, "")
+	s.Equal(http.StatusUnauthorized, w.Code)
+	s.NotEmpty(w.Header().Get("Deprecation"))
+
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+t.ID, nil, t.ViewerToken)
+	s.Equal(http.StatusOK, w.Code)
+	s.Empty(w.Header().Get("Deprecation"))
+}
@@ -0,0 +1,156 @@
+package validation
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+type ValidationTestSuite struct {
+	env.BaseSuite
+}
+
+func TestValidationSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &ValidationTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+// fieldCodes maps invalid fields to their codes
+func fieldCodes(fields []domain.FieldError) map[string]string {
+	codes := make(map[string]string, len(fields))
+	for _, field := range fields {
+		codes[field.Field] = field.Code
+	}
+	return codes
+}
+
+func (s *ValidationTestSuite) TestAllFieldErrorsAtOnce() {
+	team := s.CreateTeam("Validation Team")
+
+	w := s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{
+		TeamID: team.ID,
+		User: domain.User{
+			ID:                "user1",
+			FirstName:         "   ",
+			Initials:          "J1",
+			ParentNames:       []string{"Michael", "Sarah", "Extra"},
+			GrandParentsNames: []string{"Robert", "", "James", "Patricia", "Fifth"},
+			Country:           "USA",
+		},
+	}, team.AdminToken)
+	s.Require().Equal(http.StatusBadRequest, w.Code, "body: %s", w.Body.String())
+
+	var resp model.ErrorResponse
+	s.Decode(w, &resp)
+	s.Equal("invalid_user", resp.Code)
+	s.Equal(map[string]string{
+		"first_name":            "required",
+		"initials":              "invalid",
+		"parent_names":          "too_many",
+		"grandparents_names":    "too_many",
+		"grandparents_names[1]": "required",
+		"country":               "invalid",
+	}, fieldCodes(resp.Fields))
+}
+
+func (s *ValidationTestSuite) TestInitialsMustMatchFirstName() {
+	team := s.CreateTeam("Initials Team")
+
+	w := s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{
+		TeamID: team.ID,
+		User:   domain.User{ID: "user1", FirstName: "John", Initials: "MD"},
+	}, team.AdminToken)
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+
+	var resp model.ErrorResponse
+	s.Decode(w, &resp)
+	s.Equal(map[string]string{"initials": "mismatch"}, fieldCodes(resp.Fields))
+}
+
+func (s *ValidationTestSuite) TestLengthLimits() {
+	ctx := context.Background()
+	team := s.CreateTeam("Limits Team")
+
+	_, err := s.Usecase.AddUser(ctx, usecase.AddUserParams{
+		TeamID: team.ID,
+		User: domain.User{
+			ID:          strings.Repeat("i", domain.MaxUserIDLength+1),
+			FirstName:   strings.Repeat("ж", domain.MaxNameLength+1),
+			ParentNames: []string{strings.Repeat("a", domain.MaxNameLength+1)},
+		},
+	})
+	s.Require().ErrorIs(err, domain.ErrValidation)
+
+	var domainErr *domain.Error
+	s.Require().ErrorAs(err, &domainErr)
+	s.Equal(map[string]string{
+		"id":              "too_long",
+		"first_name":      "too_long",
+		"parent_names[0]": "too_long",
+	}, fieldCodes(domainErr.Fields))
+
+	// Multi-byte names are limited by characters, not bytes
+	_, err = s.Usecase.AddUser(ctx, usecase.AddUserParams{
+		TeamID: team.ID,
+		User:   domain.User{ID: "user1", FirstName: strings.Repeat("ж", domain.MaxNameLength)},
+	})
+	s.NoError(err)
+}
+
+func (s *ValidationTestSuite) TestNormalization() {
+	ctx := context.Background()
+	team := s.CreateTeam("Normalize Team")
+
+	added, err := s.Usecase.AddUser(ctx, usecase.AddUserParams{
+		TeamID: team.ID,
+		User: domain.User{
+			ID:          " user1 ",
+			FirstName:   "  Élodie ",
+			Initials:    "éd",
+			ParentNames: []string{" Marie "},
+			Country:     " fr",
+		},
+	})
+	s.Require().NoError(err)
+	user := added.User
+
+	s.Equal("user1", user.ID)
+	s.Equal("Élodie", user.FirstName)
+	s.Equal("ÉD", user.Initials)
+	s.Equal([]string{"Marie"}, user.ParentNames)
+	s.Equal("FR", user.Country)
+
+	result, err := s.Usecase.GetTeam(ctx, team.ID)
+	s.Require().NoError(err)
+	s.Require().Len(result.Users, 1)
+	s.Equal("FR", result.Users[0].Country)
+}
+
+func (s *ValidationTestSuite) TestJoinByInviteValidatesUser() {
+	ctx := context.Background()
+	team := s.CreateTeam("Invite Validation Team")
+
+	invite, err := s.Usecase.CreateInvite(ctx, usecase.CreateInviteParams{TeamID: team.ID, MaxUses: 1})
+	s.Require().NoError(err)
+
+	w := s.Do(http.MethodPost, "/api/v1/invites/"+invite.Code+"/join", model.JoinByInviteRequest{
+		User: domain.User{ID: "user1", FirstName: "Ann", Country: "XX"},
+	}, "")
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+
+	// A rejected join does not use up the invite
+	w = s.Do(http.MethodPost, "/api/v1/invites/"+invite.Code+"/join", model.JoinByInviteRequest{
+		User: domain.User{ID: "user1", FirstName: "Ann", Country: "SE"},
+	}, "")
+	s.Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+}
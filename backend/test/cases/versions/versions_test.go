@@ -0,0 +1,242 @@
+package versions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+type VersionsTestSuite struct {
+	env.BaseSuite
+}
+
+func TestVersionsSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &VersionsTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+// doWithHeader sends a request like Do with one extra header
+func (s *VersionsTestSuite) doWithHeader(method, target string, body interface{}, token, header, value string) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		s.Require().NoError(err)
+	}
+
+	req := httptest.NewRequest(method, target, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(header, value)
+
+	w := httptest.NewRecorder()
+	s.Router.ServeHTTP(w, req)
+	return w
+}
+
+// getTeam returns the team and its ETag
+func (s *VersionsTestSuite) getTeam(team model.CreateTeamResponse) (domain.Team, string) {
+	w := s.Do(http.MethodGet, "/api/team?team_id="+team.ID, nil, team.ViewerToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var resp model.GetTeamResponse
+	s.Decode(w, &resp)
+	return resp.Team, w.Header().Get("ETag")
+}
+
+func (s *VersionsTestSuite) addUser(team model.CreateTeamResponse, user domain.User) *httptest.ResponseRecorder {
+	return s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{TeamID: team.ID, User: user}, team.AdminToken)
+}
+
+func (s *VersionsTestSuite) TestETagFollowsChanges() {
+	team := s.CreateTeam("ETag Team")
+
+	result, etag := s.getTeam(team)
+	s.Equal(int64(1), result.Version)
+	s.Equal(`"1"`, etag)
+
+	// Unchanged teams are answered with 304
+	w := s.doWithHeader(http.MethodGet, "/api/team?team_id="+team.ID, nil, team.ViewerToken, "If-None-Match", etag)
+	s.Equal(http.StatusNotModified, w.Code)
+	s.Empty(w.Body.String())
+
+	// Every change of a member changes the team's ETag
+	s.Require().Equal(http.StatusOK, s.addUser(team, domain.User{ID: "user1", FirstName: "John"}).Code)
+	result, newETag := s.getTeam(team)
+	s.NotEqual(etag, newETag)
+	s.Equal(int64(2), result.Version)
+
+	w = s.doWithHeader(http.MethodGet, "/api/team?team_id="+team.ID, nil, team.ViewerToken, "If-None-Match", etag)
+	s.Equal(http.StatusOK, w.Code)
+}
+
+func (s *VersionsTestSuite) TestMemberWritesReturnETag() {
+	team := s.CreateTeam("Chained Writes")
+
+	// Each member write answers with the ETag the next write expects
+	w := s.addUser(team, domain.User{ID: "user1", FirstName: "John"})
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	s.Equal(`"2"`, w.Header().Get("ETag"))
+
+	member := "/api/v1/teams/" + team.ID + "/members/user1"
+	w = s.doWithHeader(http.MethodPut, member, domain.User{FirstName: "Johnny"}, team.AdminToken, "If-Match", w.Header().Get("ETag"))
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	s.Equal(`"3"`, w.Header().Get("ETag"))
+
+	w = s.doWithHeader(http.MethodDelete, member, nil, team.AdminToken, "If-Match", w.Header().Get("ETag"))
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	s.Equal(`"4"`, w.Header().Get("ETag"))
+
+	_, etag := s.getTeam(team)
+	s.Equal(w.Header().Get("ETag"), etag)
+}
+
+func (s *VersionsTestSuite) TestConcurrentUserEditsConflict() {
+	team := s.CreateTeam("Two Phones")
+	s.Require().Equal(http.StatusOK, s.addUser(team, domain.User{ID: "user1", FirstName: "John"}).Code)
+
+	// Both phones load the same copy
+	result, _ := s.getTeam(team)
+	s.Require().Len(result.Users, 1)
+	copyA := result.Users[0]
+	copyB := result.Users[0]
+	s.Equal(int64(1), copyA.Version)
+
+	copyA.FirstName = "Johnny"
+	w := s.addUser(team, copyA)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var resp model.AddToTeamResponse
+	s.Decode(w, &resp)
+	s.Equal(int64(2), resp.User.Version)
+
+	// The second phone edited a stale copy
+	copyB.FirstName = "Jack"
+	w = s.addUser(team, copyB)
+	s.Require().Equal(http.StatusPreconditionFailed, w.Code, "body: %s", w.Body.String())
+
+	var errResp model.ErrorResponse
+	s.Decode(w, &errResp)
+	s.Equal("version_mismatch", errResp.Code)
+
+	result, _ = s.getTeam(team)
+	s.Equal("Johnny", result.Users[0].FirstName)
+
+	// Version 0 still overwrites blindly
+	copyB.Version = 0
+	s.Equal(http.StatusOK, s.addUser(team, copyB).Code)
+}
+
+func (s *VersionsTestSuite) TestVersionOfUnknownUser() {
+	team := s.CreateTeam("Unknown User")
+
+	w := s.addUser(team, domain.User{ID: "ghost", FirstName: "Casper", Version: 3})
+	s.Equal(http.StatusPreconditionFailed, w.Code)
+}
+
+func (s *VersionsTestSuite) TestIfMatchOnTeamWrites() {
+	team := s.CreateTeam("If-Match Team")
+	_, etag := s.getTeam(team)
+
+	// Someone else changes the team
+	s.Require().Equal(http.StatusOK, s.addUser(team, domain.User{ID: "user1", FirstName: "John"}).Code)
+
+	name := "Renamed"
+	rename := model.UpdateTeamRequest{Name: &name}
+	w := s.doWithHeader(http.MethodPatch, "/api/v1/teams/"+team.ID, rename, team.AdminToken, "If-Match", etag)
+	s.Require().Equal(http.StatusPreconditionFailed, w.Code)
+
+	w = s.doWithHeader(http.MethodDelete, "/api/team/user?team_id="+team.ID+"&user_id=user1", nil, team.AdminToken, "If-Match", etag)
+	s.Require().Equal(http.StatusPreconditionFailed, w.Code)
+
+	w = s.doWithHeader(http.MethodPost, "/api/team/user", model.AddToTeamRequest{
+		TeamID: team.ID,
+		User:   domain.User{ID: "user2", FirstName: "Jane"},
+	}, team.AdminToken, "If-Match", etag)
+	s.Require().Equal(http.StatusPreconditionFailed, w.Code)
+
+	w = s.doWithHeader(http.MethodDelete, "/api/v1/teams/"+team.ID, nil, team.AdminToken, "If-Match", etag)
+	s.Require().Equal(http.StatusPreconditionFailed, w.Code)
+
+	// Nothing was changed by the rejected writes
+	result, current := s.getTeam(team)
+	s.Equal("If-Match Team", result.Name)
+	s.Len(result.Users, 1)
+
+	// With the current ETag the write goes through and returns the next one
+	w = s.doWithHeader(http.MethodPatch, "/api/v1/teams/"+team.ID, rename, team.AdminToken, "If-Match", current)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	s.NotEqual(current, w.Header().Get("ETag"))
+
+	var resp model.UpdateTeamResponse
+	s.Decode(w, &resp)
+	s.Equal("Renamed", resp.Team.Name)
+	s.Equal(fmt.Sprintf(`"%d"`, resp.Team.Version), w.Header().Get("ETag"))
+
+	// "*" matches any version
+	w = s.doWithHeader(http.MethodPatch, "/api/v1/teams/"+team.ID, rename, team.AdminToken, "If-Match", "*")
+	s.Equal(http.StatusOK, w.Code)
+
+	// Weak or malformed ETags never match
+	for _, value := range []string{`W/"3"`, `3`, `"x"`} {
+		w = s.doWithHeader(http.MethodPatch, "/api/v1/teams/"+team.ID, rename, team.AdminToken, "If-Match", value)
+		s.Equal(http.StatusPreconditionFailed, w.Code, value)
+	}
+}
+
+func (s *VersionsTestSuite) TestDeletedUserIsNotReAdded() {
+	team := s.CreateTeam("Re-added")
+	s.Require().Equal(http.StatusOK, s.addUser(team, domain.User{ID: "user1", FirstName: "John"}).Code)
+
+	w := s.Do(http.MethodDelete, "/api/team/user?team_id="+team.ID+"&user_id=user1", nil, team.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	// Copies from before the deletion no longer match
+	w = s.addUser(team, domain.User{ID: "user1", FirstName: "John", Version: 1})
+	s.Equal(http.StatusPreconditionFailed, w.Code)
+
+	// The deleted member has to be restored instead
+	w = s.addUser(team, domain.User{ID: "user1", FirstName: "John"})
+	s.Equal(http.StatusConflict, w.Code)
+}
+
+func (s *VersionsTestSuite) TestIfMatchOnMemberWrites() {
+	team := s.CreateTeam("Member ETag")
+	s.Require().Equal(http.StatusOK, s.addUser(team, domain.User{ID: "user1", FirstName: "John"}).Code)
+	path := "/api/v1/teams/" + team.ID + "/members/user1"
+
+	// A member is sent with the ETag of its team
+	w := s.Do(http.MethodGet, path, nil, team.ViewerToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+	etag := w.Header().Get("ETag")
+	_, teamETag := s.getTeam(team)
+	s.Equal(teamETag, etag)
+
+	w = s.doWithHeader(http.MethodGet, path, nil, team.ViewerToken, "If-None-Match", etag)
+	s.Equal(http.StatusNotModified, w.Code)
+
+	// Writes of the member take that ETag
+	w = s.doWithHeader(http.MethodPut, path, domain.User{FirstName: "Johnny"}, team.AdminToken, "If-Match", etag)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	w = s.doWithHeader(http.MethodDelete, path, nil, team.AdminToken, "If-Match", etag)
+	s.Equal(http.StatusPreconditionFailed, w.Code)
+
+	w = s.Do(http.MethodGet, path, nil, team.ViewerToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.NotEqual(etag, w.Header().Get("ETag"))
+
+	w = s.doWithHeader(http.MethodDelete, path, nil, team.AdminToken, "If-Match", w.Header().Get("ETag"))
+	s.Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+}
@@ -0,0 +1,435 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kvloginov/cup-of-team/backend/internal/api/handlers"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
+	"github.com/kvloginov/cup-of-team/backend/internal/domain"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/webhook"
+	"github.com/kvloginov/cup-of-team/backend/internal/usecase/team"
+	"github.com/kvloginov/cup-of-team/backend/test/env"
+	"github.com/stretchr/testify/suite"
+)
+
+const testSecret = "0123456789abcdef-test-secret"
+
+type WebhooksTestSuite struct {
+	env.BaseSuite
+	receiver *receiver
+	server   *httptest.Server
+}
+
+func TestWebhooksSuite(t *testing.T) {
+	for _, driver := range env.Drivers() {
+		t.Run(string(driver), func(t *testing.T) {
+			suite.Run(t, &WebhooksTestSuite{BaseSuite: env.BaseSuite{Driver: driver}})
+		})
+	}
+}
+
+// receiver records webhook requests and answers with scripted statuses
+type receiver struct {
+	mu       sync.Mutex
+	requests []received
+	statuses []int // answered in order, 200 once used up
+	// onRequest, when set, runs before answering
+	onRequest func()
+}
+
+type received struct {
+	Header http.Header
+	Body   []byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if rc.onRequest != nil {
+		rc.onRequest()
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.requests = append(rc.requests, received{Header: r.Header.Clone(), Body: body})
+
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) received() []received {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]received(nil), rc.requests...)
+}
+
+func (s *WebhooksTestSuite) SetupTest() {
+	s.receiver = &receiver{}
+	s.server = httptest.NewServer(s.receiver)
+}
+
+func (s *WebhooksTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// deliverer sends deliveries from the suite's storage with a fast backoff
+func (s *WebhooksTestSuite) deliverer(maxAttempts int) *team.Usecase {
+	return team.NewUsecase(s.Repo,
+		team.WithWebhookSender(webhook.NewSender(team.DefaultWebhookTimeout, env.Loopback)),
+		team.WithWebhookRetry(team.WebhookRetry{
+			MaxAttempts: maxAttempts,
+			BaseDelay:   10 * time.Millisecond,
+			MaxDelay:    40 * time.Millisecond,
+		}))
+}
+
+func (s *WebhooksTestSuite) createWebhook(t model.CreateTeamResponse, events ...domain.EventType) domain.Webhook {
+	w := s.Do(http.MethodPost, "/api/v1/teams/"+t.ID+"/webhooks", model.CreateWebhookRequest{
+		URL:    s.server.URL + "/hook",
+		Secret: testSecret,
+		Events: events,
+	}, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var resp model.CreateWebhookResponse
+	s.Decode(w, &resp)
+	return resp.Webhook
+}
+
+func (s *WebhooksTestSuite) deliveries(t model.CreateTeamResponse, webhookID string) []domain.WebhookDelivery {
+	w := s.Do(http.MethodGet, "/api/v1/teams/"+t.ID+"/webhooks/"+webhookID+"/deliveries", nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var resp model.ListWebhookDeliveriesResponse
+	s.Decode(w, &resp)
+	return resp.Deliveries
+}
+
+func (s *WebhooksTestSuite) addUser(t model.CreateTeamResponse, user domain.User) {
+	w := s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{TeamID: t.ID, User: user}, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+}
+
+func (s *WebhooksTestSuite) TestRegisterAndList() {
+	t := s.CreateTeam("Hooked Team")
+
+	// A generated secret is shown once
+	w := s.Do(http.MethodPost, "/api/v1/teams/"+t.ID+"/webhooks", model.CreateWebhookRequest{
+		URL: "https://example.com/hook",
+	}, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var created model.CreateWebhookResponse
+	s.Decode(w, &created)
+	s.NotEmpty(created.Webhook.ID)
+	s.GreaterOrEqual(len(created.Webhook.Secret), domain.MinWebhookSecretLength)
+	s.Empty(created.Webhook.Events)
+
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+t.ID+"/webhooks", nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	var list model.ListWebhooksResponse
+	s.Decode(w, &list)
+	s.Require().Len(list.Webhooks, 1)
+	s.Equal(created.Webhook.ID, list.Webhooks[0].ID)
+	s.Equal("https://example.com/hook", list.Webhooks[0].URL)
+	s.Empty(list.Webhooks[0].Secret)
+
+	// Viewers can't see or manage webhooks
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+t.ID+"/webhooks", nil, t.ViewerToken)
+	s.Equal(http.StatusForbidden, w.Code)
+}
+
+func (s *WebhooksTestSuite) TestRegisterValidation() {
+	t := s.CreateTeam("Invalid Hooks")
+
+	w := s.Do(http.MethodPost, "/api/v1/teams/"+t.ID+"/webhooks", model.CreateWebhookRequest{
+		URL:    "ftp://example.com",
+		Secret: "short",
+		Events: []domain.EventType{domain.EventMemberAdded, "member_exploded"},
+	}, t.AdminToken)
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+
+	var errResp model.ErrorResponse
+	s.Decode(w, &errResp)
+	s.Equal("invalid_webhook", errResp.Code)
+
+	fields := map[string]string{}
+	for _, field := range errResp.Fields {
+		fields[field.Field] = field.Code
+	}
+	s.Equal(map[string]string{
+		"url":       "invalid",
+		"secret":    "too_short",
+		"events[1]": "invalid",
+	}, fields)
+}
+
+func (s *WebhooksTestSuite) TestInternalTargetsAreRejected() {
+	t := s.CreateTeam("Internal Hooks")
+	strict := handlers.NewHandlers(team.NewUsecase(s.Repo))
+	server := httpServer.NewServer(httpServer.Config{})
+	strict.RegisterRoutes(server)
+	router := server.Handler()
+
+	for _, target := range []string{
+		s.server.URL + "/hook",
+		"http://localhost:8080/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.5/hook",
+		"http://192.168.1.1/hook",
+		"http://[::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://0.0.0.0:8080/hook",
+	} {
+		body, _ := json.Marshal(model.CreateWebhookRequest{URL: target})
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/teams/"+t.ID+"/webhooks", bytes.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+t.AdminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		s.Require().Equal(http.StatusBadRequest, w.Code, target)
+
+		var errResp model.ErrorResponse
+		s.Decode(w, &errResp)
+		s.Require().Len(errResp.Fields, 1, target)
+		s.Equal("url", errResp.Fields[0].Field, target)
+		s.Equal("forbidden", errResp.Fields[0].Code, target)
+	}
+
+	// Names are checked once resolved, when connecting
+	sender := webhook.NewSender(time.Second, nil)
+	s.NoError(sender.CheckURL("http://localhost.example.com/hook"))
+	status, err := sender.Send(context.Background(), webhook.Request{
+		URL:     strings.Replace(s.server.URL, "127.0.0.1", "localhost", 1) + "/hook",
+		Payload: []byte("{}"),
+	})
+	s.Zero(status)
+	s.ErrorIs(err, webhook.ErrForbiddenTarget)
+	s.Empty(s.receiver.received())
+
+	// Public addresses and allowed networks pass
+	s.NoError(sender.CheckURL("https://203.0.113.10/hook"))
+	allowlist, err := webhook.ParseAllowlist([]string{"10.0.0.0/8", "::1"})
+	s.Require().NoError(err)
+	s.NoError(allowlist.CheckURL("http://10.1.2.3/hook"))
+	s.NoError(allowlist.CheckURL("http://[::1]/hook"))
+	s.ErrorIs(allowlist.CheckURL("http://192.168.1.1/hook"), webhook.ErrForbiddenTarget)
+	_, err = webhook.ParseAllowlist([]string{"10.0.0.0/33"})
+	s.Error(err)
+}
+
+func (s *WebhooksTestSuite) TestSignedDelivery() {
+	t := s.CreateTeam("Signed Team")
+	hook := s.createWebhook(t)
+
+	s.addUser(t, domain.User{ID: "user1", FirstName: "John"})
+
+	delivered, err := s.deliverer(3).DeliverWebhooks(context.Background())
+	s.Require().NoError(err)
+	s.Equal(1, delivered)
+
+	requests := s.receiver.received()
+	s.Require().Len(requests, 1)
+	req := requests[0]
+
+	// The signature covers the timestamp and the body
+	timestamp, err := strconv.ParseInt(req.Header.Get(webhook.HeaderTimestamp), 10, 64)
+	s.Require().NoError(err)
+	s.Equal(webhook.Sign(testSecret, timestamp, req.Body), req.Header.Get(webhook.HeaderSignature))
+	s.NotEqual(webhook.Sign("another secret", timestamp, req.Body), req.Header.Get(webhook.HeaderSignature))
+	s.Equal("member_added", req.Header.Get(webhook.HeaderEvent))
+	s.Equal("application/json", req.Header.Get("Content-Type"))
+
+	var event domain.Event
+	s.Require().NoError(json.Unmarshal(req.Body, &event))
+	s.NotZero(event.ID)
+	s.Equal(domain.EventMemberAdded, event.Type)
+	s.Equal(t.ID, event.TeamID)
+	s.Equal("John", event.User.FirstName)
+
+	log := s.deliveries(t, hook.ID)
+	s.Require().Len(log, 1)
+	s.Equal(domain.DeliveryDelivered, log[0].Status)
+	s.Equal(event.ID, log[0].EventID)
+	s.Equal(1, log[0].Attempts)
+	s.Equal(http.StatusOK, log[0].ResponseStatus)
+	s.Nil(log[0].NextAttemptAt)
+	s.Equal(strconv.FormatInt(log[0].ID, 10), req.Header.Get(webhook.HeaderDelivery))
+
+	// Nothing is sent twice
+	delivered, err = s.deliverer(3).DeliverWebhooks(context.Background())
+	s.Require().NoError(err)
+	s.Zero(delivered)
+	s.Len(s.receiver.received(), 1)
+}
+
+func (s *WebhooksTestSuite) TestEventFilter() {
+	t := s.CreateTeam("Filtered Team")
+	hook := s.createWebhook(t, domain.EventMemberRemoved)
+
+	s.addUser(t, domain.User{ID: "user1", FirstName: "John"})
+	w := s.Do(http.MethodDelete, "/api/team/user?team_id="+t.ID+"&user_id=user1", nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	_, err := s.deliverer(3).DeliverWebhooks(context.Background())
+	s.Require().NoError(err)
+
+	log := s.deliveries(t, hook.ID)
+	s.Require().Len(log, 1)
+	s.Equal(domain.EventMemberRemoved, log[0].EventType)
+	s.Len(s.receiver.received(), 1)
+}
+
+func (s *WebhooksTestSuite) TestRetriesWithBackoff() {
+	t := s.CreateTeam("Flaky Receiver")
+	hook := s.createWebhook(t)
+	s.receiver.statuses = []int{http.StatusInternalServerError, http.StatusBadGateway}
+
+	s.addUser(t, domain.User{ID: "user1", FirstName: "John"})
+	deliverer := s.deliverer(5)
+
+	delivered, err := deliverer.DeliverWebhooks(context.Background())
+	s.Require().NoError(err)
+	s.Zero(delivered)
+
+	log := s.deliveries(t, hook.ID)
+	s.Require().Len(log, 1)
+	s.Equal(domain.DeliveryPending, log[0].Status)
+	s.Equal(1, log[0].Attempts)
+	s.Equal(http.StatusInternalServerError, log[0].ResponseStatus)
+	s.NotEmpty(log[0].LastError)
+	s.Require().NotNil(log[0].NextAttemptAt)
+	s.True(log[0].NextAttemptAt.After(*log[0].LastAttemptAt))
+
+	// Not due yet
+	delivered, err = deliverer.DeliverWebhooks(context.Background())
+	s.Require().NoError(err)
+	s.Zero(delivered)
+	s.Len(s.receiver.received(), 1)
+
+	// The second failure waits twice as long, the third attempt succeeds
+	s.Eventually(func() bool {
+		delivered, err := deliverer.DeliverWebhooks(context.Background())
+		s.Require().NoError(err)
+		return delivered == 1
+	}, 2*time.Second, 5*time.Millisecond)
+
+	log = s.deliveries(t, hook.ID)
+	s.Equal(domain.DeliveryDelivered, log[0].Status)
+	s.Equal(3, log[0].Attempts)
+	s.Empty(log[0].LastError)
+
+	// Retries carry the same delivery ID and body
+	requests := s.receiver.received()
+	s.Require().Len(requests, 3)
+	for _, req := range requests[1:] {
+		s.Equal(requests[0].Header.Get(webhook.HeaderDelivery), req.Header.Get(webhook.HeaderDelivery))
+		s.Equal(requests[0].Body, req.Body)
+	}
+}
+
+func (s *WebhooksTestSuite) TestGivesUpAfterMaxAttempts() {
+	t := s.CreateTeam("Dead Receiver")
+	hook := s.createWebhook(t)
+	s.receiver.statuses = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+
+	s.addUser(t, domain.User{ID: "user1", FirstName: "John"})
+	deliverer := s.deliverer(2)
+
+	s.Eventually(func() bool {
+		_, err := deliverer.DeliverWebhooks(context.Background())
+		s.Require().NoError(err)
+		return s.deliveries(t, hook.ID)[0].Status != domain.DeliveryPending
+	}, 2*time.Second, 5*time.Millisecond)
+
+	log := s.deliveries(t, hook.ID)
+	s.Equal(domain.DeliveryFailed, log[0].Status)
+	s.Equal(2, log[0].Attempts)
+	s.Equal(http.StatusServiceUnavailable, log[0].ResponseStatus)
+	s.Nil(log[0].NextAttemptAt)
+	s.Len(s.receiver.received(), 2)
+}
+
+func (s *WebhooksTestSuite) TestDeliveriesAreClaimedOneAtATime() {
+	t := s.CreateTeam("Slow Receiver")
+	s.createWebhook(t, domain.EventMemberAdded)
+	s.addUser(t, domain.User{ID: "user1", FirstName: "John"})
+	s.addUser(t, domain.User{ID: "user2", FirstName: "Jane"})
+
+	// Another worker claims what is due while the first delivery is sent
+	var stolen []repository.Delivery
+	s.receiver.onRequest = func() {
+		if stolen == nil {
+			now := time.Now()
+			claimed, err := s.Repo.ClaimDeliveries(context.Background(), now, now.Add(team.DeliveryLease), 10)
+			s.Require().NoError(err)
+			stolen = append([]repository.Delivery{}, claimed...)
+		}
+	}
+
+	delivered, err := s.deliverer(3).DeliverWebhooks(context.Background())
+	s.Require().NoError(err)
+
+	// The second delivery was still free to claim, and sent only once
+	s.Equal(1, delivered)
+	s.Len(stolen, 1)
+	s.Len(s.receiver.received(), 1)
+}
+
+func (s *WebhooksTestSuite) TestRejectedChangesAreNotDelivered() {
+	t := s.CreateTeam("Careful Team")
+	hook := s.createWebhook(t)
+
+	w := s.Do(http.MethodPost, "/api/team/user", model.AddToTeamRequest{
+		TeamID: t.ID,
+		User:   domain.User{ID: "user1", FirstName: "John", Version: 3},
+	}, t.AdminToken)
+	s.Require().Equal(http.StatusPreconditionFailed, w.Code)
+
+	s.Empty(s.deliveries(t, hook.ID))
+}
+
+func (s *WebhooksTestSuite) TestDeleteWebhook() {
+	t := s.CreateTeam("Unhooked Team")
+	other := s.CreateTeam("Other Team")
+	hook := s.createWebhook(t)
+
+	s.addUser(t, domain.User{ID: "user1", FirstName: "John"})
+
+	// Webhooks of other teams are unknown
+	w := s.Do(http.MethodDelete, "/api/v1/teams/"+other.ID+"/webhooks/"+hook.ID, nil, other.AdminToken)
+	s.Equal(http.StatusNotFound, w.Code)
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+other.ID+"/webhooks/"+hook.ID+"/deliveries", nil, other.AdminToken)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	w = s.Do(http.MethodDelete, "/api/v1/teams/"+t.ID+"/webhooks/"+hook.ID, nil, t.AdminToken)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	// Queued deliveries go with it
+	delivered, err := s.deliverer(3).DeliverWebhooks(context.Background())
+	s.Require().NoError(err)
+	s.Zero(delivered)
+	s.Empty(s.receiver.received())
+
+	w = s.Do(http.MethodGet, "/api/v1/teams/"+t.ID+"/webhooks/"+hook.ID+"/deliveries", nil, t.AdminToken)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	var errResp model.ErrorResponse
+	s.Decode(w, &errResp)
+	s.Equal("webhook_not_found", errResp.Code)
+}
@@ -1,24 +1,43 @@
 package env
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/kvloginov/cup-of-team/backend/internal/api/handlers"
+	"github.com/kvloginov/cup-of-team/backend/internal/api/model"
 	"github.com/kvloginov/cup-of-team/backend/internal/infra/db"
+	httpServer "github.com/kvloginov/cup-of-team/backend/internal/infra/http"
 	"github.com/kvloginov/cup-of-team/backend/internal/infra/repository"
+	"github.com/kvloginov/cup-of-team/backend/internal/infra/webhook"
 	"github.com/kvloginov/cup-of-team/backend/internal/usecase/team"
 	"github.com/stretchr/testify/suite"
 )
 
+// Loopback lets webhooks target test servers on the local host
+var Loopback = webhook.Allowlist{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+
+// PostgresDSNEnv names the variable holding a DSN for PostgreSQL runs
+const PostgresDSNEnv = "TEST_POSTGRES_DSN"
+
 type BaseSuite struct {
 	suite.Suite
+	// Driver selects the storage, in-memory when empty
+	Driver   db.Driver
 	DB       *db.DB
-	Repo     *repository.Repository
+	Repo     repository.Repository
 	Usecase  *team.Usecase
 	Handlers *handlers.Handlers
-	dbPath   string
+	Server   *httpServer.Server
+	// Router serves all registered routes, including middleware
+	Router http.Handler
 }
 
 func (s *BaseSuite) SetupTest() {
@@ -28,17 +47,106 @@ func TestBaseSuite(t *testing.T) {
 	suite.Run(t, new(BaseSuite))
 }
 
+// Drivers returns the storages suites should run against.
+// PostgreSQL is included only when TEST_POSTGRES_DSN is set.
+func Drivers() []db.Driver {
+	drivers := []db.Driver{db.DriverMemory, db.DriverSQLite}
+	if os.Getenv(PostgresDSNEnv) != "" {
+		drivers = append(drivers, db.DriverPostgres)
+	}
+	return drivers
+}
+
 func (s *BaseSuite) SetupSuite() {
-	// Create temporary database file for tests
-	s.dbPath = filepath.Join(os.TempDir(), "cup-of-team-test.db")
+	switch s.Driver {
+	case db.DriverMemory, "":
+		s.Repo = repository.NewMemory()
 
-	database, err := db.New(s.dbPath)
-	s.Require().NoError(err, "Failed to initialize test database")
-	s.DB = database
+	case db.DriverSQLite:
+		// Temporary database file, removed together with the test dir
+		database, err := db.New(db.Config{
+			Driver: db.DriverSQLite,
+			DSN:    filepath.Join(s.T().TempDir(), "cup-of-team-test.db"),
+		})
+		s.Require().NoError(err, "Failed to initialize test database")
+		s.DB = database
+		s.Repo = repository.NewSQLite(database.DB, repository.WithQueryTimeout(database.QueryTimeout))
+
+	case db.DriverPostgres:
+		database := s.openPostgres()
+		s.DB = database
+		s.Repo = repository.NewPostgres(database.DB, repository.WithQueryTimeout(database.QueryTimeout))
+
+	default:
+		s.FailNow("unknown driver", string(s.Driver))
+	}
 
-	s.Repo = repository.New(database.DB)
-	s.Usecase = team.NewUsecase(s.Repo)
+	s.Usecase = team.NewUsecase(s.Repo,
+		team.WithWebhookSender(webhook.NewSender(team.DefaultWebhookTimeout, Loopback)))
 	s.Handlers = handlers.NewHandlers(s.Usecase)
+
+	s.Server = httpServer.NewServer(httpServer.Config{})
+	s.Handlers.RegisterRoutes(s.Server)
+	s.Router = s.Server.Handler()
+}
+
+// Do sends a request through the router. body is sent as JSON unless nil
+// and token as a bearer token unless empty.
+func (s *BaseSuite) Do(method, target string, body interface{}, token string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		s.Require().NoError(err)
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	s.Router.ServeHTTP(w, req)
+	return w
+}
+
+// Decode reads a JSON response body into v
+func (s *BaseSuite) Decode(w *httptest.ResponseRecorder, v interface{}) {
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(v), "body: %s", w.Body.String())
+}
+
+// CreateTeam creates a team through the API and returns its secrets
+func (s *BaseSuite) CreateTeam(name string) model.CreateTeamResponse {
+	w := s.Do(http.MethodPost, "/api/team", model.CreateTeamRequest{Name: name}, "")
+	s.Require().Equal(http.StatusOK, w.Code, "body: %s", w.Body.String())
+
+	var resp model.CreateTeamResponse
+	s.Decode(w, &resp)
+	return resp
+}
+
+// openPostgres connects to TEST_POSTGRES_DSN and recreates the schema
+func (s *BaseSuite) openPostgres() *db.DB {
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		s.T().Skipf("%s is not set", PostgresDSNEnv)
+	}
+
+	database, err := db.New(db.Config{Driver: db.DriverPostgres, DSN: dsn})
+	s.Require().NoError(err, "Failed to initialize test database")
+
+	// Start every suite from an empty schema
+	migrations, err := db.Migrations(db.DriverPostgres)
+	s.Require().NoError(err)
+	migrator := db.NewMigrator(database.DB, migrations)
+	_, err = migrator.Down(len(migrations))
+	s.Require().NoError(err)
+	s.Require().NoError(migrator.Up())
+
+	return database
 }
 
 func (s *BaseSuite) TearDownSuite() {
@@ -46,9 +154,4 @@ func (s *BaseSuite) TearDownSuite() {
 	if s.DB != nil {
 		s.DB.Close()
 	}
-
-	// Remove test database file
-	if s.dbPath != "" {
-		os.Remove(s.dbPath)
-	}
 }